@@ -24,12 +24,19 @@ func init() {
 
 	// フラグは cmd/root.go のグローバル変数にバインドされます
 	authCmd.Flags().IntVar(&oauthPort, "oauth-port", 8080, "Port used for OAuth2 authentication flow.")
+	authCmd.Flags().BoolVar(&noBrowser, "no-browser", false, "Headless mode for servers without a browser: print the auth URL and read the redirect URL or code pasted on stdin instead of running a local callback server.")
+	authCmd.Flags().StringVar(&tokenStoreKind, "token-store", "file", "Where to store the OAuth token: 'file' (token.json) or 'keyring' (OS keyring).")
 }
 
 // authApplication は認証フローを実行します。
 func authApplication(cmd *cobra.Command, args []string) error {
 	log.Println("Starting YouTube OAuth2 authentication flow...")
 
+	if err := configureTokenStore(); err != nil {
+		return err
+	}
+	youtube.SetHeadlessAuth(noBrowser)
+
 	// 💡 修正: 宣言されているが使用されていなかった ctx と cancel の行を削除します。
 	// ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	// defer cancel()
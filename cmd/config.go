@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// maxShownValueLength は config show で表示する値の最大文字数です (長いプロンプトなどは省略)。
+const maxShownValueLength = 40
+
+// secretFlags は config show で値を伏せ字にするフラグ名です。
+var secretFlags = map[string]bool{
+	"api-key":        true,
+	"openai-api-key": true,
+	"redis-password": true,
+}
+
+// flagEnvVars はデフォルト値を環境変数から読み込むフラグと、その環境変数名です。
+var flagEnvVars = map[string]string{
+	"api-key":        "GEMINI_API_KEY",
+	"openai-api-key": "OPENAI_API_KEY",
+	"redis-password": "REDIS_PASSWORD",
+}
+
+// configCmd は設定関連のサブコマンドの親コマンドです。
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the application configuration.",
+}
+
+// configShowCmd は実際に有効になる設定を表示するコマンド定義です。
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the fully-resolved configuration that 'run' would use (secrets redacted).",
+	Long: `This command accepts the same flags as 'run' and prints the resolved value of
+every setting together with where it came from (flag, environment variable, or default).`,
+	RunE: showConfig,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd)
+
+	// run コマンドと同じフラグ定義を共有し、同じ方法で値を解決する
+	registerRunFlags(configShowCmd)
+}
+
+// showConfig は解決済みの設定を表示します。
+func showConfig(cmd *cobra.Command, args []string) error {
+	if err := applyPersona(cmd); err != nil {
+		return err
+	}
+	fmt.Println("--- Resolved Configuration ---")
+
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Name == "help" {
+			return
+		}
+
+		value := f.Value.String()
+		if secretFlags[f.Name] {
+			value = redact(value)
+		} else if runes := []rune(value); len(runes) > maxShownValueLength {
+			value = string(runes[:maxShownValueLength]) + "..."
+		}
+		fmt.Printf("%-26s %-40s (%s)\n", f.Name, value, flagSource(cmd, f))
+	})
+
+	// 派生した設定値
+	instructionSource := "not set"
+	if systemInstruction != "" {
+		instructionSource = fmt.Sprintf("--instruction flag (%d chars)", len([]rune(systemInstruction)))
+		if personaFlags["instruction"] {
+			instructionSource = fmt.Sprintf("persona %s (%d chars)", personaName, len([]rune(systemInstruction)))
+		}
+	}
+	fmt.Printf("%-26s %s\n", "instruction source", instructionSource)
+
+	if err := validateRunFlags(); err != nil {
+		fmt.Printf("\n⚠️ Invalid configuration: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// flagSource はフラグの値がどこから設定されたかを返します。
+func flagSource(cmd *cobra.Command, f *pflag.Flag) string {
+	if cmd.Flags().Changed(f.Name) {
+		return "flag"
+	}
+	if personaFlags[f.Name] {
+		return "persona " + personaName
+	}
+	if env, ok := flagEnvVars[f.Name]; ok && os.Getenv(env) != "" {
+		return "env " + env
+	}
+	return "default"
+}
+
+// redact は秘密情報を末尾 4 文字以外伏せ字にします。
+func redact(secret string) string {
+	if secret == "" {
+		return "(not set)"
+	}
+	runes := []rune(secret)
+	if len(runes) <= 4 {
+		return strings.Repeat("*", len(runes))
+	}
+	return strings.Repeat("*", 8) + string(runes[len(runes)-4:])
+}
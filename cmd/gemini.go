@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"prompter-live-go/internal/gemini"
+)
+
+// geminiTestPrompt は gemini test で送信する簡単なプロンプトです。
+const geminiTestPrompt = "Reply with a short greeting to confirm you are working."
+
+// geminiTestTimeout は gemini test の応答を待つ最大時間です。
+const geminiTestTimeout = 30 * time.Second
+
+// geminiCmd は Gemini 関連のサブコマンドの親コマンドです。
+var geminiCmd = &cobra.Command{
+	Use:   "gemini",
+	Short: "Gemini API utilities.",
+}
+
+// geminiTestCmd は設定したモデルと API キーで Gemini を呼び出せるかを確認するコマンド定義です。
+var geminiTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Send a trivial prompt to the configured Gemini model and print the response and token usage.",
+	Long: `This command sends a short prompt with the configured model and API key, so that a
+typo in the model name or a rejected key is found before going live.`,
+	RunE: testGemini,
+}
+
+func init() {
+	rootCmd.AddCommand(geminiCmd)
+	geminiCmd.AddCommand(geminiTestCmd)
+
+	// --api-key は rootCmd の永続フラグを共有します
+	geminiTestCmd.Flags().StringVarP(&modelName, "model", "m", "gemini-2.5-flash", "Model name to test")
+	geminiTestCmd.Flags().StringVarP(&systemInstruction, "instruction", "i", "", "System instruction (prompt) to apply to the test request")
+}
+
+// testGemini は Gemini に簡単なプロンプトを送信し、応答とトークン使用量を表示します。
+func testGemini(cmd *cobra.Command, args []string) error {
+	if err := checkGeminiAuth(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), geminiTestTimeout)
+	defer cancel()
+
+	client, err := gemini.NewClient(ctx, apiKey, geminiEndpointConfig(), modelName, systemInstruction, 1)
+	if err != nil {
+		return fmt.Errorf("error initializing Gemini Client: %w", err)
+	}
+	defer client.Close()
+
+	log.Printf("Sending test prompt to model %s...", modelName)
+	start := time.Now()
+	resp, err := client.Test(ctx, geminiTestPrompt)
+	if err != nil {
+		return fmt.Errorf("gemini test failed for model %q (check the model name and API key): %w", modelName, err)
+	}
+
+	fmt.Printf("Model:    %s\n", modelName)
+	fmt.Printf("Latency:  %v\n", time.Since(start).Truncate(time.Millisecond))
+	fmt.Printf("Tokens:   %d prompt / %d response\n", resp.PromptTokens, resp.ResponseTokens)
+	fmt.Printf("Response: %s\n", resp.ResponseText)
+	if resp.ResponseText == "" {
+		return fmt.Errorf("model %q returned an empty response", modelName)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"prompter-live-go/internal/persona"
+	"prompter-live-go/internal/youtube"
+)
+
+// personaCmd はペルソナ関連のサブコマンドの親コマンドです。
+var personaCmd = &cobra.Command{
+	Use:   "persona",
+	Short: "Persona (character preset) utilities.",
+}
+
+// personaListCmd は利用できるペルソナの一覧を表示するコマンド定義です。
+var personaListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the personas available for --persona.",
+	Long: `This command lists the persona definitions (<name>.json) found in --personas-dir.
+A persona bundles a system instruction, temperature, style variants and emoji policy,
+so that a whole character can be selected at launch with 'run --persona <name>'.`,
+	RunE: listPersonas,
+}
+
+func init() {
+	rootCmd.AddCommand(personaCmd)
+	personaCmd.AddCommand(personaListCmd)
+
+	// run コマンドと同じ変数・既定値にバインドします
+	personaListCmd.Flags().StringVar(&personasDir, "personas-dir", persona.DefaultDir, "Directory containing persona definitions.")
+}
+
+// listPersonas はペルソナの一覧と、それぞれが設定する項目を表示します。
+func listPersonas(cmd *cobra.Command, args []string) error {
+	dir, err := resolvePersonasDir()
+	if err != nil {
+		return err
+	}
+	personas, err := persona.List(dir)
+	if err != nil {
+		return err
+	}
+	if len(personas) == 0 {
+		fmt.Printf("No personas found in %s. Add <name>.json files to define them.\n", dir)
+		return nil
+	}
+
+	fmt.Printf("Personas in %s:\n", dir)
+	for _, p := range personas {
+		fmt.Printf("  %-20s %s\n", p.Name, p.Description)
+		if settings := personaSettings(p); len(settings) > 0 {
+			fmt.Printf("  %-20s sets: %s\n", "", strings.Join(settings, ", "))
+		}
+	}
+	return nil
+}
+
+// applyPersona は --persona が指定されている場合にペルソナを読み込み、その設定をフラグの値に適用します。
+// 明示的に指定されたフラグはペルソナより優先します。
+func applyPersona(cmd *cobra.Command) error {
+	if personaName == "" {
+		return nil
+	}
+	dir, err := resolvePersonasDir()
+	if err != nil {
+		return err
+	}
+	p, err := persona.Load(dir, personaName)
+	if err != nil {
+		return fmt.Errorf("--persona: %w", err)
+	}
+
+	personaFlags = make(map[string]bool)
+	set := func(flag string, ok bool, apply func()) {
+		if ok && !cmd.Flags().Changed(flag) {
+			apply()
+			personaFlags[flag] = true
+		}
+	}
+	set("instruction", p.Instruction != "", func() { systemInstruction = p.Instruction })
+	set("temperature", p.Temperature != nil, func() { temperature = *p.Temperature })
+	set("style-variants-file", len(p.StyleVariants) > 0, func() { personaStyleVariants = p.StyleVariants })
+	set("emoji-policy", p.EmojiPolicy != "", func() { emojiPolicy = p.EmojiPolicy })
+
+	log.Printf("Persona %q loaded from %s (%s).", p.Name, dir, strings.Join(personaSettings(p), ", "))
+	return nil
+}
+
+// resolvePersonasDir は --personas-dir を設定ディレクトリからの相対パスとして解決します。
+func resolvePersonasDir() (string, error) {
+	if filepath.IsAbs(personasDir) {
+		return personasDir, nil
+	}
+	configPath, err := youtube.GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configPath, personasDir), nil
+}
+
+// personaSettings はペルソナが設定する項目を表示用に列挙します。
+func personaSettings(p *persona.Persona) []string {
+	var settings []string
+	if p.Instruction != "" {
+		settings = append(settings, fmt.Sprintf("instruction (%d chars)", len([]rune(p.Instruction))))
+	}
+	if p.Temperature != nil {
+		settings = append(settings, fmt.Sprintf("temperature %v", *p.Temperature))
+	}
+	if len(p.StyleVariants) > 0 {
+		settings = append(settings, fmt.Sprintf("%d style variants", len(p.StyleVariants)))
+	}
+	if p.EmojiPolicy != "" {
+		settings = append(settings, "emoji policy "+p.EmojiPolicy)
+	}
+	return settings
+}
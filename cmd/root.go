@@ -1,33 +1,163 @@
 package cmd
 
 import (
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/spf13/cobra"
+
+	"prompter-live-go/internal/gemini"
+	"prompter-live-go/internal/util"
+	"prompter-live-go/internal/version"
+	"prompter-live-go/internal/youtube"
 )
 
 // 💡 修正: グローバル変数を定義し、cmd/run.go および cmd/auth.go で共有できるようにします。
 var (
+	// 応答バックエンド関連
+	backend       string
+	openaiBaseURL string
+	openaiAPIKey  string
+	openaiModel   string
+
 	// Gemini Live API 関連
 	apiKey             string
+	geminiEndpoint     string
+	vertexProject      string
+	vertexLocation     string
 	modelName          string
+	modelList          []string
 	systemInstruction  string
+	temperature        float64
 	responseModalities []string
+	maxConcurrent      int
 
 	// YouTube Live Chat 関連
 	youtubeChannelID string
 	pollingInterval  time.Duration
+	pollingFallback  time.Duration
+	fetchBatchSize   int
+	chatRetry        time.Duration
 	oauthPort        int
+	tokenStoreKind   string
+	stateStoreKind   string
+	redisAddr        string
+	redisPassword    string
+	redisDB          int
+	noBrowser        bool
+	tokenRefresh     time.Duration
+	liveChatWait     time.Duration
+	verifyWrite      bool
+
+	// パイプライン動作関連
+	replyProbability     float64
+	celebrateMembers     bool
+	skipLinks            bool
+	respectDeletions     bool
+	verifyPosts          bool
+	responseLanguage     string
+	digestInterval       time.Duration
+	styleVariants        string
+	noPost               bool
+	thinkingText         string
+	emptyReplyFallback   string
+	postDelay            time.Duration
+	maxInputChars        int
+	ignoreChannels       []string
+	includeStreamContext bool
+	rngSeed              int64
+	prioritize           bool
+	structuredActions    bool
+	stripMeta            bool
+	stripMetaPatterns    string
+	skipSpamAuthors      bool
+	includeAuthor        bool
+	promptTemplate       string
+	maxCommentAge        time.Duration
+	activeAfter          time.Duration
+	activeWindow         string
+	resumeState          bool
+	preserveRaw          bool
+	forceStart           bool
+	offlineQueue         int
+	offlineQueueMaxAge   time.Duration
+	recapOnExit          bool
+	trackViewers         bool
+	viewerInterval       time.Duration
+	viewerMilestoneStep  int
+	authorSpamRegex      []string
+	maxSentences         int
+	emojiPolicy          string
+	normalizeWidth       bool
+	replyLengthFactor    float64
+	replyLengthMin       int
+	replyLengthMax       int
+	faqFile              string
+	superChatTemplate    string
+	legacySystemPrompt   bool
+	legacyPromptAck      string
+	shadowInstruction    string
+	candidateCount       int
+	candidateStrategy    string
+	questionCooldown     time.Duration
+	repostRepeated       bool
+	maxExchanges         int
+	exchangeResetGap     time.Duration
+
+	// 運用関連
+	dashboardAddr string
+	maxRuntime    time.Duration
+	logLevel      string
+	logFile       string
+	logMaxSize    string
+	quiet         bool
+	// logFileCloser は --log-file で開いたログファイルです (終了時に閉じる)。
+	logFileCloser io.Closer
+	// 補助的な HTTP サーバー (ダッシュボード) の TLS と Basic 認証
+	tlsCert string
+	tlsKey  string
+	auxAuth string
+	// トランスクリプト (コメント・応答の JSON Lines 記録)
+	transcriptPath   string
+	transcriptRotate string
+	csvOut           string
+	overlayFile      string
+	// 終了時にライブチャットに投稿する挨拶
+	outroMessage     string
+	outroMessageFile string
+	// ペルソナ (--persona) とその定義を置くディレクトリ
+	personaName string
+	personasDir string
+	// personaStyleVariants はペルソナで定義されたスタイル指示です (--style-variants-file 未指定時に使用)。
+	personaStyleVariants []string
+	// personaFlags はペルソナによって値を設定したフラグ名です (config show の値の出所の表示に使用)。
+	personaFlags map[string]bool
+	// apiKeySource は Gemini API キーの取得元です (resolveAPIKey で設定)。
+	apiKeySource string
 )
 
+// geminiAPIKeyEnv は Gemini API キーを読み込む環境変数名です。
+const geminiAPIKeyEnv = "GEMINI_API_KEY"
+
 // rootCmd はアプリケーション全体のエントリポイントです。
 var rootCmd = &cobra.Command{
 	Use:   "prompter_live",
 	Short: "AI Prompter for YouTube Live Chat using Gemini Live API",
 	Long: `Prompter Live Go is a CLI tool that connects to YouTube Live Chat and uses 
 Google Gemini Live API to provide low-latency, real-time responses and promotion.`,
+	Version: version.Version,
+	// すべてのサブコマンドの実行前にログレベルを適用する
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := util.SetLogLevel(logLevel); err != nil {
+			return err
+		}
+		resolveAPIKey(cmd)
+		return setupLogFile()
+	},
 	// RunE は、サブコマンドが指定されていない場合に実行されます（ここではヘルプ表示で十分）
 	Run: func(cmd *cobra.Command, args []string) {
 		cmd.Help()
@@ -36,13 +166,113 @@ Google Gemini Live API to provide low-latency, real-time responses and promotion
 
 // Execute は rootCmd を実行するエントリポイントです。
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	if err != nil {
 		log.Println(err)
+	}
+	// ログファイルは最後のエラー出力の後に閉じる (os.Exit では defer が実行されないため明示的に閉じる)
+	if logFileCloser != nil {
+		logFileCloser.Close()
+	}
+	if err != nil {
 		os.Exit(1)
 	}
 }
 
+// resolveAPIKey は Gemini API キーをすべてのコマンドで共通の優先順位で解決します。
+// --api-key フラグが指定されていればそれを、なければ GEMINI_API_KEY 環境変数を使用します。
+// 両方が異なる値で設定されている場合は、フラグが優先されることを警告します。
+func resolveAPIKey(cmd *cobra.Command) {
+	envKey := os.Getenv(geminiAPIKeyEnv)
+	switch {
+	case cmd.Flags().Changed("api-key"):
+		apiKeySource = "--api-key flag"
+		if envKey != "" && envKey != apiKey {
+			log.Printf("Warning: --api-key and %s are both set and differ; using --api-key.", geminiAPIKeyEnv)
+		}
+	case envKey != "":
+		apiKey = envKey
+		apiKeySource = geminiAPIKeyEnv + " environment variable"
+	default:
+		apiKeySource = ""
+	}
+}
+
+// logAPIKeySource は使用する Gemini API キーの取得元を、キーを伏せ字にしてログに出力します。
+func logAPIKeySource() {
+	log.Printf("Using Gemini API key %s from %s.", redact(apiKey), apiKeySource)
+}
+
+// geminiEndpointConfig は --gemini-endpoint / --vertex-project / --vertex-location から Gemini の接続先を返します。
+func geminiEndpointConfig() gemini.Endpoint {
+	return gemini.Endpoint{URL: geminiEndpoint, VertexProject: vertexProject, VertexLocation: vertexLocation}
+}
+
+// checkGeminiAuth は Gemini の認証情報を確認し、使用する認証方法をログに出力します。
+// Vertex AI (--vertex-project) では ADC で認証するため API キーは不要です。
+func checkGeminiAuth() error {
+	if vertexProject != "" {
+		log.Printf("Using Vertex AI project %s in %s with Application Default Credentials.", vertexProject, vertexLocation)
+		return nil
+	}
+	if apiKey == "" {
+		return fmt.Errorf("gemini API key is required. Please set the GEMINI_API_KEY environment variable or use the --api-key flag (or use --vertex-project)")
+	}
+	logAPIKeySource()
+	return nil
+}
+
+// setupLogFile は --log-file が指定されている場合に、ログをファイルにも出力するよう設定します。
+func setupLogFile() error {
+	if logFile == "" {
+		if quiet {
+			return fmt.Errorf("--quiet requires --log-file")
+		}
+		return nil
+	}
+
+	var maxBytes int64
+	if logMaxSize != "" {
+		size, err := util.ParseByteSize(logMaxSize)
+		if err != nil {
+			return fmt.Errorf("--log-max-size: %w", err)
+		}
+		maxBytes = size
+	}
+
+	closer, err := util.SetupLogFile(logFile, maxBytes, quiet)
+	if err != nil {
+		return fmt.Errorf("failed to open --log-file: %w", err)
+	}
+	logFileCloser = closer
+	return nil
+}
+
+// configureTokenStore は --token-store フラグに従い、YouTube 認証トークンの保存先を設定します。
+func configureTokenStore() error {
+	configPath, err := youtube.GetConfigPath()
+	if err != nil {
+		return err
+	}
+
+	store, err := util.NewTokenStore(tokenStoreKind, filepath.Join(configPath, youtube.TokenFileName))
+	if err != nil {
+		return err
+	}
+	youtube.SetTokenStore(store)
+	return nil
+}
+
 func init() {
 	// ここではグローバルな永続フラグを設定できますが、今回は各コマンドで個別に設定済みです。
 	// 💡 修正: ここに存在していた runCmd や runApplication の重複定義を削除しました。
+	// Gemini API キーは run と gemini test で共有するため、ここで一度だけ定義する (解決は resolveAPIKey)
+	rootCmd.PersistentFlags().StringVarP(&apiKey, "api-key", "k", "", "Gemini API key. Takes precedence over the GEMINI_API_KEY env var.")
+	rootCmd.PersistentFlags().StringVar(&geminiEndpoint, "gemini-endpoint", "", "Send Gemini requests to this URL (e.g., a regional endpoint or gateway, https://host[:port]) instead of the public API.")
+	rootCmd.PersistentFlags().StringVar(&vertexProject, "vertex-project", "", "Use Gemini through Vertex AI in this Google Cloud project, authenticating with Application Default Credentials instead of an API key.")
+	rootCmd.PersistentFlags().StringVar(&vertexLocation, "vertex-location", "us-central1", "Vertex AI region (with --vertex-project).")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Also write logs to this file (appended). Disabled when empty.")
+	rootCmd.PersistentFlags().StringVar(&logMaxSize, "log-max-size", "", "Rotate --log-file into a timestamped file when it would exceed this size (e.g., 10MB). No rotation when empty.")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Do not write logs to the console; only to --log-file.")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", util.LogLevelInfo, "Log verbosity: 'info' or 'debug' (debug adds detailed traces such as raw Gemini stream chunks).")
 }
@@ -2,18 +2,32 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/spf13/cobra"
 
+	"prompter-live-go/internal/dashboard"
 	"prompter-live-go/internal/gemini"
+	"prompter-live-go/internal/openai"
+	"prompter-live-go/internal/overlay"
+	"prompter-live-go/internal/persona"
 	"prompter-live-go/internal/pipeline"
+	"prompter-live-go/internal/state"
+	"prompter-live-go/internal/stats"
+	"prompter-live-go/internal/transcript"
 	"prompter-live-go/internal/types"
+	"prompter-live-go/internal/util"
+	"prompter-live-go/internal/version"
 	"prompter-live-go/internal/youtube"
 )
 
@@ -21,38 +35,416 @@ import (
 var runCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Start the Gemini Live API chat application.",
+	// フラグ値の検証は実行前に行い、不正な値では起動しない
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyPersona(cmd); err != nil {
+			return err
+		}
+		return validateRunFlags()
+	},
 	// RunE を使用してエラーを返し、クリーンシャットダウンフローに統合
 	RunE: runApplication,
 }
 
+// channelIDPattern は YouTube のチャンネルID (UC で始まる 24 文字) の形式です。
+var channelIDPattern = regexp.MustCompile(`^UC[0-9A-Za-z_-]{22}$`)
+
+// 応答バックエンドの種別 (--backend フラグの値)
+const (
+	backendGemini = "gemini"
+	backendOpenAI = "openai"
+)
+
+// maxCandidateCount は --candidate-count の上限です (Gemini の候補数の上限に合わせています)。
+const maxCandidateCount = 8
+
+// liveChatWaitInterval は起動時に配信が見つからない場合 (--live-chat-wait) の再検索の間隔です。
+const liveChatWaitInterval = 5 * time.Second
+
 // 💡 修正： cmd/root.go との重複宣言エラーを避けるため、run.go から変数宣言を完全に削除します。
 
 func init() {
 	rootCmd.AddCommand(runCmd)
+	registerRunFlags(runCmd)
 
-	// --- Gemini Live API 関連のフラグ ---
+	runCmd.MarkFlagRequired("youtube-channel-id")
+}
+
+// registerRunFlags は run コマンドのフラグを登録します。
+// config show コマンドも同じフラグ定義を共有し、run と同じ方法で設定を解決します。
+func registerRunFlags(cmd *cobra.Command) {
+	// --- 応答バックエンド関連のフラグ ---
 	// これらのフラグは cmd/root.go で定義された変数に値をバインドします。
-	runCmd.Flags().StringVarP(&apiKey, "api-key", "k", os.Getenv("GEMINI_API_KEY"), "Gemini API key (or set GEMINI_API_KEY env var)")
-	runCmd.Flags().StringVarP(&modelName, "model", "m", "gemini-2.5-flash", "Model name to use for the live session")
-	runCmd.Flags().StringVarP(&systemInstruction, "instruction", "i", "", "System instruction (prompt) for the AI personality")
-	runCmd.Flags().StringSliceVarP(&responseModalities, "modalities", "r", []string{"TEXT"}, "Comma-separated list of response modalities (e.g., TEXT, AUDIO)")
+	cmd.Flags().StringVar(&backend, "backend", backendGemini, "AI backend used to generate replies: 'gemini' or 'openai' (any OpenAI-compatible chat completions endpoint).")
+	cmd.Flags().StringVar(&openaiBaseURL, "openai-base-url", openai.DefaultBaseURL, "Base URL of the OpenAI-compatible API (used with --backend openai).")
+	cmd.Flags().StringVar(&openaiAPIKey, "openai-api-key", os.Getenv("OPENAI_API_KEY"), "API key for the OpenAI-compatible API (or set OPENAI_API_KEY env var).")
+	cmd.Flags().StringVar(&openaiModel, "openai-model", "gpt-4o-mini", "Model name for the OpenAI-compatible API (used with --backend openai).")
+
+	// --- Gemini Live API 関連のフラグ ---
+	cmd.Flags().StringVarP(&modelName, "model", "m", "gemini-2.5-flash", "Model name to use for the live session")
+	cmd.Flags().StringSliceVar(&modelList, "models", nil, "Ordered, comma-separated list of Gemini models (e.g., gemini-2.5-flash,gemini-2.0-flash). The first is the primary model (overriding --model); the rest are tried in order on quota, rate-limit or transient errors.")
+	cmd.Flags().StringVarP(&systemInstruction, "instruction", "i", "", "System instruction (prompt) for the AI personality")
+	cmd.Flags().Float64Var(&temperature, "temperature", -1, "Sampling temperature (0-2). Negative uses the model's default.")
+	cmd.Flags().StringVar(&personaName, "persona", "", "Load a named persona from --personas-dir (<name>.json bundling instruction, temperature, style variants and emoji policy). Flags given explicitly override the persona.")
+	cmd.Flags().StringVar(&personasDir, "personas-dir", persona.DefaultDir, "Directory containing persona definitions for --persona.")
+	cmd.Flags().StringSliceVarP(&responseModalities, "modalities", "r", []string{"TEXT"}, "Comma-separated list of response modalities (e.g., TEXT, AUDIO)")
+	cmd.Flags().IntVar(&maxConcurrent, "max-concurrent-gemini", 1, "Maximum number of in-flight Gemini requests, counting each --candidate-count request and each --shadow-instruction-file request separately. Additional requests block until a slot frees.")
 
 	// --- YouTube 関連のフラグ ---
-	runCmd.Flags().StringVarP(&youtubeChannelID, "youtube-channel-id", "c", "", "YouTube Channel ID (UCC... format) for live chat posting.")
-	runCmd.Flags().DurationVar(&pollingInterval, "polling-interval", 30*time.Second, "Polling interval for YouTube Live Chat messages (e.g., 15s, 1m).")
+	cmd.Flags().StringVarP(&youtubeChannelID, "youtube-channel-id", "c", "", "YouTube Channel ID (UCC... format) for live chat posting.")
+	cmd.Flags().DurationVar(&pollingInterval, "polling-interval", 30*time.Second, "Polling interval for YouTube Live Chat messages (e.g., 15s, 1m).")
+	cmd.Flags().DurationVar(&pollingFallback, "polling-fallback", types.DefaultPollingFallback, fmt.Sprintf("Polling interval used while the API returns no polling interval hint (minimum %v).", types.MinPollingFallback))
+	cmd.Flags().IntVar(&fetchBatchSize, "fetch-batch-size", youtube.DefaultFetchBatchSize, fmt.Sprintf("Maximum chat messages fetched per poll (1-%d). The API accepts at least %d, so smaller values are raised to %d with a warning. Each poll costs the same quota regardless of size; on busy chats a small batch may fall behind and need extra polls to catch up.", youtube.MaxFetchBatchSize, youtube.MinFetchBatchSize, youtube.MinFetchBatchSize))
+	cmd.Flags().DurationVar(&liveChatWait, "live-chat-wait", time.Minute, fmt.Sprintf("At startup, keep looking for the live broadcast every %v for up to this long when the stream has just started and is not found yet. Each attempt costs search quota. 0 disables the wait.", liveChatWaitInterval))
+	cmd.Flags().BoolVar(&verifyWrite, "verify-write", false, "At startup, post a short test message to the live chat and immediately delete it, failing with the precise reason if posting or deleting is not permitted.")
+	cmd.Flags().DurationVar(&chatRetry, "chat-unavailable-retry", time.Minute, "Retry interval when live chat is disabled or subscribers/members-only (0 to stop instead of retrying).")
 	// 認証ポートフラグを追加
-	runCmd.Flags().IntVar(&oauthPort, "oauth-port", 0, "Port used for OAuth2 authentication flow (must match 'auth' command).")
+	cmd.Flags().IntVar(&oauthPort, "oauth-port", 0, "Port used for OAuth2 authentication flow (must match 'auth' command).")
+	cmd.Flags().StringVar(&tokenStoreKind, "token-store", "file", "Where the OAuth token is stored: 'file' (token.json) or 'keyring' (OS keyring). Must match 'auth' command.")
+	cmd.Flags().StringVar(&stateStoreKind, "state-store", state.StoreMemory, "Where dedup and cooldown state is kept: 'memory' (this process only) or 'redis' (survives restarts and is shared by instances using the same Redis).")
+	cmd.Flags().StringVar(&redisAddr, "redis-addr", "localhost:6379", "Redis server address (host:port) for --state-store redis.")
+	cmd.Flags().StringVar(&redisPassword, "redis-password", os.Getenv("REDIS_PASSWORD"), "Redis password for --state-store redis (or set REDIS_PASSWORD env var).")
+	cmd.Flags().IntVar(&redisDB, "redis-db", 0, "Redis database number for --state-store redis.")
+	cmd.Flags().DurationVar(&tokenRefresh, "token-refresh-margin", 0, "Proactively refresh the OAuth access token this long before it expires (e.g., 5m), keeping it fresh during quiet chats. 0 refreshes lazily on the next API call.")
 
-	runCmd.MarkFlagRequired("youtube-channel-id")
+	// --- パイプライン動作関連のフラグ ---
+	cmd.Flags().Float64Var(&replyProbability, "reply-probability", 1.0, "Probability (0.0-1.0) of replying to each eligible comment. Skipped comments are still deduplicated.")
+	cmd.Flags().BoolVar(&skipLinks, "skip-links", false, "Do not reply to comments containing URLs (http/https, www., and common link shorteners).")
+	cmd.Flags().BoolVar(&respectDeletions, "respect-deletions", false, "Do not post replies to comments that were deleted by moderators before the reply was posted.")
+	cmd.Flags().BoolVar(&verifyPosts, "verify-posts", false, "Warn when a posted reply does not appear in live chat within a short window (possible shadow moderation).")
+	cmd.Flags().StringVar(&responseLanguage, "response-language", "", "Force replies in a single language (e.g., Japanese, English). When empty, Japanese (kana), Korean (Hangul) and English (mostly Latin letters with common English words such as the, is, what, you) comments are detected and answered in kind; other comments (kanji-only, GG, romaji, other Latin-script languages) get no language directive.")
+	cmd.Flags().DurationVar(&digestInterval, "digest-interval", 0, "Instead of replying to each comment, post one consolidated digest reply for the comments received in each interval (e.g., 5m). Comments still buffered at shutdown get a final digest. 0 disables digest mode.")
+	cmd.Flags().StringVar(&styleVariants, "style-variants-file", "", "Path to a file of style directives (one per line, '#' for comments). One is picked at random per reply and appended to the prompt to vary openers and tone.")
+	cmd.Flags().BoolVar(&noPost, "no-post", false, "Preview mode: generate real AI replies (incurring normal API cost) and log them, but never post to YouTube.")
+	cmd.Flags().StringVar(&thinkingText, "thinking-placeholder", "", "Text (e.g., '🤔 thinking...') posted when a reply takes more than a few seconds to generate, before the real reply. Each placeholder is an extra post and costs extra API quota. Disabled when empty.")
+	cmd.Flags().StringVar(&emptyReplyFallback, "empty-reply-fallback", "", "Text (e.g., \"I'll let the streamer take that one! 😊\") posted instead of staying silent when the generated reply is empty, e.g. blocked by safety filters. Replies the model declines with --structured-actions are still skipped. Disabled when empty.")
+	cmd.Flags().DurationVar(&postDelay, "post-delay", 0, "Wait this long between generating a reply and posting it (e.g., 2s), giving the host a chance to answer first. 0 posts immediately.")
+	cmd.Flags().IntVar(&offlineQueue, "offline-queue", 0, "Keep up to this many replies that failed to post because the connection could not be established (connection refused, DNS failure) and post them once chat can be fetched again. Content and permission errors, timeouts and dropped connections (the reply may already be posted) are not retried. 0 disables.")
+	cmd.Flags().DurationVar(&offlineQueueMaxAge, "offline-queue-max-age", 2*time.Minute, "Drop queued replies older than this instead of posting them late (with --offline-queue).")
+	cmd.Flags().BoolVar(&legacySystemPrompt, "legacy-system-prompt", false, "Send the system instruction as an initial user/model chat turn instead of Gemini's native system instruction (for compatibility with the previous behavior).")
+	cmd.Flags().StringVar(&legacyPromptAck, "legacy-system-prompt-ack", types.DefaultLegacySystemPromptAck, "Model acknowledgment text that follows the system instruction turn (with --legacy-system-prompt).")
+	cmd.Flags().IntVar(&candidateCount, "candidate-count", 1, fmt.Sprintf("Number of reply candidates to generate per comment (1-%d). With Gemini each extra candidate is a separate request, multiplying cost.", maxCandidateCount))
+	cmd.Flags().StringVar(&candidateStrategy, "candidate-strategy", types.CandidateFirst, fmt.Sprintf("How to pick among multiple candidates: %q, %q, or %q (the shortest reply that fits YouTube's 200-character limit).", types.CandidateFirst, types.CandidateRandom, types.CandidateShortestFit))
+	cmd.Flags().BoolVar(&structuredActions, "structured-actions", false, "Have the model answer with a JSON action ({\"action\":\"answer\",\"text\":...} or {\"action\":\"ignore\"}) and post only answers, letting the model decline to reply.")
+	cmd.Flags().IntVar(&maxInputChars, "max-input-chars", 1000, "Truncate comments longer than this many characters before sending them to the AI, limiting token cost and latency. 0 disables the limit.")
+	cmd.Flags().StringSliceVar(&ignoreChannels, "ignore-channels", nil, "Comma-separated channel IDs (UC...) whose comments are never replied to, e.g. other bots in the same chat.")
+	cmd.Flags().BoolVar(&includeStreamContext, "include-stream-context", false, "Add the stream's title and description (truncated) to the system instruction so replies are aware of the stream topic.")
+	cmd.Flags().BoolVar(&trackViewers, "track-viewers", false, "Periodically fetch the stream's concurrent viewer and like counts and include them in prompts. Each fetch costs 1 quota unit (Videos.List).")
+	cmd.Flags().DurationVar(&viewerInterval, "viewer-interval", 5*time.Minute, fmt.Sprintf("How often to fetch viewer counts with --track-viewers (minimum %v).", youtube.MinViewerTrackInterval))
+	cmd.Flags().IntVar(&viewerMilestoneStep, "viewer-milestone-step", 0, "With --track-viewers, ask the model to celebrate when concurrent viewers reach a new multiple of this number (e.g., 100). 0 disables.")
+	cmd.Flags().Int64Var(&rngSeed, "rng-seed", 0, "Seed for all randomized behavior (reply probability, style variants) to make runs reproducible. 0 seeds from the current time.")
+	cmd.Flags().BoolVar(&prioritize, "prioritize", false, "Within each poll, answer Super Chats (highest amount first), then moderators, then members before other comments. Replies may be posted out of arrival order.")
+	cmd.Flags().DurationVar(&maxCommentAge, "max-comment-age", 5*time.Minute, "Do not reply to comments posted longer ago than this, e.g. the backlog returned right after a restart. 0 disables.")
+	cmd.Flags().BoolVar(&resumeState, "resume-state", true, "Save the time of the last fetched chat message to chat_state.<channel ID>.json in the config directory and, after a restart on the same live chat, treat messages up to it as already seen.")
+	cmd.Flags().BoolVar(&preserveRaw, "preserve-raw", false, "Keep the raw YouTube API message on each comment (Comment.Raw) for hooks that need fields the bot does not convert, such as emoji runs or Super Sticker details. Increases memory use.")
+	cmd.Flags().BoolVar(&forceStart, "force", false, "Start even if another instance is already running for the same channel (skips the single-instance lock).")
+	cmd.Flags().StringVar(&promptTemplate, "prompt-template", "", "Path to a Go text/template file used instead of \"<author> says: <message>\" to build the prompt for each comment, e.g. '{{.Author}} asks: {{.Message}}'. Check it with 'template validate --file'.")
+	cmd.Flags().BoolVar(&includeAuthor, "include-author", true, "Include the author's display name in the prompt (\"<author> says: <message>\"). Set to false to send only the message text so the bot does not address viewers by name.")
+	cmd.Flags().BoolVar(&skipSpamAuthors, "skip-spam-authors", false, "Skip comments from authors whose display name looks like a throwaway spam account (long digit suffix, auto-generated handle, lookalike characters) before generating a reply.")
+	cmd.Flags().StringArrayVar(&authorSpamRegex, "author-spam-regex", nil, "Regular expression matched against author display names to skip spam accounts (repeatable). Replaces the built-in --skip-spam-authors patterns and implies --skip-spam-authors.")
+	cmd.Flags().BoolVar(&stripMeta, "strip-meta", false, "Strip leading meta-commentary (e.g., \"Sure! Here's a response:\", \"As an AI...\") from replies so they start with the actual content.")
+	cmd.Flags().StringVar(&stripMetaPatterns, "strip-meta-patterns-file", "", "Path to a file of regular expressions (one per line, '#' for comments) replacing the built-in --strip-meta patterns. Each pattern only matches at the start of a reply.")
+	cmd.Flags().IntVar(&maxSentences, "max-sentences", 0, "Ask the model for at most this many sentences and truncate longer replies after the Nth sentence ending (。！？ . ! ?). 0 disables the limit.")
+	cmd.Flags().StringVar(&faqFile, "faq-file", "", "Path to a FAQ file (Markdown with one heading per question, or JSON [{\"question\", \"answer\"}]). Entries matching a comment's keywords are added to the prompt as reference material.")
+	cmd.Flags().Float64Var(&replyLengthFactor, "reply-length-factor", 0, "Scale the reply length cap with the comment length (cap = factor x comment characters, bounded by --reply-length-min/--reply-length-max). The cap is given to the model as a hint and enforced on the reply. 0 uses the fixed 200-character limit.")
+	cmd.Flags().IntVar(&replyLengthMin, "reply-length-min", 40, "Lower bound of the adaptive reply length cap in characters (with --reply-length-factor).")
+	cmd.Flags().IntVar(&replyLengthMax, "reply-length-max", youtube.MaxMessageLength, fmt.Sprintf("Upper bound of the adaptive reply length cap in characters (with --reply-length-factor, at most %d).", youtube.MaxMessageLength))
+	cmd.Flags().DurationVar(&activeAfter, "active-after", 0, "Stay silent until this long after the bot starts (e.g., 10m to skip stream setup). Comments are still fetched for dedup. 0 disables.")
+	cmd.Flags().StringVar(&activeWindow, "active-window", "", "Only generate and post replies during this local wall-clock window, as HH:MM-HH:MM (e.g., 20:00-23:30; may cross midnight). Comments are still fetched outside it.")
+	cmd.Flags().StringVar(&emojiPolicy, "emoji-policy", types.EmojiAllow, "How emoji in replies are handled: 'allow' (as generated), 'strip' (remove all emoji) or 'require' (ask for at least one and append one if missing).")
+	cmd.Flags().BoolVar(&normalizeWidth, "normalize-width", false, "Normalize character width in replies before posting: full-width letters and digits become half-width, half-width katakana becomes full-width. Full-width punctuation and emoji are left unchanged.")
+	cmd.Flags().StringVar(&superChatTemplate, "super-chat-template", "", "Acknowledgment prepended to replies to Super Chats, e.g. '💎 Thanks for the {amount} Super Chat! '. {amount} and {author} are replaced. Normal comments are unaffected. Disabled when empty.")
+	cmd.Flags().DurationVar(&questionCooldown, "question-cooldown", 0, "Do not generate a new reply to a question (ending in ? or a Japanese question form) that was already answered within this duration, regardless of who asks (e.g., 10m). 0 disables.")
+	cmd.Flags().IntVar(&maxExchanges, "max-exchanges-per-author", 0, "Limit consecutive replies to the same author to this many, then stay silent until --exchange-reset-gap passes without a reply to them. Guards against one viewer monopolizing the bot. 0 disables.")
+	cmd.Flags().DurationVar(&exchangeResetGap, "exchange-reset-gap", 5*time.Minute, "Quiet time after the last reply to an author that resets their --max-exchanges-per-author count.")
+	cmd.Flags().BoolVar(&repostRepeated, "question-cooldown-repost", false, "With --question-cooldown, re-post the previous answer to a repeated question instead of staying silent.")
+	cmd.Flags().BoolVar(&celebrateMembers, "celebrate-members", false, "Reply to new membership and membership milestone events with a congratulation.")
+
+	// --- 運用関連のフラグ ---
+	cmd.Flags().DurationVar(&maxRuntime, "max-runtime", 0, "Stop gracefully after running for this duration (e.g., 1h). 0 means run until interrupted.")
+	cmd.Flags().StringVar(&dashboardAddr, "dashboard-addr", "", "Address for the local web dashboard (e.g., :8082). Disabled when empty.")
+	cmd.Flags().StringVar(&tlsCert, "tls-cert", "", "TLS certificate file (PEM) for the auxiliary HTTP servers (dashboard). Serves HTTPS when set together with --tls-key.")
+	cmd.Flags().StringVar(&tlsKey, "tls-key", "", "TLS private key file (PEM) matching --tls-cert.")
+	cmd.Flags().StringVar(&auxAuth, "aux-auth", "", "Require HTTP basic authentication (user:pass) on the auxiliary HTTP servers (dashboard).")
+	cmd.Flags().StringVar(&transcriptPath, "transcript", "", "Record received comments and posted replies to this JSON Lines file. Disabled when empty.")
+	cmd.Flags().StringVar(&shadowInstruction, "shadow-instruction-file", "", "A/B test a second system instruction: for each comment, also generate (but never post) a reply with the instruction in this file and log it and record it in --transcript as kind \"shadow\" next to the posted reply. Doubles Gemini requests and token cost; shadow requests share the --max-concurrent-gemini limit. Gemini backend only.")
+	cmd.Flags().StringVar(&transcriptRotate, "transcript-rotate", "", "Rotate the transcript into timestamped files by size (e.g., 100MB) or \"daily\". Requires --transcript.")
+	cmd.Flags().StringVar(&outroMessage, "outro-message", "", "Message posted to the live chat during graceful shutdown if the chat is still active, e.g. '🤖 AI co-host signing off, thanks everyone!'. Disabled when empty.")
+	cmd.Flags().StringVar(&outroMessageFile, "outro-message-file", "", "Path to a file containing the --outro-message text.")
+	cmd.Flags().BoolVar(&recapOnExit, "recap-on-exit", false, "During graceful shutdown, generate a recap of the most-asked questions and their answers and post it (split into up to 3 messages) if the chat is still active. Moderators can also request one with '!bot recap'.")
+	cmd.Flags().StringVar(&overlayFile, "overlay-file", "", "Overwrite this JSON file with the latest posted reply and the author it answered, for an OBS text/browser source. Written atomically via rename. Disabled when empty.")
+	cmd.Flags().StringVar(&csvOut, "csv-out", "", "Export each processed comment (timestamp, author, comment, reply, posted, skip_reason) as a row of this CSV file for spreadsheet analysis. Disabled when empty.")
+}
+
+// apiFetchBatchSize は --fetch-batch-size を API が受け付ける範囲に収めた値を返します。
+// API の下限 (youtube.MinFetchBatchSize) 未満の値は下限に切り上げます (validateRunFlags で警告します)。
+func apiFetchBatchSize() int {
+	return max(fetchBatchSize, youtube.MinFetchBatchSize)
+}
+
+// validateRunFlags は run コマンドのフラグ値の範囲を検証します。
+func validateRunFlags() error {
+	if backend != backendGemini && backend != backendOpenAI {
+		return fmt.Errorf("--backend must be %q or %q, got %q", backendGemini, backendOpenAI, backend)
+	}
+	// 0 や負の値は time.After(0) によるビジーループで API を過剰に呼び出すため拒否する
+	if pollingInterval < types.MinPollingInterval {
+		return fmt.Errorf("--polling-interval must be at least %v, got %v", types.MinPollingInterval, pollingInterval)
+	}
+	if pollingFallback < types.MinPollingFallback {
+		return fmt.Errorf("--polling-fallback must be at least %v, got %v", types.MinPollingFallback, pollingFallback)
+	}
+	if pollingInterval < types.RecommendedMinPollingInterval {
+		log.Printf("Warning: --polling-interval %v is below YouTube's typical minimum of %v and may exhaust your API quota quickly.", pollingInterval, types.RecommendedMinPollingInterval)
+	}
+	if fetchBatchSize < 1 || fetchBatchSize > youtube.MaxFetchBatchSize {
+		return fmt.Errorf("--fetch-batch-size must be between 1 and %d, got %d", youtube.MaxFetchBatchSize, fetchBatchSize)
+	}
+	if fetchBatchSize < youtube.MinFetchBatchSize {
+		log.Printf("Warning: --fetch-batch-size %d is below the API minimum of %d; using %d. A smaller batch would not save quota, since each poll costs the same.", fetchBatchSize, youtube.MinFetchBatchSize, youtube.MinFetchBatchSize)
+	}
+	if maxConcurrent < 1 {
+		return fmt.Errorf("--max-concurrent-gemini must be at least 1, got %d", maxConcurrent)
+	}
+	if replyProbability < 0 || replyProbability > 1 {
+		return fmt.Errorf("--reply-probability must be between 0.0 and 1.0, got %v", replyProbability)
+	}
+	for _, id := range ignoreChannels {
+		if !channelIDPattern.MatchString(id) {
+			return fmt.Errorf("--ignore-channels: %q does not look like a YouTube channel ID (UC followed by 22 characters)", id)
+		}
+	}
+	if maxInputChars < 0 {
+		return fmt.Errorf("--max-input-chars must not be negative, got %d", maxInputChars)
+	}
+	if postDelay < 0 {
+		return fmt.Errorf("--post-delay must not be negative, got %v", postDelay)
+	}
+	if verifyWrite && noPost {
+		return fmt.Errorf("--verify-write posts to the live chat and cannot be combined with --no-post")
+	}
+	if liveChatWait < 0 {
+		return fmt.Errorf("--live-chat-wait must not be negative, got %v", liveChatWait)
+	}
+	if tokenRefresh < 0 {
+		return fmt.Errorf("--token-refresh-margin must not be negative, got %v", tokenRefresh)
+	}
+	if candidateCount < 1 || candidateCount > maxCandidateCount {
+		return fmt.Errorf("--candidate-count must be between 1 and %d, got %d", maxCandidateCount, candidateCount)
+	}
+	switch candidateStrategy {
+	case types.CandidateFirst, types.CandidateRandom, types.CandidateShortestFit:
+	default:
+		return fmt.Errorf("--candidate-strategy must be %q, %q or %q, got %q", types.CandidateFirst, types.CandidateRandom, types.CandidateShortestFit, candidateStrategy)
+	}
+	if maxCommentAge < 0 {
+		return fmt.Errorf("--max-comment-age must not be negative, got %v", maxCommentAge)
+	}
+	if maxExchanges < 0 {
+		return fmt.Errorf("--max-exchanges-per-author must not be negative, got %d", maxExchanges)
+	}
+	if exchangeResetGap <= 0 {
+		return fmt.Errorf("--exchange-reset-gap must be positive, got %v", exchangeResetGap)
+	}
+	if questionCooldown < 0 {
+		return fmt.Errorf("--question-cooldown must not be negative, got %v", questionCooldown)
+	}
+	if replyLengthFactor < 0 {
+		return fmt.Errorf("--reply-length-factor must not be negative, got %v", replyLengthFactor)
+	}
+	if replyLengthMin < 1 || replyLengthMin > replyLengthMax || replyLengthMax > youtube.MaxMessageLength {
+		return fmt.Errorf("--reply-length-min and --reply-length-max must satisfy 1 <= min <= max <= %d, got %d and %d", youtube.MaxMessageLength, replyLengthMin, replyLengthMax)
+	}
+	if trackViewers && viewerInterval < youtube.MinViewerTrackInterval {
+		return fmt.Errorf("--viewer-interval must be at least %v, got %v", youtube.MinViewerTrackInterval, viewerInterval)
+	}
+	if viewerMilestoneStep < 0 {
+		return fmt.Errorf("--viewer-milestone-step must not be negative, got %d", viewerMilestoneStep)
+	}
+	if viewerMilestoneStep > 0 && !trackViewers {
+		return fmt.Errorf("--viewer-milestone-step requires --track-viewers")
+	}
+	if offlineQueue < 0 {
+		return fmt.Errorf("--offline-queue must not be negative, got %d", offlineQueue)
+	}
+	if offlineQueueMaxAge <= 0 {
+		return fmt.Errorf("--offline-queue-max-age must be positive, got %v", offlineQueueMaxAge)
+	}
+	if temperature > 2 {
+		return fmt.Errorf("--temperature must be between 0 and 2, got %v", temperature)
+	}
+	if stateStoreKind != state.StoreMemory && stateStoreKind != state.StoreRedis {
+		return fmt.Errorf("--state-store must be %q or %q, got %q", state.StoreMemory, state.StoreRedis, stateStoreKind)
+	}
+	if activeAfter < 0 {
+		return fmt.Errorf("--active-after must not be negative, got %v", activeAfter)
+	}
+	if activeWindow != "" {
+		if _, err := pipeline.ParseActiveWindow(activeWindow); err != nil {
+			return fmt.Errorf("--active-window: %w", err)
+		}
+	}
+	switch emojiPolicy {
+	case types.EmojiAllow, types.EmojiStrip, types.EmojiRequire:
+	default:
+		return fmt.Errorf("--emoji-policy must be %q, %q or %q, got %q", types.EmojiAllow, types.EmojiStrip, types.EmojiRequire, emojiPolicy)
+	}
+	if maxSentences < 0 {
+		return fmt.Errorf("--max-sentences must not be negative, got %d", maxSentences)
+	}
+	if (tlsCert == "") != (tlsKey == "") {
+		return fmt.Errorf("--tls-cert and --tls-key must be specified together")
+	}
+	if auxAuth != "" {
+		if user, _, ok := strings.Cut(auxAuth, ":"); !ok || user == "" {
+			return fmt.Errorf("--aux-auth must be in the form user:pass")
+		}
+		if tlsCert == "" {
+			log.Println("Warning: --aux-auth without --tls-cert sends credentials in plain text.")
+		}
+	}
+	if n := utf8.RuneCountInString(strings.TrimSpace(emptyReplyFallback)); n > youtube.MaxMessageLength {
+		return fmt.Errorf("--empty-reply-fallback must be at most %d characters, got %d", youtube.MaxMessageLength, n)
+	}
+	if outroMessage != "" && outroMessageFile != "" {
+		return fmt.Errorf("--outro-message and --outro-message-file cannot be used together")
+	}
+	if n := utf8.RuneCountInString(strings.TrimSpace(outroMessage)); n > youtube.MaxMessageLength {
+		return fmt.Errorf("--outro-message must be at most %d characters, got %d", youtube.MaxMessageLength, n)
+	}
+	if stripMetaPatterns != "" && !stripMeta {
+		return fmt.Errorf("--strip-meta-patterns-file requires --strip-meta")
+	}
+	if digestInterval < 0 {
+		return fmt.Errorf("--digest-interval must not be negative, got %v", digestInterval)
+	}
+	if _, err := transcript.ParseRotation(transcriptRotate); err != nil {
+		return fmt.Errorf("--transcript-rotate: %w", err)
+	}
+	if shadowInstruction != "" && backend != backendGemini {
+		return fmt.Errorf("--shadow-instruction-file requires --backend %s", backendGemini)
+	}
+	if shadowInstruction != "" && transcriptPath == "" {
+		log.Println("Warning: --shadow-instruction-file without --transcript only logs the shadow replies.")
+	}
+	if transcriptRotate != "" && transcriptPath == "" {
+		return fmt.Errorf("--transcript-rotate requires --transcript")
+	}
+	return nil
+}
+
+// buildConfigs はフラグにバインドされた値から Gemini Live API 設定とパイプライン設定を構築します。
+func buildConfigs() (types.LiveAPIConfig, types.PipelineConfig) {
+	// 1. Gemini Live API 設定の構築
+	geminiConfig := types.LiveAPIConfig{
+		ModelName:         modelName,
+		SystemInstruction: systemInstruction,
+		// ResponseModalities: responseModalities, // LiveAPIConfig から削除された
+		MaxConcurrentRequests: maxConcurrent,
+		StructuredActions:     structuredActions,
+		LegacySystemPrompt:    legacySystemPrompt,
+		LegacySystemPromptAck: strings.TrimSpace(legacyPromptAck),
+		CandidateCount:        candidateCount,
+	}
+	// 負の値 (既定) の場合はモデルの既定の温度を使用する
+	if temperature >= 0 {
+		t := float32(temperature)
+		geminiConfig.Temperature = &t
+	}
+	// --models が指定された場合は、先頭を主モデル、残りをフォールバックモデルとして使用
+	if len(modelList) > 0 {
+		geminiConfig.ModelName = modelList[0]
+		geminiConfig.FallbackModels = modelList[1:]
+	}
+
+	// 2. パイプライン設定の構築 (ポーリング間隔を含む)
+	pipelineConfig := types.PipelineConfig{
+		PollingInterval:       pollingInterval,
+		PollingFallback:       pollingFallback,
+		ReplyProbability:      replyProbability,
+		RandomSeed:            rngSeed,
+		CelebrateMembers:      celebrateMembers,
+		ChatUnavailableRetry:  chatRetry,
+		SkipLinks:             skipLinks,
+		RespectDeletions:      respectDeletions,
+		VerifyPosts:           verifyPosts,
+		ResponseLanguage:      responseLanguage,
+		DigestInterval:        digestInterval,
+		NoPost:                noPost,
+		ThinkingPlaceholder:   thinkingText,
+		EmptyReplyFallback:    strings.TrimSpace(emptyReplyFallback),
+		PostDelay:             postDelay,
+		MaxInputChars:         maxInputChars,
+		IgnoreChannels:        ignoreChannels,
+		Prioritize:            prioritize,
+		StripMeta:             stripMeta,
+		SkipSpamAuthors:       skipSpamAuthors || len(authorSpamRegex) > 0,
+		OmitAuthor:            !includeAuthor,
+		MaxCommentAge:         maxCommentAge,
+		ActiveAfter:           activeAfter,
+		OfflineQueueSize:      offlineQueue,
+		OfflineQueueMaxAge:    offlineQueueMaxAge,
+		RecapOnExit:           recapOnExit,
+		TrackViewers:          trackViewers,
+		ViewerMilestoneStep:   viewerMilestoneStep,
+		MaxSentences:          maxSentences,
+		EmojiPolicy:           emojiPolicy,
+		NormalizeWidth:        normalizeWidth,
+		ReplyLengthFactor:     replyLengthFactor,
+		ReplyLengthMin:        replyLengthMin,
+		ReplyLengthMax:        replyLengthMax,
+		SuperChatTemplate:     superChatTemplate,
+		QuestionCooldown:      questionCooldown,
+		MaxExchangesPerAuthor: maxExchanges,
+		ExchangeResetGap:      exchangeResetGap,
+		RepostRepeatedAnswer:  repostRepeated,
+		CandidateStrategy:     candidateStrategy,
+	}
+
+	return geminiConfig, pipelineConfig
+}
+
+// acquireInstanceLock はチャンネルごとの多重起動防止のロックを取得し、解放する関数を返します。
+func acquireInstanceLock() (func(), error) {
+	configPath, err := youtube.GetConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	path := util.InstanceLockPath(configPath, youtubeChannelID)
+	lock, err := util.AcquireInstanceLock(path)
+	if errors.Is(err, util.ErrInstanceLocked) {
+		return nil, fmt.Errorf("another instance is already running for channel %q (lock file %s); stop it first, or pass --force to start anyway", youtubeChannelID, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire the single-instance lock: %w", err)
+	}
+	return func() {
+		if err := lock.Release(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}, nil
 }
 
 // runApplication はアプリケーションのメイン実行ロジックです。
 // この関数は runCmd の実行ロジックとして cmd/run.go に存在するのが正しいです。
 // cmd/root.go に重複定義がある場合、そちらを削除する必要があります。
 func runApplication(cmd *cobra.Command, args []string) error {
-	// APIキーの必須チェックとエラー伝播
-	if apiKey == "" {
-		return fmt.Errorf("gemini API key is required. Please set the GEMINI_API_KEY environment variable or use the --api-key flag")
+	// APIキー (Vertex AI 使用時は ADC) の必須チェックとエラー伝播 (Gemini バックエンド使用時のみ)
+	if backend == backendGemini {
+		if err := checkGeminiAuth(); err != nil {
+			return err
+		}
+	}
+	if err := configureTokenStore(); err != nil {
+		return err
+	}
+	// 同じチャンネルに対して 2 つのボットが動いていると、二重に応答してクォータも浪費するため起動時に検出する
+	if !forceStart {
+		release, err := acquireInstanceLock()
+		if err != nil {
+			return err
+		}
+		defer release()
 	}
 
 	// クリーンシャットダウンのためのコンテキスト設定
@@ -68,51 +460,431 @@ func runApplication(cmd *cobra.Command, args []string) error {
 		cancel()
 	}()
 
-	// 1. Gemini Live API 設定の構築
-	geminiConfig := types.LiveAPIConfig{
-		ModelName:         modelName,
-		SystemInstruction: systemInstruction,
-		// ResponseModalities: responseModalities, // LiveAPIConfig から削除された
+	// 最大実行時間が指定されている場合は、シグナルとタイムアウトのうち先に発生した方で停止する
+	if maxRuntime > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, maxRuntime)
+		defer cancelTimeout()
+		log.Printf("Max runtime set to %v. Scheduled stop at %s.", maxRuntime, time.Now().Add(maxRuntime).Format(time.RFC3339))
 	}
 
-	// 2. パイプライン設定の構築 (ポーリング間隔を含む)
-	pipelineConfig := types.PipelineConfig{
-		PollingInterval: pollingInterval,
+	// 1-2. Gemini Live API 設定とパイプライン設定の構築
+	geminiConfig, pipelineConfig := buildConfigs()
+	if styleVariants == "" && len(personaStyleVariants) > 0 {
+		pipelineConfig.StyleVariants = personaStyleVariants
+	}
+	if styleVariants != "" {
+		variants, err := util.LoadLinesFile(styleVariants)
+		if err != nil {
+			return fmt.Errorf("failed to load --style-variants-file: %w", err)
+		}
+		if len(variants) == 0 {
+			return fmt.Errorf("--style-variants-file %s contains no style directives", styleVariants)
+		}
+		pipelineConfig.StyleVariants = variants
+	}
+	shadow, err := loadShadowInstruction()
+	if err != nil {
+		return err
+	}
+	outro, err := loadOutroMessage()
+	if err != nil {
+		return err
+	}
+	if activeWindow != "" {
+		window, err := pipeline.ParseActiveWindow(activeWindow)
+		if err != nil {
+			return fmt.Errorf("--active-window: %w", err)
+		}
+		pipelineConfig.ActiveWindow = window
+	}
+	if faqFile != "" {
+		entries, err := pipeline.LoadFAQFile(faqFile)
+		if err != nil {
+			return fmt.Errorf("--faq-file: %w", err)
+		}
+		pipelineConfig.FAQ = entries
+	}
+	if promptTemplate != "" {
+		tmpl, _, err := loadPromptTemplate(promptTemplate)
+		if err != nil {
+			return fmt.Errorf("--prompt-template: %w", err)
+		}
+		pipelineConfig.PromptTemplate = tmpl
+	}
+	if len(authorSpamRegex) > 0 {
+		patterns, err := pipeline.CompileAuthorSpamPatterns(authorSpamRegex)
+		if err != nil {
+			return fmt.Errorf("--author-spam-regex: %w", err)
+		}
+		pipelineConfig.AuthorSpamPatterns = patterns
+	}
+	if stripMetaPatterns != "" {
+		patterns, err := util.LoadLinesFile(stripMetaPatterns)
+		if err != nil {
+			return fmt.Errorf("failed to load --strip-meta-patterns-file: %w", err)
+		}
+		if pipelineConfig.MetaPatterns, err = pipeline.CompileMetaPatterns(patterns); err != nil {
+			return fmt.Errorf("--strip-meta-patterns-file %s: %w", stripMetaPatterns, err)
+		}
 	}
 
 	log.Println("--- Gemini Live Prompter ---")
+	log.Printf("Version: %s (run ID: %s)", version.Version, version.RunID)
+	log.Printf("Backend: %s", backend)
 	log.Printf("Model: %s", geminiConfig.ModelName)
+	if len(geminiConfig.FallbackModels) > 0 {
+		log.Printf("Fallback Models: %v", geminiConfig.FallbackModels)
+	}
 	log.Printf("System Instruction: %s", geminiConfig.SystemInstruction)
 	log.Printf("Response Modalities: %v", responseModalities)
+	log.Printf("Max Concurrent Gemini Requests: %d", geminiConfig.MaxConcurrentRequests)
 	log.Printf("YouTube Channel ID: %s", youtubeChannelID)
 	log.Printf("YouTube Polling Interval: %v", pipelineConfig.PollingInterval)
+	log.Printf("YouTube Fetch Batch Size: %d", apiFetchBatchSize())
 	log.Printf("OAuth Port: %d", oauthPort)
-	log.Println("----------------------------")
-
-	// 3. Gemini Live Client の初期化
-	liveClient, err := gemini.NewClient(ctx, apiKey, geminiConfig.ModelName, geminiConfig.SystemInstruction)
-	if err != nil {
-		return fmt.Errorf("error initializing Gemini Client: %w", err)
+	log.Printf("Token Store: %s", tokenStoreKind)
+	log.Printf("State Store: %s", stateStoreKind)
+	log.Printf("Reply Probability: %.2f", pipelineConfig.ReplyProbability)
+	log.Printf("Celebrate Members: %v", pipelineConfig.CelebrateMembers)
+	log.Printf("Skip Links: %v", pipelineConfig.SkipLinks)
+	if pipelineConfig.SkipSpamAuthors {
+		if len(pipelineConfig.AuthorSpamPatterns) > 0 {
+			log.Printf("Skip Spam Authors: %d custom patterns", len(pipelineConfig.AuthorSpamPatterns))
+		} else {
+			log.Printf("Skip Spam Authors: built-in patterns")
+		}
+	}
+	if len(pipelineConfig.IgnoreChannels) > 0 {
+		log.Printf("Ignored Channels: %d loaded", len(pipelineConfig.IgnoreChannels))
+	}
+	if len(pipelineConfig.FAQ) > 0 {
+		log.Printf("FAQ: %d entries (from %s)", len(pipelineConfig.FAQ), faqFile)
+	}
+	if len(pipelineConfig.StyleVariants) > 0 {
+		source := styleVariants
+		if source == "" {
+			source = "persona " + personaName
+		}
+		log.Printf("Style Variants: %d (from %s)", len(pipelineConfig.StyleVariants), source)
+	}
+	if pipelineConfig.DigestInterval > 0 {
+		log.Printf("Digest Interval: %v", pipelineConfig.DigestInterval)
+	}
+	if pipelineConfig.ResponseLanguage != "" {
+		log.Printf("Response Language: %s", pipelineConfig.ResponseLanguage)
+	} else {
+		log.Println("Response Language: auto-detect")
+	}
+	if pipelineConfig.NoPost {
+		log.Println("No-Post Mode: replies are generated and logged but NOT posted to YouTube")
 	}
+	log.Println("----------------------------")
 
-	// 4. YouTube Client の初期化 (OAuthポートを渡す)
+	// 3. YouTube Client の初期化 (OAuthポートを渡す)
+	youtube.SetTokenRefreshMargin(tokenRefresh)
 	youtubeClient, err := youtube.NewClient(ctx, youtubeChannelID, oauthPort)
 	if err != nil {
 		return fmt.Errorf("error initializing YouTube Client: %w", err)
 	}
+	if err := youtubeClient.SetFetchBatchSize(apiFetchBatchSize()); err != nil {
+		return err
+	}
+	// 重複排除・クールダウンの状態の保存先 (--state-store)。Redis の場合は接続できなければ起動時に失敗させる
+	stateStore, err := state.New(stateStoreKind, state.Options{RedisAddr: redisAddr, RedisPassword: redisPassword, RedisDB: redisDB})
+	if err != nil {
+		return fmt.Errorf("--state-store: %w", err)
+	}
+	defer stateStore.Close()
+	youtubeClient.SetStateStore(stateStore)
+	youtubeClient.SetPreserveRaw(preserveRaw)
+	// 再起動時に、前回の実行で取得済みのメッセージにまとめて応答しないよう既読位置を引き継ぐ (--resume-state)
+	if resumeState {
+		path, err := youtube.DefaultResumeStatePath(youtubeChannelID)
+		if err != nil {
+			return err
+		}
+		if err := youtubeClient.EnableResumeState(path); err != nil {
+			return err
+		}
+	}
+	// 投稿に必要なスコープがないトークンでは、配信途中ではなく起動時に失敗させる (--no-post では投稿しないため確認しない)
+	if !noPost {
+		if err := youtubeClient.CheckWriteScope(ctx); err != nil {
+			return err
+		}
+	}
+	// 配信開始直後で配信がまだ見つからない場合に備え、起動時に限り短時間だけ再試行する (--live-chat-wait)
+	if liveChatWait > 0 {
+		if err := youtubeClient.WaitForLiveChat(ctx, liveChatWait, liveChatWaitInterval); err != nil {
+			if errors.Is(err, youtube.ErrNoLiveBroadcast) {
+				return fmt.Errorf("no live broadcast found within %v; start the stream first or increase --live-chat-wait: %w", liveChatWait, err)
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			// チャットの無効化などその他のエラーは、パイプラインのエラー処理に任せる
+			log.Printf("Warning: Could not connect to the live chat at startup: %v", err)
+		}
+	}
+	// 書き込み経路の事前検証 (--verify-write)。ライブチャットに触れるため明示的に指定された場合のみ行う
+	if verifyWrite {
+		if err := youtubeClient.VerifyWriteAccess(ctx); err != nil {
+			return err
+		}
+	}
+	// アクセストークンの先行リフレッシュ (--token-refresh-margin)。ctx のキャンセルで停止する
+	go youtubeClient.RunTokenRefresher(ctx)
+	// 同時視聴者数の定期取得 (--track-viewers)。ctx のキャンセルで停止する
+	if trackViewers {
+		go youtubeClient.RunViewerTracker(ctx, viewerInterval)
+	}
 
-	// 5. パイプラインプロセッサの初期化
-	lowLatencyProcessor := pipeline.NewLowLatencyPipeline(liveClient, youtubeClient, geminiConfig, pipelineConfig)
+	// 配信のタイトルと説明をシステム指示に追加 (--include-stream-context)
+	if includeStreamContext {
+		info, err := youtubeClient.StreamInfo(ctx)
+		if err != nil {
+			log.Printf("Warning: Could not fetch stream title/description for context: %v", err)
+		} else {
+			geminiConfig.SystemInstruction = appendStreamContext(geminiConfig.SystemInstruction, info)
+			if shadow != "" {
+				shadow = appendStreamContext(shadow, info)
+			}
+			log.Printf("Stream context added to system instruction: %q", info.Title)
+		}
+	}
 
-	// 6. パイプラインの実行
-	if err := lowLatencyProcessor.Run(ctx); err != nil {
-		if err == context.Canceled {
-			log.Println("Application stopped gracefully.")
-			return nil
+	// 4. 応答バックエンドの初期化 (Gemini Live Client または OpenAI 互換クライアント)
+	var liveClient *gemini.Client
+	var responder pipeline.Responder
+	switch backend {
+	case backendOpenAI:
+		openaiClient, err := openai.NewClient(openaiBaseURL, openaiAPIKey, openaiModel, geminiConfig.SystemInstruction)
+		if err != nil {
+			return fmt.Errorf("error initializing OpenAI-compatible Client: %w", err)
+		}
+		openaiClient.SetJSONMode(geminiConfig.StructuredActions)
+		openaiClient.SetCandidateCount(geminiConfig.CandidateCount)
+		openaiClient.SetTemperature(geminiConfig.Temperature)
+		responder = openaiClient
+	default:
+		client, err := gemini.NewClient(ctx, apiKey, geminiEndpointConfig(), geminiConfig.ModelName, geminiConfig.SystemInstruction, geminiConfig.MaxConcurrentRequests)
+		if err != nil {
+			return fmt.Errorf("error initializing Gemini Client: %w", err)
 		}
-		return fmt.Errorf("pipeline execution failed: %w", err)
+		liveClient = client
+		defer liveClient.Close()
+	}
+	// シャドーモード (--shadow-instruction-file) では、別のシステム指示で応答を生成する Client を用意する
+	var shadowClient *gemini.Client
+	if shadow != "" {
+		client, err := gemini.NewClient(ctx, apiKey, geminiEndpointConfig(), geminiConfig.ModelName, shadow, geminiConfig.MaxConcurrentRequests)
+		if err != nil {
+			return fmt.Errorf("error initializing shadow Gemini Client: %w", err)
+		}
+		// シャドーのリクエストも --max-concurrent-gemini の上限に含める
+		client.ShareConcurrencyLimit(liveClient)
+		shadowClient = client
+		defer shadowClient.Close()
 	}
 
-	log.Println("Application finished successfully.")
-	return nil
+	// 5. 実行統計とダッシュボード (任意) の初期化
+	recorder := stats.NewRecorder()
+	// 停止理由 (シグナル、最大実行時間、エラー) に関わらず、終了時にサマリーを出力
+	defer logRunSummary(recorder.Snapshot)
+	if dashboardAddr != "" {
+		server := dashboard.NewServer(dashboardAddr, recorder)
+		if tlsCert != "" {
+			if err := server.SetTLS(tlsCert, tlsKey); err != nil {
+				return fmt.Errorf("dashboard: %w", err)
+			}
+		}
+		if user, password, ok := strings.Cut(auxAuth, ":"); ok {
+			server.SetBasicAuth(user, password)
+		}
+		go func() {
+			if err := server.Run(ctx); err != nil {
+				log.Printf("Dashboard stopped: %v", err)
+			}
+		}()
+	}
+
+	// 6. パイプラインプロセッサの初期化 (YouTube クライアントをコメントソースと投稿先の両方として使用)
+	lowLatencyProcessor := pipeline.NewLowLatencyPipeline(liveClient, responder, youtubeClient, youtubeClient, geminiConfig, pipelineConfig, recorder)
+	lowLatencyProcessor.SetStateStore(stateStore)
+	if shadowClient != nil {
+		lowLatencyProcessor.SetShadowClient(shadowClient)
+	}
+
+	// トランスクリプトの記録 (バッファされた内容はシャットダウン時の Close で書き出される)
+	if transcriptPath != "" {
+		rotation, _ := transcript.ParseRotation(transcriptRotate) // validateRunFlags で検証済み
+		transcriptWriter, err := transcript.NewWriter(transcriptPath, rotation)
+		if err != nil {
+			return fmt.Errorf("failed to open transcript: %w", err)
+		}
+		defer func() {
+			if err := transcriptWriter.Close(); err != nil {
+				log.Printf("Warning: Failed to close transcript: %v", err)
+			}
+		}()
+		lowLatencyProcessor.SetTranscript(transcriptWriter)
+	}
+
+	// 処理結果の CSV エクスポート (バッファされた内容はシャットダウン時の Close で書き出される)
+	if csvOut != "" {
+		csvWriter, err := transcript.NewCSVWriter(csvOut)
+		if err != nil {
+			return fmt.Errorf("failed to open --csv-out: %w", err)
+		}
+		defer func() {
+			if err := csvWriter.Close(); err != nil {
+				log.Printf("Warning: Failed to close CSV export: %v", err)
+			}
+		}()
+		log.Printf("Exporting processed comments to %s", csvOut)
+		lowLatencyProcessor.SetCSVExport(csvWriter)
+	}
+
+	// OBS 用のオーバーレイファイル
+	if overlayFile != "" {
+		overlayWriter, err := overlay.NewWriter(overlayFile)
+		if err != nil {
+			return fmt.Errorf("failed to set up --overlay-file: %w", err)
+		}
+		log.Printf("Writing the latest reply to overlay file %s", overlayFile)
+		lowLatencyProcessor.SetOverlay(overlayWriter)
+	}
+
+	// 終了時の挨拶は、パイプラインの終了後 (終了理由に関わらず) に投稿する
+	if outro != "" {
+		defer postOutroMessage(youtubeClient, outro)
+	}
+
+	// 7. パイプラインの実行
+	return pipelineResult(ctx, lowLatencyProcessor.Run(ctx))
+}
+
+// pipelineResult はパイプラインの終了時のエラーを run コマンドの戻り値に変換します。
+// シャットダウン (SIGINT など) や --max-runtime による ctx の終了は正常終了として nil を返します。
+func pipelineResult(ctx context.Context, err error) error {
+	if err == nil {
+		log.Println("Application finished successfully.")
+		return nil
+	}
+	// %w でラップされたエラーも判定できるよう errors.Is を使用する。
+	// 処理中のリクエスト自体のタイムアウトと区別するため、ctx が終了している場合のみ正常終了とみなす。
+	if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+		log.Println("Application stopped gracefully.")
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+		log.Printf("Max runtime of %v reached. Application stopped gracefully.", maxRuntime)
+		return nil
+	}
+	return fmt.Errorf("pipeline execution failed: %w", err)
+}
+
+// outroPostTimeout は終了時の挨拶の投稿を待つ最大時間です。投稿が応答しない場合でも終了を妨げないようにします。
+const outroPostTimeout = 5 * time.Second
+
+// loadOutroMessage は --outro-message または --outro-message-file から終了時の挨拶を読み込みます。
+func loadOutroMessage() (string, error) {
+	if outroMessageFile == "" {
+		return strings.TrimSpace(outroMessage), nil
+	}
+	text, err := util.LoadPromptFile(outroMessageFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to load --outro-message-file: %w", err)
+	}
+	text = strings.TrimSpace(text)
+	if n := utf8.RuneCountInString(text); n > youtube.MaxMessageLength {
+		return "", fmt.Errorf("--outro-message-file %s must be at most %d characters, got %d", outroMessageFile, youtube.MaxMessageLength, n)
+	}
+	return text, nil
+}
+
+// loadShadowInstruction はシャドーモードのシステム指示 (--shadow-instruction-file) を読み込みます。未指定の場合は空文字列を返します。
+func loadShadowInstruction() (string, error) {
+	if shadowInstruction == "" {
+		return "", nil
+	}
+	text, err := util.LoadPromptFile(shadowInstruction)
+	if err != nil {
+		return "", fmt.Errorf("failed to load --shadow-instruction-file: %w", err)
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("--shadow-instruction-file %s is empty", shadowInstruction)
+	}
+	return text, nil
+}
+
+// postOutroMessage はライブチャットがまだ有効な場合に終了時の挨拶を投稿します。
+// シャットダウン時はアプリケーションのコンテキストがキャンセル済みのため、独自のタイムアウト付きコンテキストを使用します。
+func postOutroMessage(client *youtube.Client, message string) {
+	if noPost {
+		log.Printf("[no-post] Would post outro message: %s", message)
+		return
+	}
+	if !client.HasActiveChat() {
+		log.Println("Live chat is no longer active. Skipping outro message.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), outroPostTimeout)
+	defer cancel()
+	if err := client.PostComment(ctx, message); err != nil {
+		log.Printf("Warning: Failed to post outro message: %v", err)
+	}
+}
+
+// maxStreamDescriptionLength はシステム指示に含める配信の説明の最大文字数です。
+const maxStreamDescriptionLength = 500
+
+// appendStreamContext は配信のタイトルと説明 (長い場合は切り詰め) をシステム指示に追加します。
+func appendStreamContext(instruction string, info youtube.StreamInfo) string {
+	description := []rune(strings.TrimSpace(info.Description))
+	if len(description) > maxStreamDescriptionLength {
+		description = append(description[:maxStreamDescriptionLength], '…')
+	}
+
+	streamContext := fmt.Sprintf("This stream is titled '%s' about: %s", info.Title, string(description))
+	if instruction == "" {
+		return streamContext
+	}
+	return instruction + "\n\n" + streamContext
+}
+
+// logRunSummary は実行終了時のサマリー (実行時間、処理件数、スキップ理由、エラー数、トークン使用量、応答の所要時間) を出力します。
+// defer 時点ではなく終了時の統計を取得するため、スナップショット関数を受け取ります。
+func logRunSummary(snapshot func() stats.Snapshot) {
+	snap := snapshot()
+
+	log.Println("--- Run Summary ---")
+	log.Printf("Total Runtime: %v", time.Since(snap.StartedAt).Truncate(time.Second))
+	log.Printf("Comments Processed: %d", snap.CommentsProcessed)
+	log.Printf("Replies Posted: %d", snap.RepliesPosted)
+
+	reasons := make([]string, 0, len(snap.SkipsByReason))
+	for reason := range snap.SkipsByReason {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		log.Printf("Skipped (%s): %d", reason, snap.SkipsByReason[reason])
+	}
+
+	log.Printf("Errors: %d", snap.Errors)
+	log.Printf("Gemini Tokens: %d prompt / %d response (estimated cost: $%.4f)", snap.PromptTokens, snap.ResponseTokens, snap.EstimatedCostUSD())
+	if total := snap.Latency.Total; total.Count > 0 {
+		log.Printf("Reply Latency: avg %v, p50 %v, p95 %v, max %v (%d replies)", roundLatency(total.Mean()), roundLatency(total.Quantile(0.5)), roundLatency(total.Quantile(0.95)), roundLatency(total.Max), total.Count)
+		log.Printf("  Breakdown (avg): fetch %v, queue %v, generate %v, post %v", roundLatency(snap.Latency.Fetch.Mean()), roundLatency(snap.Latency.Queue.Mean()), roundLatency(snap.Latency.Generate.Mean()), roundLatency(snap.Latency.Post.Mean()))
+	}
+	log.Println("-------------------")
+}
+
+// roundLatency はサマリー表示用に所要時間を 0.1 秒単位に丸めます。
+func roundLatency(d time.Duration) time.Duration {
+	return d.Round(100 * time.Millisecond)
 }
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"prompter-live-go/internal/pipeline"
+	"prompter-live-go/internal/stats"
+	"prompter-live-go/internal/types"
+	"prompter-live-go/internal/youtube"
+)
+
+// parseRunFlags は run コマンドのフラグを既定値で登録し直してから args を解析します。
+// フラグはパッケージの変数にバインドされるため、テストを並行して実行してはいけません。
+func parseRunFlags(t *testing.T, args ...string) {
+	t.Helper()
+	cmd := &cobra.Command{Use: "run"}
+	registerRunFlags(cmd)
+	if err := cmd.ParseFlags(args); err != nil {
+		t.Fatalf("ParseFlags(%v): %v", args, err)
+	}
+}
+
+func TestValidateRunFlagsPollingInterval(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{name: "zero", value: "0s", wantErr: "--polling-interval must be at least 1s"},
+		{name: "negative", value: "-5s", wantErr: "--polling-interval must be at least 1s"},
+		{name: "sub-second", value: "500ms", wantErr: "--polling-interval must be at least 1s"},
+		{name: "minimum", value: "1s"},
+		{name: "below recommended minimum (warns)", value: "1500ms"},
+		{name: "default-like", value: "30s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseRunFlags(t, "--polling-interval", tt.value)
+			err := validateRunFlags()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateRunFlags() with --polling-interval %s: %v", tt.value, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validateRunFlags() with --polling-interval %s = %v, want error containing %q", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPipelineResult(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithTimeout(context.Background(), -time.Second)
+	defer cancelExpired()
+	running := context.Background()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		err     error
+		wantErr bool
+	}{
+		{name: "finished", ctx: running, err: nil},
+		{name: "canceled", ctx: canceled, err: context.Canceled},
+		{name: "wrapped cancellation", ctx: canceled, err: fmt.Errorf("fetch: %w", context.Canceled)},
+		{name: "max runtime", ctx: expired, err: context.DeadlineExceeded},
+		{name: "wrapped max runtime", ctx: expired, err: fmt.Errorf("generate: %w", context.DeadlineExceeded)},
+		// ctx が終了していないタイムアウトは、個々のリクエストの失敗であり正常終了ではない
+		{name: "request timeout while running", ctx: running, err: fmt.Errorf("post: %w", context.DeadlineExceeded), wantErr: true},
+		{name: "cancellation while running", ctx: running, err: context.Canceled, wantErr: true},
+		{name: "other error", ctx: canceled, err: errors.New("chat is disabled"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := pipelineResult(tt.ctx, tt.err)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pipelineResult(%v) = %v, want error %v", tt.err, err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, tt.err) {
+				t.Errorf("pipelineResult(%v) = %v, want it to wrap the pipeline error", tt.err, err)
+			}
+		})
+	}
+}
+
+// idleSource はコメントを返さないコメントソースです。
+type idleSource struct{}
+
+func (idleSource) FetchLiveChatMessages(ctx context.Context) ([]youtube.Comment, time.Duration, error) {
+	return nil, 0, nil
+}
+func (idleSource) IsCommentDeleted(commentID string) bool { return false }
+
+// nopResponder は空の応答を返す Responder です。
+type nopResponder struct{}
+
+func (nopResponder) GenerateResponse(ctx context.Context, data types.LiveStreamData) (*types.LowLatencyResponse, error) {
+	return &types.LowLatencyResponse{Done: true}, nil
+}
+
+func TestPipelineShutdownIsClean(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+	}{
+		{name: "SIGINT", ctx: func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+			return ctx, cancel
+		}},
+		{name: "max runtime", ctx: func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 50*time.Millisecond)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			p := pipeline.NewLowLatencyPipeline(nil, nopResponder{}, idleSource{}, nil, types.LiveAPIConfig{},
+				types.PipelineConfig{PollingInterval: time.Second, ReplyProbability: 1}, stats.NewRecorder())
+
+			if err := pipelineResult(ctx, p.Run(ctx)); err != nil {
+				t.Fatalf("pipelineResult after shutdown = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestValidateRunFlagsFetchBatchSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+		wantAPI int
+	}{
+		{name: "zero", value: "0", wantErr: "--fetch-batch-size must be between 1 and 2000"},
+		{name: "negative", value: "-1", wantErr: "--fetch-batch-size must be between 1 and 2000"},
+		{name: "above maximum", value: "2001", wantErr: "--fetch-batch-size must be between 1 and 2000"},
+		{name: "one is raised to the API minimum (warns)", value: "1", wantAPI: youtube.MinFetchBatchSize},
+		{name: "below API minimum (warns)", value: "199", wantAPI: youtube.MinFetchBatchSize},
+		{name: "API minimum", value: "200", wantAPI: 200},
+		{name: "default-like", value: "500", wantAPI: 500},
+		{name: "maximum", value: "2000", wantAPI: 2000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseRunFlags(t, "--fetch-batch-size", tt.value)
+			err := validateRunFlags()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("validateRunFlags() with --fetch-batch-size %s = %v, want error containing %q", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateRunFlags() with --fetch-batch-size %s: %v", tt.value, err)
+			}
+			if got := apiFetchBatchSize(); got != tt.wantAPI {
+				t.Errorf("apiFetchBatchSize() with --fetch-batch-size %s = %d, want %d", tt.value, got, tt.wantAPI)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/spf13/cobra"
+
+	"prompter-live-go/internal/pipeline"
+	"prompter-live-go/internal/util"
+)
+
+// templateFile は template validate で検証するテンプレートファイルのパスです。
+var templateFile string
+
+// templateCmd はプロンプトテンプレート関連のサブコマンドの親コマンドです。
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Prompt template utilities.",
+}
+
+// templateValidateCmd はプロンプトテンプレートを配信前に検証するコマンド定義です。
+var templateValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Parse a prompt template and render it against a sample comment.",
+	Long: `This command parses a prompt template (--prompt-template) and renders it against a sample
+comment, so that a misspelled field such as {{.Auther}} is found before going live rather than
+at the first comment. The rendered sample is printed on success.`,
+	RunE: validateTemplate,
+}
+
+func init() {
+	rootCmd.AddCommand(templateCmd)
+	templateCmd.AddCommand(templateValidateCmd)
+
+	templateValidateCmd.Flags().StringVar(&templateFile, "file", "", "Path to the prompt template file to validate")
+	templateValidateCmd.MarkFlagRequired("file")
+}
+
+// validateTemplate はテンプレートを解析・サンプルに適用し、生成されたプロンプトを表示します。
+func validateTemplate(cmd *cobra.Command, args []string) error {
+	_, sample, err := loadPromptTemplate(templateFile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Template %s is valid. Rendered with a sample comment:\n\n%s\n", templateFile, sample)
+	return nil
+}
+
+// loadPromptTemplate はプロンプトテンプレートを読み込み、サンプルのコメントに適用して検証します。
+// 存在しないフィールドの参照などは、最初のコメントではなく読み込み時にエラーになります。
+func loadPromptTemplate(path string) (*template.Template, string, error) {
+	text, err := util.LoadPromptFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load prompt template: %w", err)
+	}
+	tmpl, err := pipeline.ParsePromptTemplate(path, text)
+	if err != nil {
+		return nil, "", err
+	}
+	sample, err := pipeline.RenderPromptTemplate(tmpl, pipeline.SamplePromptComment)
+	if err != nil {
+		return nil, "", err
+	}
+	if sample == "" {
+		return nil, "", fmt.Errorf("prompt template %s renders to an empty prompt", path)
+	}
+	return tmpl, sample, nil
+}
@@ -0,0 +1,261 @@
+package dashboard
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"prompter-live-go/internal/stats"
+)
+
+// Server は実行統計を表示するローカル Web ダッシュボードです。
+type Server struct {
+	addr     string
+	recorder *stats.Recorder
+
+	// tlsConfig が設定されている場合は HTTPS で待ち受けます (--tls-cert / --tls-key)。
+	tlsConfig *tls.Config
+	// authUser が空でない場合は Basic 認証を要求します (--aux-auth)。
+	authUser     string
+	authPassword string
+}
+
+// entryView は JSON API で返すコメント・応答 1 件の表現です。
+type entryView struct {
+	Time   time.Time `json:"time"`
+	Author string    `json:"author"`
+	Text   string    `json:"text"`
+}
+
+// statsView は JSON API (/api/stats) のレスポンスです。
+type statsView struct {
+	Uptime            string      `json:"uptime"`
+	CommentsProcessed int         `json:"comments_processed"`
+	RepliesPosted     int         `json:"replies_posted"`
+	Errors            int         `json:"errors"`
+	PollInterval      string      `json:"poll_interval"`
+	PromptTokens      int64       `json:"prompt_tokens"`
+	ResponseTokens    int64       `json:"response_tokens"`
+	RecentComments    []entryView `json:"recent_comments"`
+	RecentReplies     []entryView `json:"recent_replies"`
+	// Latency はコメントの投稿から応答の投稿までの所要時間の内訳 (fetch, queue, generate, post, total) です。
+	Latency []latencyView `json:"latency"`
+}
+
+// latencyView は JSON API で返す所要時間の内訳 1 つの集計です。
+type latencyView struct {
+	Stage string `json:"stage"`
+	Count int    `json:"count"`
+	Mean  string `json:"mean"`
+	P50   string `json:"p50"`
+	P95   string `json:"p95"`
+	Max   string `json:"max"`
+}
+
+// NewServer は新しいダッシュボードサーバーを作成します。
+func NewServer(addr string, recorder *stats.Recorder) *Server {
+	return &Server{
+		addr:     addr,
+		recorder: recorder,
+	}
+}
+
+// SetTLS は HTTPS で待ち受けるための証明書と秘密鍵を読み込みます。Run の開始前に呼び出す必要があります。
+// 読み込みに失敗した場合は、起動時に検出できるようエラーを返します。
+func (s *Server) SetTLS(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	s.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	return nil
+}
+
+// SetBasicAuth はすべてのリクエストに Basic 認証を要求するよう設定します。Run の開始前に呼び出す必要があります。
+func (s *Server) SetBasicAuth(user, password string) {
+	s.authUser = user
+	s.authPassword = password
+}
+
+// Run はダッシュボードの HTTP サーバーを起動し、ctx がキャンセルされるまでブロックします。
+func (s *Server) Run(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleIndex)
+	mux.HandleFunc("/api/stats", s.handleStats)
+
+	// ポート使用中などのエラーを呼び出し元に返すため、リッスンは同期的に行う
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on dashboard address %s: %w", s.addr, err)
+	}
+	scheme := "http"
+	if s.tlsConfig != nil {
+		ln = tls.NewListener(ln, s.tlsConfig)
+		scheme = "https"
+	}
+
+	srv := &http.Server{Handler: s.withBasicAuth(mux)}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Dashboard listening on %s://%s", scheme, ln.Addr())
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("dashboard server failed: %w", err)
+	}
+	return nil
+}
+
+// withBasicAuth は Basic 認証が設定されている場合に、認証に成功したリクエストのみを next に渡すハンドラーを返します。
+func (s *Server) withBasicAuth(next http.Handler) http.Handler {
+	if s.authUser == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		// タイミング攻撃を避けるため、ユーザー名とパスワードの両方を定数時間で比較する
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(s.authUser)) == 1
+		passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(s.authPassword)) == 1
+		if !ok || !userOK || !passwordOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="prompter-live-go", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleStats は現在の統計を JSON で返します。
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	snap := s.recorder.Snapshot()
+
+	view := statsView{
+		Uptime:            time.Since(snap.StartedAt).Truncate(time.Second).String(),
+		CommentsProcessed: snap.CommentsProcessed,
+		RepliesPosted:     snap.RepliesPosted,
+		Errors:            snap.Errors,
+		PollInterval:      snap.PollInterval.String(),
+		PromptTokens:      snap.PromptTokens,
+		ResponseTokens:    snap.ResponseTokens,
+		RecentComments:    toEntryViews(snap.RecentComments),
+		RecentReplies:     toEntryViews(snap.RecentReplies),
+		Latency:           toLatencyViews(snap.Latency),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(view); err != nil {
+		log.Printf("Dashboard: failed to encode stats: %v", err)
+	}
+}
+
+// handleIndex は統計 API をポーリングして表示する HTML ページを返します。
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(indexHTML))
+}
+
+// toEntryViews は統計のエントリを新しい順の JSON 表現に変換します。
+func toEntryViews(entries []stats.Entry) []entryView {
+	views := make([]entryView, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		views = append(views, entryView{Time: entries[i].Time, Author: entries[i].Author, Text: entries[i].Text})
+	}
+	return views
+}
+
+// toLatencyViews は所要時間のヒストグラムを内訳の順に JSON 表現に変換します。
+func toLatencyViews(l stats.LatencyHistograms) []latencyView {
+	stages := []struct {
+		name string
+		h    stats.Histogram
+	}{
+		{"fetch", l.Fetch},
+		{"queue", l.Queue},
+		{"generate", l.Generate},
+		{"post", l.Post},
+		{"total", l.Total},
+	}
+	views := make([]latencyView, 0, len(stages))
+	for _, s := range stages {
+		views = append(views, latencyView{
+			Stage: s.name,
+			Count: s.h.Count,
+			Mean:  s.h.Mean().Truncate(time.Millisecond).String(),
+			P50:   s.h.Quantile(0.5).Truncate(time.Millisecond).String(),
+			P95:   s.h.Quantile(0.95).Truncate(time.Millisecond).String(),
+			Max:   s.h.Max.Truncate(time.Millisecond).String(),
+		})
+	}
+	return views
+}
+
+// indexHTML はダッシュボードのページです。/api/stats を数秒ごとに取得して表示を更新します。
+const indexHTML = `<!DOCTYPE html>
+<html lang="ja">
+<head>
+<meta charset="utf-8">
+<title>Prompter Live Go Dashboard</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+td, th { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>Prompter Live Go</h1>
+<table id="summary"></table>
+<h2>Reply Latency</h2>
+<table id="latency"></table>
+<h2>Recent Replies</h2>
+<table id="replies"></table>
+<h2>Recent Comments</h2>
+<table id="comments"></table>
+<script>
+function esc(s) {
+  const d = document.createElement("div");
+  d.textContent = s;
+  return d.innerHTML;
+}
+function rows(entries) {
+  return "<tr><th>Time</th><th>Author</th><th>Text</th></tr>" + entries.map(e =>
+    "<tr><td>" + new Date(e.time).toLocaleTimeString() + "</td><td>" + esc(e.author) + "</td><td>" + esc(e.text) + "</td></tr>").join("");
+}
+async function refresh() {
+  try {
+    const s = await (await fetch("/api/stats")).json();
+    document.getElementById("summary").innerHTML =
+      "<tr><th>Uptime</th><td>" + s.uptime + "</td></tr>" +
+      "<tr><th>Comments</th><td>" + s.comments_processed + "</td></tr>" +
+      "<tr><th>Replies</th><td>" + s.replies_posted + "</td></tr>" +
+      "<tr><th>Errors</th><td>" + s.errors + "</td></tr>" +
+      "<tr><th>Poll Interval</th><td>" + s.poll_interval + "</td></tr>" +
+      "<tr><th>Tokens (prompt / response)</th><td>" + s.prompt_tokens + " / " + s.response_tokens + "</td></tr>";
+    document.getElementById("latency").innerHTML =
+      "<tr><th>Stage</th><th>Replies</th><th>Mean</th><th>p50</th><th>p95</th><th>Max</th></tr>" + s.latency.map(l =>
+        "<tr><td>" + l.stage + "</td><td>" + l.count + "</td><td>" + l.mean + "</td><td>" + l.p50 + "</td><td>" + l.p95 + "</td><td>" + l.max + "</td></tr>").join("");
+    document.getElementById("replies").innerHTML = rows(s.recent_replies);
+    document.getElementById("comments").innerHTML = rows(s.recent_comments);
+  } catch (e) {
+    console.error(e);
+  }
+}
+refresh();
+setInterval(refresh, 3000);
+</script>
+</body>
+</html>
+`
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
 
 	"prompter-live-go/internal/types"
+	"prompter-live-go/internal/version"
 
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
@@ -22,50 +24,160 @@ type Session interface {
 type Client struct {
 	baseClient *genai.Client
 	modelName  string
+	// endpoint は接続先です (Vertex AI 使用時のモデル名の変換に使用)。
+	endpoint Endpoint
 	// システム指示をClientレベルで保持
 	systemInstruction string
+	// sem は同時に実行中の Gemini リクエスト数を制限するセマフォです。
+	// Client から作成されたすべてのセッションで共有されます。
+	sem chan struct{}
+
+	// sessions は Close 時にまとめて終了させるため、開いているセッションを追跡します。
+	sessions   map[*geminiLiveSession]struct{}
+	sessionsMu sync.Mutex
 }
 
 // NewClient は新しい Gemini Client インスタンスを作成します。
-func NewClient(ctx context.Context, apiKey string, modelName string, systemInstruction string) (*Client, error) {
-	if apiKey == "" {
-		return nil, fmt.Errorf("gemini API key is required")
+// endpoint は接続先で、ゼロ値の場合は公開 API に apiKey で接続します (Vertex AI 使用時は apiKey を使用しません)。
+// maxConcurrent は同時に実行できる Gemini リクエストの上限です (1 未満の場合は 1)。
+func NewClient(ctx context.Context, apiKey string, endpoint Endpoint, modelName string, systemInstruction string, maxConcurrent int) (*Client, error) {
+	opts, err := endpoint.clientOptions(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
 	}
 
 	// 1. genai.Client の初期化
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+	client, err := genai.NewClient(ctx, append(opts, option.WithUserAgent(version.UserAgent()))...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create genai client: %w", err)
 	}
 
-	log.Printf("Gemini Client initialized with model: %s", modelName)
+	log.Printf("Gemini Client initialized with model: %s via %s (max concurrent requests: %d)", modelName, endpoint, maxConcurrent)
 
 	// 2. Client構造体を作成
 	return &Client{
 		baseClient:        client,
 		modelName:         modelName,
+		endpoint:          endpoint,
 		systemInstruction: systemInstruction,
+		sem:               make(chan struct{}, maxConcurrent),
+		sessions:          make(map[*geminiLiveSession]struct{}),
 	}, nil
 }
 
+// ShareConcurrencyLimit は同時に実行できる Gemini リクエストの上限 (セマフォ) を other と共有します。
+// シャドーモードの Client が本来の Client と同じ --max-concurrent-gemini の枠を使うために使用します。
+// セッションはそれぞれ作成時のセマフォを使うため、セッションを開始する前に呼び出してください。
+func (c *Client) ShareConcurrencyLimit(other *Client) {
+	c.sem = other.sem
+}
+
 // StartSession は新しい会話セッションを開始し、その Session インターフェースを返します。
 func (c *Client) StartSession(ctx context.Context, config types.LiveAPIConfig) (Session, error) {
+	return c.StartSessionWithHistory(ctx, config, nil)
+}
+
+// StartSessionWithHistory は指定した会話履歴から新しい会話セッションを開始します。
+// 過去の会話の再開や few-shot 例の注入に使用できます。history が空の場合は StartSession と同じです。
+func (c *Client) StartSessionWithHistory(ctx context.Context, config types.LiveAPIConfig, history []*genai.Content) (Session, error) {
 	// 1. モデルを取得。
-	model := c.baseClient.GenerativeModel(c.modelName)
+	model := c.baseClient.GenerativeModel(c.endpoint.modelPath(c.modelName))
 
 	// 2. 内部セッション (newGeminiLiveSession) を作成
 	// c.systemInstruction を第3引数として渡し、ペルソナを適用
-	session := newGeminiLiveSession(model, config, c.systemInstruction)
+	session := newGeminiLiveSession(model, config, c.systemInstruction, c.sem)
+	session.modelName = c.modelName
+	if session.candidateCount > 1 {
+		log.Printf("Generating %d reply candidates per comment (each candidate is a separate request).", session.candidateCount)
+	}
+	for _, name := range config.FallbackModels {
+		fallback := c.baseClient.GenerativeModel(c.endpoint.modelPath(name))
+		configureModel(fallback, config, c.systemInstruction)
+		session.fallbacks = append(session.fallbacks, fallbackModel{name: name, model: fallback})
+	}
+	if len(history) > 0 {
+		// システム指示の初期履歴の後ろに、指定された会話履歴を続ける
+		session.chatSession.History = append(session.chatSession.History, history...)
+	}
+
+	// Client.Close でまとめて終了できるよう登録し、セッション側の Close で登録を解除する
+	c.sessionsMu.Lock()
+	c.sessions[session] = struct{}{}
+	c.sessionsMu.Unlock()
+	session.onClose = func() {
+		c.sessionsMu.Lock()
+		delete(c.sessions, session)
+		c.sessionsMu.Unlock()
+	}
 
-	log.Printf("New Gemini Session started for model: %s", c.modelName)
+	log.Printf("New Gemini Session started for model: %s (initial history: %d turns)", c.modelName, len(history))
 
 	// 3. Sessionインターフェースとして返す
 	return session, nil
 }
 
-// Close は基盤となる genai.Client 接続を閉じます。
+// Test は簡単なプロンプトを 1 回だけ送信し (会話履歴は使用しません)、応答とトークン使用量を返します。
+// モデル名や API キーの誤りを配信前に検出するために使用します。
+func (c *Client) Test(ctx context.Context, prompt string) (*types.LowLatencyResponse, error) {
+	model := c.baseClient.GenerativeModel(c.endpoint.modelPath(c.modelName))
+	if c.systemInstruction != "" {
+		model.SystemInstruction = NewTurn("user", c.systemInstruction)
+	}
+
+	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	if err != nil {
+		return nil, err
+	}
+
+	var text string
+	if len(resp.Candidates) > 0 {
+		text = candidateText(resp.Candidates[0])
+	}
+
+	result := &types.LowLatencyResponse{ResponseText: text, Done: true}
+	if resp.UsageMetadata != nil {
+		result.PromptTokens = resp.UsageMetadata.PromptTokenCount
+		result.ResponseTokens = resp.UsageMetadata.CandidatesTokenCount
+	}
+	return result, nil
+}
+
+// NewTurn は StartSessionWithHistory に渡す会話履歴の 1 ターンを作成します。
+// role には "user" または "model" を指定します。
+func NewTurn(role string, text string) *genai.Content {
+	return &genai.Content{
+		Role:  role,
+		Parts: []genai.Part{genai.Text(text)},
+	}
+}
+
+// Close は開いているすべてのセッションを終了し (実行中のストリーム処理の完了を待ちます)、
+// 基盤となる genai.Client 接続を閉じます。複数回呼び出しても安全です。
 func (c *Client) Close() {
+	// 1. 開いているセッションを取り出す (session.Close は登録解除のためロックを取るので、ロック外で呼ぶ)
+	c.sessionsMu.Lock()
+	sessions := make([]*geminiLiveSession, 0, len(c.sessions))
+	for s := range c.sessions {
+		sessions = append(sessions, s)
+	}
+	c.sessionsMu.Unlock()
+
+	// 2. 各セッションを終了
+	for _, s := range sessions {
+		s.Close()
+	}
+	if len(sessions) > 0 {
+		log.Printf("Closed %d open Gemini session(s).", len(sessions))
+	}
+
+	// 3. genai.Client を閉じる
 	if c.baseClient != nil {
-		c.baseClient.Close()
+		if err := c.baseClient.Close(); err != nil {
+			log.Printf("Warning: Failed to close Gemini client: %v", err)
+		}
+		c.baseClient = nil
 	}
 }
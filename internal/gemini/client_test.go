@@ -0,0 +1,192 @@
+package gemini
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"prompter-live-go/internal/types"
+)
+
+// sessionGoroutines は gemini パッケージ (テストのファイルを除く) のコードを実行中のゴルーチンのスタックを返します。
+func sessionGoroutines() []string {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	var leaked []string
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		for _, line := range strings.Split(g, "\n") {
+			if strings.Contains(line, "/internal/gemini/") && !strings.Contains(line, "_test.go:") {
+				leaked = append(leaked, g)
+				break
+			}
+		}
+	}
+	return leaked
+}
+
+func TestClientCloseLeavesNoGoroutines(t *testing.T) {
+	tests := []struct {
+		name     string
+		sessions int
+		inFlight int
+	}{
+		{name: "idle sessions", sessions: 2, inFlight: 0},
+		{name: "in-flight stream", sessions: 1, inFlight: 1},
+		{name: "several in-flight streams", sessions: 3, inFlight: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 応答を返さず、クライアントが接続を切るまでストリームを保持するサーバー
+			received := make(chan struct{}, tt.inFlight)
+			stop := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// 本文を読み終えると、クライアントの切断を r.Context() で検出できる
+				io.Copy(io.Discard, r.Body)
+				received <- struct{}{}
+				select {
+				case <-r.Context().Done():
+				case <-stop:
+				}
+			}))
+			defer srv.Close()
+			defer close(stop)
+
+			ctx := context.Background()
+			client, err := NewClient(ctx, "test-key", Endpoint{URL: srv.URL}, "gemini-test", "You are a test bot.", tt.inFlight+1)
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+
+			var sessions []Session
+			for i := 0; i < tt.sessions; i++ {
+				s, err := client.StartSession(ctx, types.LiveAPIConfig{})
+				if err != nil {
+					t.Fatalf("StartSession: %v", err)
+				}
+				sessions = append(sessions, s)
+			}
+			for i := 0; i < tt.inFlight; i++ {
+				if err := sessions[i].Send(ctx, types.LiveStreamData{Text: "hello", Author: "viewer"}); err != nil {
+					t.Fatalf("Send: %v", err)
+				}
+				select {
+				case <-received:
+				case <-time.After(5 * time.Second):
+					t.Fatal("the stream request never reached the server")
+				}
+			}
+
+			closed := make(chan struct{})
+			go func() {
+				client.Close()
+				close(closed)
+			}()
+			select {
+			case <-closed:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Client.Close did not return while streams were in flight")
+			}
+
+			// Close はストリーム処理の完了を待つため、セッションのゴルーチンは残らない
+			if leaked := sessionGoroutines(); len(leaked) > 0 {
+				t.Fatalf("%d goroutine(s) leaked after Close:\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+			}
+			client.sessionsMu.Lock()
+			open := len(client.sessions)
+			client.sessionsMu.Unlock()
+			if open != 0 {
+				t.Errorf("%d session(s) still registered after Close", open)
+			}
+			for _, s := range sessions {
+				if err := s.Send(ctx, types.LiveStreamData{Text: "hello"}); err == nil {
+					t.Error("Send after Close succeeded, want an error")
+				}
+			}
+			// 複数回呼び出しても安全
+			client.Close()
+		})
+	}
+}
+
+// TestShareConcurrencyLimit は、上限を共有した 2 つの Client (本来の Client とシャドーの Client) の
+// リクエストを合わせても、同時に実行中のリクエスト数が上限を超えないことを確認します。
+func TestShareConcurrencyLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		share    bool
+		wantPeak int // 同時に実行中のリクエスト数の上限
+	}{
+		{name: "shared limit", share: true, wantPeak: 1},
+		{name: "separate limits", share: false, wantPeak: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inFlight, peak atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+				n := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(50 * time.Millisecond)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":{"code":400,"message":"invalid argument","status":"INVALID_ARGUMENT"}}`))
+			}))
+			defer srv.Close()
+
+			ctx := context.Background()
+			primary, err := NewClient(ctx, "test-key", Endpoint{URL: srv.URL}, "gemini-test", "primary", 1)
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			defer primary.Close()
+			shadow, err := NewClient(ctx, "test-key", Endpoint{URL: srv.URL}, "gemini-test", "shadow", 1)
+			if err != nil {
+				t.Fatalf("NewClient (shadow): %v", err)
+			}
+			defer shadow.Close()
+			if tt.share {
+				shadow.ShareConcurrencyLimit(primary)
+			}
+
+			var wg sync.WaitGroup
+			for _, client := range []*Client{primary, shadow} {
+				session, err := client.StartSession(ctx, types.LiveAPIConfig{})
+				if err != nil {
+					t.Fatalf("StartSession: %v", err)
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range 3 {
+						if err := session.Send(ctx, types.LiveStreamData{Text: "hello"}); err != nil {
+							t.Errorf("Send: %v", err)
+							return
+						}
+						if _, err := session.RecvResponse(); err != nil {
+							t.Errorf("RecvResponse: %v", err)
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			if got := int(peak.Load()); got != tt.wantPeak {
+				t.Errorf("peak in-flight requests = %d, want %d", got, tt.wantPeak)
+			}
+		})
+	}
+}
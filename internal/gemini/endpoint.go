@@ -0,0 +1,102 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
+)
+
+// Endpoint は Gemini の接続先と認証方法です。ゼロ値は公開 API (API キーで認証) を表します。
+type Endpoint struct {
+	// URL が設定されている場合、既定の接続先の代わりにこの URL (https://host[:port]) に接続します。
+	URL string
+	// VertexProject が設定されている場合、Vertex AI のこのプロジェクトを使用し、
+	// API キーの代わりに Application Default Credentials (ADC) で認証します。
+	VertexProject string
+	// VertexLocation は Vertex AI のリージョン (例: us-central1) です。
+	VertexLocation string
+}
+
+// IsVertex は Vertex AI を使用するかどうかを返します。
+func (e Endpoint) IsVertex() bool {
+	return e.VertexProject != ""
+}
+
+// String は接続先をログ出力用の文字列で返します。
+func (e Endpoint) String() string {
+	switch {
+	case e.IsVertex() && e.URL != "":
+		return fmt.Sprintf("Vertex AI (project %s, location %s) at %s", e.VertexProject, e.VertexLocation, e.URL)
+	case e.IsVertex():
+		return fmt.Sprintf("Vertex AI (project %s, location %s)", e.VertexProject, e.VertexLocation)
+	case e.URL != "":
+		return e.URL
+	default:
+		return "Gemini API"
+	}
+}
+
+// vertexScope は Vertex AI の呼び出しに必要な OAuth スコープです。
+const vertexScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// clientOptions は接続先と認証方法に応じた genai.Client のオプションを返します。
+func (e Endpoint) clientOptions(ctx context.Context, apiKey string) ([]option.ClientOption, error) {
+	var opts []option.ClientOption
+	if e.URL != "" {
+		u, err := url.Parse(e.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("invalid Gemini endpoint %q: expected https://host[:port]", e.URL)
+		}
+		opts = append(opts, option.WithEndpoint(strings.TrimSuffix(e.URL, "/")))
+	}
+
+	if !e.IsVertex() {
+		if apiKey == "" {
+			return nil, fmt.Errorf("gemini API key is required")
+		}
+		return append(opts, option.WithAPIKey(apiKey)), nil
+	}
+
+	if e.VertexLocation == "" {
+		return nil, fmt.Errorf("a Vertex AI location is required")
+	}
+	if e.URL == "" {
+		opts = append(opts, option.WithEndpoint(fmt.Sprintf("https://%s-aiplatform.googleapis.com", e.VertexLocation)))
+	}
+	creds, err := google.FindDefaultCredentials(ctx, vertexScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find Application Default Credentials for Vertex AI (run 'gcloud auth application-default login' or set GOOGLE_APPLICATION_CREDENTIALS): %w", err)
+	}
+	transport := &vertexTransport{base: &oauth2.Transport{Source: creds.TokenSource, Base: http.DefaultTransport}}
+	return append(opts, option.WithHTTPClient(&http.Client{Transport: transport})), nil
+}
+
+// modelPath は Vertex AI 使用時に、モデル名を Vertex AI のリソース名に変換します。
+// 既に "/" を含む名前 (リソース名・チューニング済みモデル) と公開 API 使用時はそのまま返します。
+func (e Endpoint) modelPath(name string) string {
+	if !e.IsVertex() || strings.ContainsRune(name, '/') {
+		return name
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/publishers/google/models/%s", e.VertexProject, e.VertexLocation, name)
+}
+
+// vertexTransport は Gemini API 用のリクエストパス (/v1beta/...) を Vertex AI の API バージョン (/v1/...) に書き換えます。
+// genai パッケージは Gemini API のパスしか組み立てないため、Vertex AI ではこの書き換えが必要です。
+type vertexTransport struct {
+	base http.RoundTripper
+}
+
+func (t *vertexTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rest, ok := strings.CutPrefix(req.URL.Path, "/v1beta/"); ok {
+		req = req.Clone(req.Context())
+		req.URL.Path = "/v1/" + rest
+		req.URL.RawPath = ""
+	}
+	return t.base.RoundTrip(req)
+}
@@ -2,46 +2,100 @@ package gemini
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"prompter-live-go/internal/types"
+	"prompter-live-go/internal/util"
 
 	"github.com/google/generative-ai-go/genai"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+)
+
+// レート制限・一時的なエラー時の再試行の設定
+const (
+	// retryAttempts は 1 つのモデルに対する最大試行回数 (初回を含む) です。
+	retryAttempts = 3
+	// retryBaseDelay はサーバーから待機時間の指示がない場合の指数バックオフの初期値です。
+	retryBaseDelay = time.Second
+	// maxRetryDelay はサーバーが指示した待機時間に従う上限です。これを超える場合は再試行しません。
+	maxRetryDelay = 30 * time.Second
 )
 
 // geminiLiveSession は Gemini Live API との対話セッションを管理します。
 type geminiLiveSession struct {
 	chatSession *genai.ChatSession
+	// model は主モデルです (複数の応答候補を生成する際に会話を複製するために使用)。
+	model *genai.GenerativeModel
+	// modelName は主モデルの名前です (ログ出力用)。
+	modelName string
+	// candidateCount は 1 回の応答で生成する候補の数です。
+	candidateCount int
+	// fallbacks はクォータ超過などで主モデルが失敗した場合に順に試すモデルです。
+	fallbacks []fallbackModel
 
 	// responseChan は完全な応答テキストと Done シグナルをパイプラインに送信します。
+	// Send 1 回につき、必ず 1 件の応答 (空の応答やエラーを含む) が送信されます。
 	responseChan chan *types.LowLatencyResponse
-	// doneChan は内部ストリーム処理が完了したことを通知します。
-	doneChan chan error
-	mu       sync.Mutex
+	// sem は Client と共有する同時リクエスト数制限用のセマフォです。
+	sem chan struct{}
+	mu  sync.Mutex
+
+	// closeCtx は Close 時にキャンセルされ、実行中のストリーム処理を中断します。
+	closeCtx  context.Context
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+	// wg は Send が起動したストリーム処理のゴルーチンを追跡します。
+	wg sync.WaitGroup
+	// onClose は Close 時に Client からの登録を解除するためのコールバックです。
+	onClose func()
+}
+
+// fallbackModel はフォールバック先のモデルとその名前です。
+type fallbackModel struct {
+	name  string
+	model *genai.GenerativeModel
 }
 
 // newGeminiLiveSession は新しい geminiLiveSession を作成します。
-// systemInstruction を受け取り、それを初期履歴としてモデルに渡し、ペルソナを適用します。
-func newGeminiLiveSession(model *genai.GenerativeModel, config types.LiveAPIConfig, systemInstruction string) *geminiLiveSession {
-	// 履歴として Content を構築しますが、SDKの互換性エラーのため、この履歴は現在 StartChat に渡せません。
-	// システム指示の適用は、最初のユーザーメッセージを装って送信されることで処理されます。
-	if systemInstruction != "" {
-		log.Printf("Applying System Instruction (Note: Due to SDK constraint, applied via first message): '%s'", systemInstruction)
-	}
+// systemInstruction はモデルのシステム指示として設定し、ペルソナを適用します
+// (--legacy-system-prompt 指定時は初期履歴として渡します)。
+func newGeminiLiveSession(model *genai.GenerativeModel, config types.LiveAPIConfig, systemInstruction string, sem chan struct{}) *geminiLiveSession {
+	// システム指示と、構造化アクションモードの JSON モード・レスポンススキーマを設定する
+	configureModel(model, config, systemInstruction)
 
 	// 履歴を自動で管理する ChatSession を開始
 	// 💡 修正: ユーザー環境でバリアディックな呼び出しが失敗するため、引数なしで呼び出します。
 	// この呼び出しにより、**ビルドエラーが確実に解消されます**。
 	chatSession := model.StartChat()
 
+	// 💡 修正: 互換モード (--legacy-system-prompt) では、システム指示を最初のメッセージとして送信するのではなく、
+	// 会話履歴に直接追加します。送受信の往復が不要になり、最初の実際のコメントへの応答を消費することもありません。
+	if systemInstruction != "" && config.LegacySystemPrompt {
+		log.Printf("Applying System Instruction via initial history: '%s'", systemInstruction)
+		chatSession.History = systemInstructionHistory(systemInstruction, config.LegacySystemPromptAck)
+	}
+
+	closeCtx, cancel := context.WithCancel(context.Background())
+
 	return &geminiLiveSession{
-		chatSession:  chatSession,
-		responseChan: make(chan *types.LowLatencyResponse, 1),
-		doneChan:     make(chan error, 1),
+		chatSession:    chatSession,
+		model:          model,
+		candidateCount: max(config.CandidateCount, 1),
+		responseChan:   make(chan *types.LowLatencyResponse, 1),
+		sem:            sem,
+		closeCtx:       closeCtx,
+		cancel:         cancel,
 	}
 }
 
@@ -54,57 +108,277 @@ func (s *geminiLiveSession) Send(ctx context.Context, data types.LiveStreamData)
 	// ユーザー入力の genai.Part を作成
 	userInput := genai.Text(data.Text)
 
+	if s.closeCtx.Err() != nil {
+		return fmt.Errorf("gemini session is closed")
+	}
+
+	// セッションが閉じられた場合もストリームを中断できるよう、専用のコンテキストを使用
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(s.closeCtx, cancel)
+
 	// 非同期でストリーム処理を実行
+	s.wg.Add(1)
 	go func() {
+		defer s.wg.Done()
 		defer func() {
-			// 処理が完了したことを通知
-			s.doneChan <- io.EOF
+			stop()
+			cancel()
 		}()
 
-		// 1. ストリームを開始
-		stream := s.chatSession.SendMessageStream(ctx, userInput)
-		var responseBuilder strings.Builder
+		// 1. ストリームを開始し、完全な応答を受信 (レート制限時はサーバーが指示する時間だけ待って再試行)
+		// 同時リクエスト数の上限 (sem) は、候補ごとの個々のリクエストに適用する
+		text, candidates, usage, err := generateCandidates(ctx, s.sem, s.modelName, s.model, s.chatSession, userInput, s.candidateCount)
+
+		// 2. クォータ超過などの一時的なエラーの場合は、フォールバックモデルを順に試す
+		failedModel := s.modelName
+		for i := 0; err != nil && isFallbackError(err) && i < len(s.fallbacks); i++ {
+			fallback := s.fallbacks[i]
+			log.Printf("Gemini model %s failed: %v. Falling back to %s.", failedModel, err, fallback.name)
 
-		// 2. ストリームが完了するまでチャンクを累積
-		for {
-			resp, err := stream.Next()
-			if err == io.EOF {
-				break // ストリーム完了
+			// フォールバックモデルには現在の会話履歴を引き継ぎ、成功した場合はその履歴を主セッションに戻す
+			chat := fallback.model.StartChat()
+			chat.History = append([]*genai.Content(nil), s.chatSession.History...)
+			text, candidates, usage, err = generateCandidates(ctx, s.sem, fallback.name, fallback.model, chat, userInput, s.candidateCount)
+			if err == nil {
+				s.chatSession.History = chat.History
+				log.Printf("Reply generated by fallback model %s.", fallback.name)
 			}
-			if err != nil {
-				log.Printf("Gemini stream error: %v", err)
-				s.responseChan <- &types.LowLatencyResponse{ResponseText: fmt.Sprintf("Error: %v", err.Error()), Done: true}
-				return
+			failedModel = fallback.name
+		}
+		if err != nil {
+			// エラーの内容は応答テキストではなく Err で通知し、ライブチャットに投稿されないようにする
+			log.Printf("Gemini stream error: %v", err)
+			s.deliver(&types.LowLatencyResponse{Done: true, Err: err})
+			return
+		}
+
+		// 3. 累積した完全な応答を responseChan に一度だけ送信
+		// (空の応答の場合も Done シグナルとして送信し、パイプラインのブロックを解除する)
+		result := &types.LowLatencyResponse{
+			ResponseText: text,
+			Done:         true, // 応答完了シグナル
+			Candidates:   candidates,
+		}
+		if usage != nil {
+			result.PromptTokens = usage.PromptTokenCount
+			result.ResponseTokens = usage.CandidatesTokenCount
+		}
+		s.deliver(result)
+	}()
+
+	return nil
+}
+
+// generateCandidates は chat の会話履歴に input を送信し、n 件の応答候補を生成します。
+// genai の ChatSession は候補数を 1 に固定するため、n が 2 以上の場合は会話履歴を複製した n 個のチャットで
+// 並行して生成します (リクエスト数とトークン消費は n 倍になります)。会話履歴には最初に成功した候補を記録します。
+// 各候補のリクエストは sem の空きを 1 つずつ取得するため、同時に送信するリクエスト数は sem の容量を超えません。
+// 戻り値の text は最初の候補、candidates は n が 2 以上の場合の成功したすべての候補です。
+func generateCandidates(ctx context.Context, sem chan struct{}, modelName string, model *genai.GenerativeModel, chat *genai.ChatSession, input genai.Part, n int) (text string, candidates []string, usage *genai.UsageMetadata, err error) {
+	if n <= 1 {
+		text, usage, err = streamWithRetry(ctx, sem, modelName, chat, input)
+		return text, nil, usage, err
+	}
+
+	type result struct {
+		chat  *genai.ChatSession
+		text  string
+		usage *genai.UsageMetadata
+		err   error
+	}
+	results := make([]result, n)
+	var wg sync.WaitGroup
+	for i := range results {
+		r := &results[i]
+		r.chat = model.StartChat()
+		r.chat.History = append([]*genai.Content(nil), chat.History...)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.text, r.usage, r.err = streamWithRetry(ctx, sem, modelName, r.chat, input)
+		}()
+	}
+	wg.Wait()
+
+	usage = &genai.UsageMetadata{}
+	for _, r := range results {
+		if r.err != nil {
+			if err == nil {
+				err = r.err
 			}
+			continue
+		}
+		if candidates == nil {
+			chat.History = r.chat.History
+		}
+		candidates = append(candidates, r.text)
+		if r.usage != nil {
+			usage.PromptTokenCount += r.usage.PromptTokenCount
+			usage.CandidatesTokenCount += r.usage.CandidatesTokenCount
+		}
+	}
+	if len(candidates) == 0 {
+		return "", nil, nil, err
+	}
+	return candidates[0], candidates, usage, nil
+}
+
+// streamWithSlot は同時リクエスト数の上限 (sem) に空きが出るまで待ってから streamMessage を 1 回実行し、完了後に空きを返します。
+func streamWithSlot(ctx context.Context, sem chan struct{}, chat *genai.ChatSession, input genai.Part) (string, *genai.UsageMetadata, error) {
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return "", nil, ctx.Err()
+	}
+	defer func() { <-sem }()
+	return streamMessage(ctx, chat, input)
+}
+
+// streamWithRetry は streamMessage を実行し、レート制限・一時的なエラーの場合は最大 retryAttempts 回まで再試行します。
+// 待機時間はエラーに含まれる Retry-After / RetryInfo の指示に従い、指示がない場合は指数バックオフを使用します。
+// 指示された待機時間が maxRetryDelay を超える場合は、応答が古くなるため再試行せずにエラーを返します (フォールバックモデルに切り替わります)。
+// 同時リクエスト数の上限 (sem) の空きは試行ごとに取得し、再試行までの待機中は他のリクエストに譲ります。
+func streamWithRetry(ctx context.Context, sem chan struct{}, modelName string, chat *genai.ChatSession, input genai.Part) (string, *genai.UsageMetadata, error) {
+	history := chat.History
+	for attempt := 1; ; attempt++ {
+		text, usage, err := streamWithSlot(ctx, sem, chat, input)
+		if err == nil {
+			return text, usage, nil
+		}
+		// 失敗したリクエストの入力が履歴に残ると、再試行時にユーザーのターンが重複するため元に戻す
+		chat.History = history
+
+		if !isFallbackError(err) || attempt >= retryAttempts {
+			return "", nil, err
+		}
+		delay, hinted := retryAfterHint(err)
+		if !hinted {
+			delay = retryBaseDelay << (attempt - 1)
+		} else if delay > maxRetryDelay {
+			log.Printf("Gemini model %s is rate limited; the server asked to retry in %v, which exceeds %v. Giving up on this model.", modelName, delay, maxRetryDelay)
+			return "", nil, err
+		}
+
+		if hinted {
+			log.Printf("Gemini model %s is rate limited (attempt %d/%d). Honoring server retry delay of %v.", modelName, attempt, retryAttempts, delay)
+		} else {
+			log.Printf("Gemini model %s failed (attempt %d/%d): %v. Retrying in %v.", modelName, attempt, retryAttempts, err, delay)
+		}
+		select {
+		case <-ctx.Done():
+			return "", nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
 
-			// チャンクからテキストを抽出して累積
-			if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
-				// genai.Part はスライスなので、最初の要素をチェック
-				if len(resp.Candidates[0].Content.Parts) > 0 {
-					if textPart, ok := resp.Candidates[0].Content.Parts[0].(genai.Text); ok {
-						responseBuilder.WriteString(string(textPart))
-					}
-				}
+// retryAfterHint はエラーに含まれる再試行までの待機時間の指示を返します。
+// gRPC のエラー詳細 (RetryInfo) と、HTTP の Retry-After ヘッダー (秒数または日時) に対応します。
+func retryAfterHint(err error) (time.Duration, bool) {
+	var apiErr *apierror.APIError
+	if errors.As(err, &apiErr) {
+		if info := apiErr.Details().RetryInfo; info != nil && info.GetRetryDelay() != nil {
+			return info.GetRetryDelay().AsDuration(), true
+		}
+	}
+
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) {
+		if v := strings.TrimSpace(gErr.Header.Get("Retry-After")); v != "" {
+			if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+				return time.Duration(secs) * time.Second, true
+			}
+			if t, err := http.ParseTime(v); err == nil {
+				return max(time.Until(t), 0), true
 			}
 		}
+	}
+	return 0, false
+}
 
-		// 3. 累積した完全な応答を responseChan に一度だけ送信
-		fullResponse := responseBuilder.String()
-		if fullResponse != "" {
-			s.responseChan <- &types.LowLatencyResponse{
-				ResponseText: fullResponse,
-				Done:         true, // 応答完了シグナル
+// streamMessage はメッセージをチャットセッションに送信し、ストリームが完了するまでチャンクを累積して返します。
+func streamMessage(ctx context.Context, chat *genai.ChatSession, input genai.Part) (string, *genai.UsageMetadata, error) {
+	stream := chat.SendMessageStream(ctx, input)
+	var responseBuilder strings.Builder
+	var usage *genai.UsageMetadata
+	var finishReason genai.FinishReason
+
+	for {
+		resp, err := stream.Next()
+		// genai のイテレーターは完了時に io.EOF ではなく iterator.Done を返す
+		if errors.Is(err, iterator.Done) || errors.Is(err, io.EOF) {
+			break // ストリーム完了
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		// トークン使用量は (通常最後の) チャンクに含まれる
+		if resp.UsageMetadata != nil {
+			usage = resp.UsageMetadata
+		}
+
+		// チャンクからテキストを抽出して累積
+		if len(resp.Candidates) > 0 {
+			cand := resp.Candidates[0]
+			if cand.FinishReason != genai.FinishReasonUnspecified {
+				finishReason = cand.FinishReason
 			}
+			chunk := candidateText(cand)
+			responseBuilder.WriteString(chunk)
+			util.Debugf("Gemini stream chunk (%d bytes, total %d, finish reason %v): %q", len(chunk), responseBuilder.Len(), cand.FinishReason, truncateForLog(chunk, 120))
 		}
+	}
+
+	// ブロックされた・テキスト以外のパートのみの応答は、空の応答として返す (パイプラインで投稿がスキップされる)
+	if responseBuilder.Len() == 0 {
+		log.Printf("Gemini returned no text parts (finish reason %v); treating as an empty response.", finishReason)
+	}
+	return responseBuilder.String(), usage, nil
+}
 
-		// 4. (重要) responseChan に何も送信されない場合 (空の応答など) に備え、Doneシグナルを送り、パイプラインのブロックを解除する
-		if fullResponse == "" {
-			s.responseChan <- &types.LowLatencyResponse{ResponseText: "", Done: true}
+// candidateText は候補に含まれるテキストのパートを連結して返します。
+// ブロックされた応答などでパートが空の場合や、テキスト以外のパート (関数呼び出しなど) のみの場合は空文字列を返します。
+func candidateText(cand *genai.Candidate) string {
+	if cand == nil || cand.Content == nil {
+		return ""
+	}
+	var text strings.Builder
+	for _, part := range cand.Content.Parts {
+		if t, ok := part.(genai.Text); ok {
+			text.WriteString(string(t))
+		} else {
+			util.Debugf("Ignoring non-text Gemini part %T", part)
 		}
+	}
+	return text.String()
+}
 
-	}()
+// isFallbackError はエラーがクォータ超過・レート制限・一時的なサーバーエラーであり、
+// 別のモデルで再試行する価値があるかどうかを判定します。
+func isFallbackError(err error) bool {
+	var apiErr *apierror.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.HTTPCode() {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable:
+			return true
+		}
+		if st := apiErr.GRPCStatus(); st != nil {
+			switch st.Code() {
+			case codes.ResourceExhausted, codes.Unavailable, codes.Internal:
+				return true
+			}
+		}
+	}
 
-	return nil
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) {
+		switch gErr.Code {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable:
+			return true
+		}
+	}
+	return false
 }
 
 // RecvResponse は完全な応答が生成されるのを待ち、それを一度だけ返します。
@@ -112,28 +386,86 @@ func (s *geminiLiveSession) RecvResponse() (*types.LowLatencyResponse, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// Send は必ず 1 件の応答を送信するため、完了通知用の別チャネルは使わずに応答そのものを待つ
 	select {
-	case <-s.doneChan:
-		// 内部ストリーム処理が完了したことを示す
-		// responseChan からまだ読み込まれていないデータがあれば読み込む
-		select {
-		case resp := <-s.responseChan:
-			return resp, nil
-		default:
-			// Done が通知されたが、responseChan にデータが残っていない場合は、EOFを返す
-			return &types.LowLatencyResponse{Done: true}, io.EOF
-		}
+	case resp := <-s.responseChan:
+		return resp, nil
+	case <-s.closeCtx.Done():
+		// セッションが閉じられた場合は、これ以上応答が届かないため EOF を返す
+		return &types.LowLatencyResponse{Done: true}, io.EOF
+	}
+}
 
-	case resp, ok := <-s.responseChan:
-		if !ok {
-			return nil, io.EOF
-		}
+// configureModel は設定に応じてモデルのシステム指示と生成設定を適用します。主モデルとフォールバックモデルの両方に使用します。
+// システム指示はモデルのネイティブなシステム指示として設定します。会話のターンとして扱われないため、
+// 後のターンで上書きされにくく、トークンの消費も抑えられます。
+func configureModel(model *genai.GenerativeModel, config types.LiveAPIConfig, systemInstruction string) {
+	if systemInstruction != "" && !config.LegacySystemPrompt {
+		model.SystemInstruction = NewTurn("user", systemInstruction)
+	}
+	if config.Temperature != nil {
+		model.SetTemperature(*config.Temperature)
+	}
+	if config.StructuredActions {
+		model.ResponseMIMEType = "application/json"
+		model.ResponseSchema = actionSchema
+	}
+}
 
-		return resp, nil
+// actionSchema は構造化アクションモードの応答スキーマです ({"action": "answer"|"ignore", "text": "..."})。
+var actionSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"action": {
+			Type: genai.TypeString,
+			Enum: []string{"answer", "ignore"},
+		},
+		"text": {
+			Type:        genai.TypeString,
+			Description: "The reply to post when action is answer.",
+		},
+	},
+	Required: []string{"action"},
+}
+
+// truncateForLog はログ出力用に文字列を最大 n 文字 (rune) に切り詰めます。
+func truncateForLog(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
+
+// systemInstructionHistory はシステム指示をユーザーターンとして、その了承をモデルのターンとして表す初期履歴を作成します。
+// 互換モード (--legacy-system-prompt) でのみ使用します。ack が空の場合は既定の応答を使用します。
+func systemInstructionHistory(systemInstruction, ack string) []*genai.Content {
+	if ack == "" {
+		ack = types.DefaultLegacySystemPromptAck
+	}
+	return []*genai.Content{
+		NewTurn("user", systemInstruction),
+		NewTurn("model", ack),
+	}
+}
+
+// deliver は応答を responseChan に送信します。
+// セッションが閉じられ、受信側がいなくなった場合はゴルーチンが残らないよう破棄します。
+func (s *geminiLiveSession) deliver(resp *types.LowLatencyResponse) {
+	select {
+	case s.responseChan <- resp:
+	case <-s.closeCtx.Done():
 	}
 }
 
-// Close はセッションとクライアントをクリーンアップします。
+// Close はセッションを終了し、実行中のストリーム処理を中断してその完了を待ちます。
+// 複数回呼び出しても安全です。
 func (s *geminiLiveSession) Close() {
-	// ここでは特に何も行いません。
+	s.closeOnce.Do(func() {
+		s.cancel()
+		s.wg.Wait()
+		if s.onClose != nil {
+			s.onClose()
+		}
+	})
 }
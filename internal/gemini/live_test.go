@@ -0,0 +1,161 @@
+package gemini
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+// newFakeGeminiModel は streamGenerateContent に再試行の対象外のエラー (400) を返すテスト用サーバーに接続したモデルを作成します。
+// リクエストの数と同時実行数の確認に使用します。handle はリクエストごとに応答の前に呼び出されます。
+func newFakeGeminiModel(t *testing.T, handle func()) *genai.GenerativeModel {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":400,"message":"invalid argument","status":"INVALID_ARGUMENT"}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := genai.NewClient(context.Background(), option.WithAPIKey("test-key"), option.WithEndpoint(srv.URL))
+	if err != nil {
+		t.Fatalf("genai.NewClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client.GenerativeModel("gemini-test")
+}
+
+func TestGenerateCandidatesRespectsConcurrencyLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		candidates    int
+		maxConcurrent int
+	}{
+		{name: "more candidates than slots", candidates: 4, maxConcurrent: 1},
+		{name: "two slots", candidates: 4, maxConcurrent: 2},
+		{name: "single candidate", candidates: 1, maxConcurrent: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inFlight, peak, total atomic.Int32
+			model := newFakeGeminiModel(t, func() {
+				n := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				total.Add(1)
+				time.Sleep(20 * time.Millisecond)
+			})
+
+			sem := make(chan struct{}, tt.maxConcurrent)
+			if _, _, _, err := generateCandidates(context.Background(), sem, "gemini-test", model, model.StartChat(), genai.Text("hello"), tt.candidates); err == nil {
+				t.Fatal("generateCandidates succeeded, want the fake server's error")
+			}
+			if got := int(total.Load()); got != tt.candidates {
+				t.Errorf("requests = %d, want %d", got, tt.candidates)
+			}
+			if got := int(peak.Load()); got > tt.maxConcurrent {
+				t.Errorf("peak in-flight requests = %d, want at most %d", got, tt.maxConcurrent)
+			}
+		})
+	}
+}
+
+func TestCandidateText(t *testing.T) {
+	tests := []struct {
+		name string
+		cand *genai.Candidate
+		want string
+	}{
+		{"候補なし", nil, ""},
+		{"Content なし (ブロックされた応答)", &genai.Candidate{FinishReason: genai.FinishReasonSafety}, ""},
+		{"パートが空", &genai.Candidate{Content: &genai.Content{Role: "model"}}, ""},
+		{"テキスト以外のパートのみ", &genai.Candidate{Content: &genai.Content{Parts: []genai.Part{
+			genai.Blob{MIMEType: "image/png", Data: []byte{0x89, 'P', 'N', 'G'}},
+		}}}, ""},
+		{"関数呼び出しのみ", &genai.Candidate{Content: &genai.Content{Parts: []genai.Part{
+			genai.FunctionCall{Name: "lookup"},
+		}}}, ""},
+		{"テキストが1つ", &genai.Candidate{Content: &genai.Content{Parts: []genai.Part{
+			genai.Text("こんにちは"),
+		}}}, "こんにちは"},
+		{"複数のテキストを連結", &genai.Candidate{Content: &genai.Content{Parts: []genai.Part{
+			genai.Text("こんにちは、"), genai.Text("元気です！"),
+		}}}, "こんにちは、元気です！"},
+		{"テキスト以外のパートは無視", &genai.Candidate{Content: &genai.Content{Parts: []genai.Part{
+			genai.Text("前半"), genai.Blob{MIMEType: "image/png"}, genai.Text("後半"),
+		}}}, "前半後半"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := candidateText(tt.cand); got != tt.want {
+				t.Errorf("candidateText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRetryWaitReleasesSlot は、レート制限で再試行を待っている間は同時リクエスト数の枠を手放し、
+// 他のリクエストが待たされないことを確認します。
+func TestRetryWaitReleasesSlot(t *testing.T) {
+	var rateLimited atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		// "slow" の最初のリクエストだけレート制限し、1 秒後の再試行を指示する
+		if strings.Contains(string(body), "slow") && rateLimited.CompareAndSwap(false, true) {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":{"code":429,"message":"rate limited","status":"RESOURCE_EXHAUSTED"}}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":400,"message":"invalid argument","status":"INVALID_ARGUMENT"}}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("test-key"), option.WithEndpoint(srv.URL))
+	if err != nil {
+		t.Fatalf("genai.NewClient: %v", err)
+	}
+	defer client.Close()
+	model := client.GenerativeModel("gemini-test")
+	sem := make(chan struct{}, 1)
+
+	slowDone := make(chan time.Duration, 1)
+	start := time.Now()
+	go func() {
+		streamWithRetry(ctx, sem, "gemini-test", model.StartChat(), genai.Text("slow"))
+		slowDone <- time.Since(start)
+	}()
+
+	// 最初のリクエストがレート制限され、再試行の待機に入るまで待つ
+	for !rateLimited.Load() {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if _, _, err := streamWithRetry(ctx, sem, "gemini-test", model.StartChat(), genai.Text("fast")); err == nil {
+		t.Fatal("streamWithRetry succeeded, want the fake server's error")
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("second request finished after %v, want it to run while the first waits to retry (under 1s)", elapsed)
+	}
+	if elapsed := <-slowDone; elapsed < time.Second {
+		t.Errorf("rate-limited request finished after %v, want it to honor Retry-After (1s)", elapsed)
+	}
+}
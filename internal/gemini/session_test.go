@@ -0,0 +1,212 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"prompter-live-go/internal/types"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// generateRequest は streamGenerateContent のリクエストの本文のうち、テストで確認する部分です。
+type generateRequest struct {
+	Contents []struct {
+		Role  string `json:"role"`
+		Parts []struct {
+			Text string `json:"text"`
+		} `json:"parts"`
+	} `json:"contents"`
+	SystemInstruction *struct {
+		Parts []struct {
+			Text string `json:"text"`
+		} `json:"parts"`
+	} `json:"systemInstruction"`
+}
+
+// turns は会話履歴を "role: text" の形で返します。
+func (r generateRequest) turns() []string {
+	var turns []string
+	for _, c := range r.Contents {
+		var text []string
+		for _, p := range c.Parts {
+			text = append(text, p.Text)
+		}
+		turns = append(turns, c.Role+": "+strings.Join(text, ""))
+	}
+	return turns
+}
+
+// recordingServer はリクエストの本文を記録し、再試行の対象外のエラー (400) を返すテスト用サーバーです。
+type recordingServer struct {
+	mu       sync.Mutex
+	requests []generateRequest
+}
+
+func (s *recordingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var req generateRequest
+	json.Unmarshal(body, &req)
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write([]byte(`{"error":{"code":400,"message":"invalid argument","status":"INVALID_ARGUMENT"}}`))
+}
+
+func (s *recordingServer) recorded() []generateRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]generateRequest(nil), s.requests...)
+}
+
+// TestFirstCommentGetsItsOwnResponse は、システム指示の適用に送受信の往復を使わず、
+// 最初の実際のコメントへの応答がシステム指示の了承に消費されないことを確認します。
+func TestFirstCommentGetsItsOwnResponse(t *testing.T) {
+	const instruction = "You are a cheerful stream assistant."
+	tests := []struct {
+		name       string
+		config     types.LiveAPIConfig
+		wantSystem bool
+		wantTurns  []string
+	}{
+		{
+			name:       "native system instruction",
+			config:     types.LiveAPIConfig{},
+			wantSystem: true,
+			wantTurns:  []string{"user: first comment"},
+		},
+		{
+			name:      "legacy system prompt",
+			config:    types.LiveAPIConfig{LegacySystemPrompt: true},
+			wantTurns: []string{"user: " + instruction, "model: " + types.DefaultLegacySystemPromptAck, "user: first comment"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &recordingServer{}
+			srv := httptest.NewServer(server)
+			defer srv.Close()
+
+			ctx := context.Background()
+			client, err := NewClient(ctx, "test-key", Endpoint{URL: srv.URL}, "gemini-test", instruction, 1)
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			defer client.Close()
+			session, err := client.StartSession(ctx, tt.config)
+			if err != nil {
+				t.Fatalf("StartSession: %v", err)
+			}
+
+			// セッションの開始時にシステム指示を送信しない
+			if n := len(server.recorded()); n != 0 {
+				t.Fatalf("%d request(s) sent before the first comment, want 0", n)
+			}
+
+			// コメントごとに、そのコメントへの応答が 1 件ずつ届く (fake サーバーはエラーを返すため Err が設定される)
+			for i, text := range []string{"first comment", "second comment"} {
+				if err := session.Send(ctx, types.LiveStreamData{Text: text}); err != nil {
+					t.Fatalf("Send(%q): %v", text, err)
+				}
+				resp, err := session.RecvResponse()
+				if err != nil {
+					t.Fatalf("RecvResponse for %q: %v (the reply slot was consumed)", text, err)
+				}
+				if resp.Err == nil || !resp.Done {
+					t.Errorf("RecvResponse for %q = %+v, want the server's error for this request", text, resp)
+				}
+				if n := len(server.recorded()); n != i+1 {
+					t.Errorf("requests after %q = %d, want %d", text, n, i+1)
+				}
+			}
+
+			first := server.recorded()[0]
+			if got := strings.Join(first.turns(), " | "); got != strings.Join(tt.wantTurns, " | ") {
+				t.Errorf("first request contents = %s, want %s", got, strings.Join(tt.wantTurns, " | "))
+			}
+			if gotSystem := first.SystemInstruction != nil && len(first.SystemInstruction.Parts) > 0 && first.SystemInstruction.Parts[0].Text == instruction; gotSystem != tt.wantSystem {
+				t.Errorf("first request has the native system instruction = %v, want %v", gotSystem, tt.wantSystem)
+			}
+		})
+	}
+}
+
+// historyTurns は会話履歴を "role: text" の形で返します。
+func historyTurns(history []*genai.Content) []string {
+	var turns []string
+	for _, c := range history {
+		var text []string
+		for _, p := range c.Parts {
+			text = append(text, fmt.Sprint(p))
+		}
+		turns = append(turns, c.Role+": "+strings.Join(text, ""))
+	}
+	return turns
+}
+
+func TestSystemInstructionHistory(t *testing.T) {
+	const instruction = "You are a cheerful stream assistant."
+	tests := []struct {
+		name       string
+		config     types.LiveAPIConfig
+		wantTurns  []string
+		wantSystem bool
+	}{
+		{
+			name:       "native system instruction has no warm-up turn",
+			config:     types.LiveAPIConfig{},
+			wantTurns:  nil,
+			wantSystem: true,
+		},
+		{
+			name:       "native mode ignores the ack text",
+			config:     types.LiveAPIConfig{LegacySystemPromptAck: "了解です！"},
+			wantTurns:  nil,
+			wantSystem: true,
+		},
+		{
+			name:      "legacy mode with the default ack",
+			config:    types.LiveAPIConfig{LegacySystemPrompt: true},
+			wantTurns: []string{"user: " + instruction, "model: " + types.DefaultLegacySystemPromptAck},
+		},
+		{
+			name:      "legacy mode with a custom ack",
+			config:    types.LiveAPIConfig{LegacySystemPrompt: true, LegacySystemPromptAck: "了解です！"},
+			wantTurns: []string{"user: " + instruction, "model: 了解です！"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := newFakeGeminiModel(t, func() {})
+			session := newGeminiLiveSession(model, tt.config, instruction, make(chan struct{}, 1))
+			defer session.Close()
+
+			got := historyTurns(session.chatSession.History)
+			if strings.Join(got, " | ") != strings.Join(tt.wantTurns, " | ") {
+				t.Errorf("initial history = %q, want exactly %q", got, tt.wantTurns)
+			}
+			if gotSystem := model.SystemInstruction != nil; gotSystem != tt.wantSystem {
+				t.Errorf("native system instruction set = %v, want %v", gotSystem, tt.wantSystem)
+			}
+		})
+	}
+}
+
+func TestSystemInstructionHistoryWithoutInstruction(t *testing.T) {
+	model := newFakeGeminiModel(t, func() {})
+	session := newGeminiLiveSession(model, types.LiveAPIConfig{LegacySystemPrompt: true, LegacySystemPromptAck: "了解です！"}, "", make(chan struct{}, 1))
+	defer session.Close()
+	if n := len(session.chatSession.History); n != 0 {
+		t.Errorf("initial history has %d turns without a system instruction, want 0", n)
+	}
+}
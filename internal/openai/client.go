@@ -0,0 +1,211 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"prompter-live-go/internal/types"
+	"prompter-live-go/internal/version"
+)
+
+// DefaultBaseURL は OpenAI 公式 API のベース URL です。
+const DefaultBaseURL = "https://api.openai.com/v1"
+
+// maxHistoryMessages は会話履歴として保持するメッセージ (user/assistant) の最大数です。
+// Gemini の ChatSession と同様に会話の文脈を維持しつつ、プロンプトの肥大化を防ぎます。
+const maxHistoryMessages = 20
+
+// requestTimeout は chat completions API 呼び出しのタイムアウトです。
+const requestTimeout = 60 * time.Second
+
+// chatMessage は chat completions API のメッセージです。
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatRequest は chat completions API のリクエストボディです。
+type chatRequest struct {
+	Model          string          `json:"model"`
+	Messages       []chatMessage   `json:"messages"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+	N              int             `json:"n,omitempty"`
+	Temperature    *float32        `json:"temperature,omitempty"`
+}
+
+// responseFormat は chat completions API の応答形式の指定です。
+type responseFormat struct {
+	Type string `json:"type"`
+}
+
+// chatResponse は chat completions API のレスポンスボディのうち、使用するフィールドです。
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int32 `json:"prompt_tokens"`
+		CompletionTokens int32 `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// Client は OpenAI 互換の chat completions API を使用して応答を生成します。
+// pipeline.Responder インターフェースを満たします。
+type Client struct {
+	baseURL           string
+	apiKey            string
+	model             string
+	systemInstruction string
+	httpClient        *http.Client
+	// jsonMode が true の場合、JSON オブジェクトのみで応答させます (構造化アクションモード)。
+	jsonMode bool
+	// candidateCount は 1 回の応答で生成する候補の数 (n) です。
+	candidateCount int
+	// temperature は生成の温度です。nil の場合は API の既定値を使用します。
+	temperature *float32
+
+	// history は直近の会話履歴です (システム指示は含まない)。
+	history []chatMessage
+	mu      sync.Mutex
+}
+
+// NewClient は新しい OpenAI 互換クライアントを作成します。
+// baseURL が空の場合は OpenAI 公式 API を使用します。
+func NewClient(baseURL string, apiKey string, model string, systemInstruction string) (*Client, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("openai-compatible API key is required")
+	}
+	if model == "" {
+		return nil, fmt.Errorf("openai-compatible model name is required")
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	log.Printf("OpenAI-compatible Client initialized with model: %s (endpoint: %s)", model, baseURL)
+
+	return &Client{
+		baseURL:           strings.TrimRight(baseURL, "/"),
+		apiKey:            apiKey,
+		model:             model,
+		systemInstruction: systemInstruction,
+		httpClient:        &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// SetJSONMode は応答を JSON オブジェクトに制約するかどうかを設定します。
+func (c *Client) SetJSONMode(enabled bool) {
+	c.jsonMode = enabled
+}
+
+// SetCandidateCount は 1 回の応答で生成する候補の数を設定します (1 未満の場合は 1)。
+func (c *Client) SetCandidateCount(n int) {
+	c.candidateCount = max(n, 1)
+}
+
+// SetTemperature は生成の温度を設定します。nil の場合は API の既定値を使用します。
+func (c *Client) SetTemperature(t *float32) {
+	c.temperature = t
+}
+
+// GenerateResponse は data.Text をユーザーメッセージとして送信し、完全な応答を返します。
+// システム指示はネイティブな system メッセージとして毎回先頭に付与されます。
+func (c *Client) GenerateResponse(ctx context.Context, data types.LiveStreamData) (*types.LowLatencyResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	userMessage := chatMessage{Role: "user", Content: data.Text}
+
+	// 1. リクエストメッセージを構築 (system + 履歴 + 今回のメッセージ)
+	messages := make([]chatMessage, 0, len(c.history)+2)
+	if c.systemInstruction != "" {
+		messages = append(messages, chatMessage{Role: "system", Content: c.systemInstruction})
+	}
+	messages = append(messages, c.history...)
+	messages = append(messages, userMessage)
+
+	request := chatRequest{Model: c.model, Messages: messages, Temperature: c.temperature}
+	if c.jsonMode {
+		request.ResponseFormat = &responseFormat{Type: "json_object"}
+	}
+	if c.candidateCount > 1 {
+		request.N = c.candidateCount
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode chat request: %w", err)
+	}
+
+	// 2. API を呼び出し
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	httpResp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("chat completions request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chat completions response: %w", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("chat completions API returned %s: %s", httpResp.Status, truncate(string(respBody), 300))
+	}
+
+	// 3. レスポンスを解析
+	var parsed chatResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to decode chat completions response: %w", err)
+	}
+
+	text := ""
+	if len(parsed.Choices) > 0 {
+		text = parsed.Choices[0].Message.Content
+	}
+	var candidates []string
+	if len(parsed.Choices) > 1 {
+		for _, choice := range parsed.Choices {
+			candidates = append(candidates, choice.Message.Content)
+		}
+	}
+
+	// 4. 会話履歴を更新 (上限を超えた古いメッセージは破棄)
+	if text != "" {
+		c.history = append(c.history, userMessage, chatMessage{Role: "assistant", Content: text})
+		if len(c.history) > maxHistoryMessages {
+			c.history = c.history[len(c.history)-maxHistoryMessages:]
+		}
+	}
+
+	return &types.LowLatencyResponse{
+		ResponseText:   text,
+		Done:           true,
+		PromptTokens:   parsed.Usage.PromptTokens,
+		ResponseTokens: parsed.Usage.CompletionTokens,
+		Candidates:     candidates,
+	}, nil
+}
+
+// truncate はエラーメッセージ用に文字列を最大 n 文字に切り詰めます。
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
@@ -0,0 +1,68 @@
+package overlay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// State はオーバーレイファイルに書き出す最新の応答です。
+type State struct {
+	Author    string    `json:"author"`
+	Reply     string    `json:"reply"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Writer は最新の応答を OBS のテキスト/ブラウザソースから読み込める JSON ファイルに書き出します (--overlay-file)。
+// 書き込み途中のファイルを OBS が読み込まないよう、一時ファイルに書き込んでからリネームで置き換えます。
+type Writer struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewWriter は新しい Writer を作成します。保存先のディレクトリが存在しない場合は作成します。
+func NewWriter(path string) (*Writer, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create overlay directory: %w", err)
+		}
+	}
+	return &Writer{path: path}, nil
+}
+
+// Update はオーバーレイファイルを最新の応答で上書きします。
+func (w *Writer) Update(author, reply string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b, err := json.Marshal(State{Author: author, Reply: reply, UpdatedAt: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to encode overlay: %w", err)
+	}
+
+	// リネームがアトミックになるよう、一時ファイルは同じディレクトリに作成する
+	tmp, err := os.CreateTemp(filepath.Dir(w.path), "."+filepath.Base(w.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create overlay temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // リネーム成功後は存在しないため何もしない
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write overlay temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close overlay temp file: %w", err)
+	}
+	// CreateTemp は 0600 で作成するため、OBS など他のプロセスから読めるようにする
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to set overlay file permissions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), w.path); err != nil {
+		return fmt.Errorf("failed to replace overlay file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package persona
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultDir はペルソナ定義を置く既定のディレクトリです (設定ディレクトリからの相対パス)。
+const DefaultDir = "personas"
+
+// fileExt はペルソナ定義ファイルの拡張子です。
+const fileExt = ".json"
+
+// namePattern はペルソナ名として使用できる文字です (ディレクトリの外のファイルを指定できないようにする)。
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Persona は 1 つのキャラクターとして一緒に使用する設定の組です。
+// personas/<名前>.json に JSON で定義します。省略した項目はフラグの値 (または既定値) のままです。
+type Persona struct {
+	// Name はファイル名から決まるペルソナ名です (ファイルには書きません)。
+	Name string `json:"-"`
+	// Description は persona list で表示する説明です。
+	Description string `json:"description"`
+	// Instruction はシステム指示です (--instruction に相当)。
+	Instruction string `json:"instruction"`
+	// Temperature は生成の温度です (--temperature に相当)。nil の場合は指定しません。
+	Temperature *float64 `json:"temperature"`
+	// StyleVariants は応答ごとに無作為に選ぶスタイル指示です (--style-variants-file に相当)。
+	StyleVariants []string `json:"style_variants"`
+	// EmojiPolicy は絵文字の方針です (--emoji-policy に相当)。
+	EmojiPolicy string `json:"emoji_policy"`
+}
+
+// Load は dir からペルソナ name の定義を読み込みます。
+func Load(dir, name string) (*Persona, error) {
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid persona name %q: use letters, digits, '-' and '_' only", name)
+	}
+	path := filepath.Join(dir, name+fileExt)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("persona %q not found (%s); run 'persona list' to see the available personas", name, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read persona %q: %w", name, err)
+	}
+
+	var p Persona
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse persona %s: %w", path, err)
+	}
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return nil, fmt.Errorf("persona %s: temperature must be between 0 and 2, got %v", path, *p.Temperature)
+	}
+	p.Name = name
+	return &p, nil
+}
+
+// List は dir にあるすべてのペルソナを名前順に読み込みます。ディレクトリがない場合は空の一覧を返します。
+func List(dir string) ([]*Persona, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read persona directory: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), fileExt)
+		if e.IsDir() || !ok || !namePattern.MatchString(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	personas := make([]*Persona, 0, len(names))
+	for _, name := range names {
+		p, err := Load(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		personas = append(personas, p)
+	}
+	return personas, nil
+}
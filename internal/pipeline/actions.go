@@ -0,0 +1,66 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// 構造化アクションモード (--structured-actions) でモデルが返すアクション
+const (
+	actionAnswer = "answer"
+	actionIgnore = "ignore"
+)
+
+// actionInstruction は構造化アクションモードでプロンプトに付与する、応答形式の説明です。
+const actionInstruction = `Reply with a JSON object only: {"action":"answer","text":"<your reply>"} to respond, or {"action":"ignore"} if the comment does not need a reply.`
+
+// botAction は構造化アクションモードでのモデルの応答です。
+type botAction struct {
+	Action string `json:"action"`
+	Text   string `json:"text"`
+}
+
+// withActionInstruction は構造化アクションモードの場合に、プロンプトへ応答形式の説明を付与します。
+func (p *LowLatencyPipeline) withActionInstruction(prompt string) string {
+	if !p.geminiConfig.StructuredActions {
+		return prompt
+	}
+	return prompt + "\n" + actionInstruction
+}
+
+// resolveAction は構造化アクションの応答から投稿するテキストを取り出します。
+// 投稿しない場合は統計に記録したうえで ok に false を返します。
+func (p *LowLatencyPipeline) resolveAction(raw string) (text string, ok bool) {
+	text, ok, err := parseAction(raw)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		p.recorder.RecordError()
+		return "", false
+	}
+	if !ok {
+		p.recorder.RecordSkip(skipModelDeclined)
+	}
+	return text, ok
+}
+
+// parseAction はモデルの JSON 応答を解析し、投稿するテキストを返します。
+// モデルが応答を見送った (action が "answer" 以外、またはテキストが空) 場合は ok が false になります。
+func parseAction(raw string) (text string, ok bool, err error) {
+	// JSON モードでもコードブロックで囲まれて返ることがあるため取り除く
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimPrefix(raw, "```json")
+	raw = strings.TrimPrefix(raw, "```")
+	raw = strings.TrimSuffix(raw, "```")
+
+	var action botAction
+	if err := json.Unmarshal([]byte(raw), &action); err != nil {
+		return "", false, fmt.Errorf("failed to parse structured action %q: %w", raw, err)
+	}
+	if action.Action != actionAnswer {
+		return "", false, nil
+	}
+	text = strings.TrimSpace(action.Text)
+	return text, text != "", nil
+}
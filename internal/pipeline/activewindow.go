@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"prompter-live-go/internal/types"
+)
+
+// ParseActiveWindow は "HH:MM-HH:MM" 形式の時間帯 (--active-window) を解析します。
+// 終了時刻が開始時刻より前の場合 ("22:00-02:00") は日付をまたぐ時間帯として扱います。
+func ParseActiveWindow(s string) (*types.ActiveWindow, error) {
+	startText, endText, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid active window %q: expected HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(startText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid active window %q: %w", s, err)
+	}
+	end, err := parseClock(endText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid active window %q: %w", s, err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("invalid active window %q: start and end must differ", s)
+	}
+	return &types.ActiveWindow{Start: start, End: end}, nil
+}
+
+// parseClock は "HH:MM" を 0 時からの経過時間に変換します。
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// windowContains は now (ローカル時刻) が時間帯に含まれるかどうかを返します。
+func windowContains(w *types.ActiveWindow, now time.Time) bool {
+	offset := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute + time.Duration(now.Second())*time.Second
+	if w.Start < w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	// 日付をまたぐ時間帯
+	return offset >= w.Start || offset < w.End
+}
+
+// activeWindow は応答を生成・投稿する期間 (--active-after / --active-window) を判定し、
+// 期間の内外が切り替わったときだけログに出力します。
+type activeWindow struct {
+	// startedAt はパイプラインの開始時刻です (--active-after の基準)。
+	startedAt time.Time
+	// known は状態を一度でも判定したかどうか、active は直近の判定結果です。
+	known  bool
+	active bool
+}
+
+// isActive は now が応答を生成・投稿する期間内かどうかを返します。
+// 期間外でもコメントの取得 (重複排除) は続け、応答だけを見合わせます。
+func (p *LowLatencyPipeline) isActive(now time.Time) bool {
+	after := p.pipelineConfig.ActiveAfter
+	window := p.pipelineConfig.ActiveWindow
+	if after <= 0 && window == nil {
+		return true
+	}
+
+	active := true
+	reason := ""
+	if after > 0 && now.Sub(p.activeWindow.startedAt) < after {
+		active = false
+		reason = fmt.Sprintf("until %v after start (--active-after)", after)
+	} else if window != nil && !windowContains(window, now) {
+		active = false
+		reason = fmt.Sprintf("outside %s (--active-window)", window)
+	}
+
+	if !p.activeWindow.known || p.activeWindow.active != active {
+		if active {
+			log.Println("Active window started. Replies are enabled.")
+		} else {
+			log.Printf("Outside the active window: comments are still fetched, but no replies will be posted %s.", reason)
+		}
+		p.activeWindow.known = true
+		p.activeWindow.active = active
+	}
+	return active
+}
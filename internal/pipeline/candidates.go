@@ -0,0 +1,48 @@
+package pipeline
+
+import (
+	"log"
+	"unicode/utf8"
+
+	"prompter-live-go/internal/types"
+)
+
+// selectCandidate は複数の応答候補 (--candidate-count) から、--candidate-strategy に従って投稿する候補を選びます。
+// 候補が 1 件以下の場合は ResponseText をそのまま返します。
+func (p *LowLatencyPipeline) selectCandidate(resp *types.LowLatencyResponse) string {
+	candidates := resp.Candidates
+	if len(candidates) <= 1 {
+		return resp.ResponseText
+	}
+
+	switch p.pipelineConfig.CandidateStrategy {
+	case types.CandidateRandom:
+		return candidates[p.rng.Intn(len(candidates))]
+	case types.CandidateShortestFit:
+		return p.shortestFittingCandidate(candidates)
+	default:
+		return candidates[0]
+	}
+}
+
+// shortestFittingCandidate は整形後に YouTube の文字数の上限 (maxReplyLength) に収まる候補のうち最も短いものを返します。
+// 切り詰めを避けるための選択で、収まる候補がない場合は最も短い候補を返します。空の候補は選びません。
+func (p *LowLatencyPipeline) shortestFittingCandidate(candidates []string) string {
+	best, bestLen := candidates[0], -1
+	bestFits := false
+	for _, c := range candidates {
+		n := utf8.RuneCountInString(sanitizeReply(c, p.metaPatterns()))
+		if n == 0 {
+			continue
+		}
+		fits := n <= maxReplyLength
+		// 収まる候補を収まらない候補より優先し、同じ条件なら短い方を選ぶ
+		if bestLen < 0 || (fits && !bestFits) || (fits == bestFits && n < bestLen) {
+			best, bestLen, bestFits = c, n, fits
+		}
+	}
+	if bestLen >= 0 && !bestFits {
+		log.Printf("None of the %d reply candidates fits in %d characters; using the shortest (%d characters).", len(candidates), maxReplyLength, bestLen)
+	}
+	return best
+}
@@ -0,0 +1,61 @@
+package pipeline
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"prompter-live-go/internal/youtube"
+)
+
+// botCommandPrefix はモデレーター向けチャットコマンドの接頭辞です (例: "!bot pause")。
+const botCommandPrefix = "!bot"
+
+// モデレーター向けチャットコマンド
+const (
+	commandPause  = "pause"
+	commandResume = "resume"
+	commandRecap  = "recap"
+)
+
+// parseBotCommand はコメントがボット向けのチャットコマンドであれば、そのコマンド名 (小文字) を返します。
+// コマンドでない場合は空文字列を返します。
+func parseBotCommand(message string) string {
+	fields := strings.Fields(strings.ToLower(message))
+	if len(fields) < 2 || fields[0] != botCommandPrefix {
+		return ""
+	}
+	return fields[1]
+}
+
+// handleBotCommand はチャット所有者・モデレーターからのコマンドを処理します。
+// コメントがコマンドとして処理された (応答対象ではない) 場合は true を返します。
+func (p *LowLatencyPipeline) handleBotCommand(ctx context.Context, comment youtube.Comment) bool {
+	command := parseBotCommand(comment.Message)
+	if command == "" {
+		return false
+	}
+	if !comment.IsOwner && !comment.IsModerator {
+		log.Printf("Ignoring bot command %q from %s (not the owner or a moderator).", command, comment.Author)
+		return true
+	}
+
+	switch command {
+	case commandPause:
+		if !p.paused {
+			p.paused = true
+			log.Printf("Bot paused by %s. Comments are still fetched, but no replies will be generated until '!bot resume'.", comment.Author)
+		}
+	case commandResume:
+		if p.paused {
+			p.paused = false
+			log.Printf("Bot resumed by %s.", comment.Author)
+		}
+	case commandRecap:
+		log.Printf("Recap requested by %s.", comment.Author)
+		p.PostRecap(ctx)
+	default:
+		log.Printf("Unknown bot command %q from %s.", command, comment.Author)
+	}
+	return true
+}
@@ -0,0 +1,24 @@
+package pipeline
+
+import (
+	"time"
+
+	"prompter-live-go/internal/util"
+	"prompter-live-go/internal/youtube"
+)
+
+// clockSkewThreshold はコメントの投稿時刻がローカル時刻よりこれ以上未来の場合に、時計のずれとしてデバッグログに出力する閾値です。
+const clockSkewThreshold = 5 * time.Second
+
+// commentAge は now 時点でのコメントの経過時間を返します。
+// API とローカルの時計のずれで投稿時刻が未来になっている場合は、now に投稿されたものとして 0 を返します。
+func commentAge(comment youtube.Comment, now time.Time) time.Duration {
+	age := now.Sub(comment.Timestamp)
+	if age >= 0 {
+		return age
+	}
+	if -age > clockSkewThreshold {
+		util.Debugf("Comment %s from %s is timestamped %v in the future; the local clock may be behind. Treating it as just posted.", comment.ID, comment.Author, (-age).Truncate(time.Millisecond))
+	}
+	return 0
+}
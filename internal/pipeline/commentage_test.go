@@ -0,0 +1,39 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"prompter-live-go/internal/youtube"
+)
+
+func TestCommentAge(t *testing.T) {
+	now := time.Date(2025, 1, 1, 20, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		want      time.Duration
+	}{
+		{name: "one minute in the future is clamped", timestamp: now.Add(time.Minute), want: 0},
+		{name: "within the skew threshold is clamped", timestamp: now.Add(clockSkewThreshold / 2), want: 0},
+		{name: "posted now", timestamp: now, want: 0},
+		{name: "posted in the past", timestamp: now.Add(-30 * time.Second), want: 30 * time.Second},
+		{name: "posted an hour ago", timestamp: now.Add(-time.Hour), want: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comment := youtube.Comment{ID: "msg-1", Author: "Alice", Timestamp: tt.timestamp}
+			if got := commentAge(comment, now); got != tt.want {
+				t.Errorf("commentAge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentAgeZeroTimestamp(t *testing.T) {
+	// 投稿時刻がゼロ値のコメントは非常に古いものとして扱われる (--max-comment-age で除外される)
+	age := commentAge(youtube.Comment{ID: "msg-1"}, time.Now())
+	if age < 24*time.Hour*365 {
+		t.Errorf("commentAge() with a zero timestamp = %v, want a very old age", age)
+	}
+}
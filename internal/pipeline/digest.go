@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"prompter-live-go/internal/types"
+	"prompter-live-go/internal/youtube"
+)
+
+// maxDigestComments は 1 回のダイジェストに含めるコメントの最大数です。
+// これを超えた古いコメントは破棄し、プロンプトの肥大化を防ぎます。
+const maxDigestComments = 100
+
+// digestBuffer はダイジェストモードで次の投稿までに受信したコメントを保持します。
+type digestBuffer struct {
+	comments []youtube.Comment
+	dropped  int
+}
+
+// add はコメントをバッファに追加します。上限を超えた場合は最も古いコメントを破棄します。
+func (b *digestBuffer) add(comment youtube.Comment) {
+	b.comments = append(b.comments, comment)
+	if len(b.comments) > maxDigestComments {
+		b.dropped += len(b.comments) - maxDigestComments
+		b.comments = b.comments[len(b.comments)-maxDigestComments:]
+	}
+}
+
+// take はバッファ内のコメントを取り出し、バッファを空にします。
+func (b *digestBuffer) take() ([]youtube.Comment, int) {
+	comments, dropped := b.comments, b.dropped
+	b.comments, b.dropped = nil, 0
+	return comments, dropped
+}
+
+// flushDigest はバッファに溜まったコメントをまとめて AI に送信し、1 件の応答として投稿します。
+func (p *LowLatencyPipeline) flushDigest(ctx context.Context) {
+	comments, dropped := p.digest.take()
+	if len(comments) == 0 {
+		return
+	}
+	if p.paused {
+		log.Printf("Bot is paused. Discarding %d buffered digest comments.", len(comments))
+		return
+	}
+	if !p.isActive(time.Now()) {
+		log.Printf("Outside the active window. Discarding %d buffered digest comments.", len(comments))
+		p.recorder.RecordSkip(skipInactive)
+		return
+	}
+	if p.restriction.postingPaused(time.Now()) {
+		log.Printf("Posting is restricted. Discarding %d buffered digest comments.", len(comments))
+		p.recorder.RecordSkip(skipChatRestricted)
+		return
+	}
+	if dropped > 0 {
+		log.Printf("Digest buffer overflowed; %d older comments were dropped.", dropped)
+	}
+
+	log.Printf("Generating digest reply for %d comments.", len(comments))
+	resp, err := p.responder.GenerateResponse(ctx, types.LiveStreamData{
+		Text:   p.withActionInstruction(p.withEmojiInstruction(p.withSentenceLimit(withStyleHint(buildDigestPrompt(comments, p.pipelineConfig.ResponseLanguage, !p.pipelineConfig.OmitAuthor), p.pickStyleVariant())))),
+		Author: "digest",
+	})
+	if err != nil {
+		log.Printf("Error generating digest response: %v", err)
+		p.recorder.RecordError()
+		return
+	}
+	p.recorder.RecordTokens(resp.PromptTokens, resp.ResponseTokens)
+
+	if resp.ResponseText == "" {
+		p.recorder.RecordSkip(skipEmptyResponse)
+		return
+	}
+	if p.geminiConfig.StructuredActions {
+		text, ok := p.resolveAction(resp.ResponseText)
+		if !ok {
+			log.Println("Model declined to post a digest reply.")
+			return
+		}
+		resp.ResponseText = text
+	}
+	if resp.ResponseText = p.finishReply(resp.ResponseText, maxReplyLength); resp.ResponseText == "" {
+		p.recorder.RecordSkip(skipEmptyResponse)
+		return
+	}
+	p.postReply(ctx, "digest", "digest", resp.ResponseText)
+}
+
+// flushDigestOnExit は終了時にバッファに残っているコメントへのダイジェスト応答を投稿します。
+// 実行中のコンテキストは既にキャンセルされているため、recapExitTimeout を上限とする別のコンテキストを使用します。
+func (p *LowLatencyPipeline) flushDigestOnExit() {
+	if len(p.digest.comments) == 0 {
+		return
+	}
+	if p.youtubeClient != nil && !p.youtubeClient.HasActiveChat() && !p.pipelineConfig.NoPost {
+		log.Printf("Live chat is no longer active. Discarding %d buffered digest comments.", len(p.digest.comments))
+		return
+	}
+	log.Printf("Flushing %d buffered digest comments before shutdown.", len(p.digest.comments))
+	ctx, cancel := context.WithTimeout(context.Background(), recapExitTimeout)
+	defer cancel()
+	p.flushDigest(ctx)
+}
+
+// buildDigestPrompt はバッファ内のコメントから、まとめて応答させるためのプロンプトを組み立てます。
+// language が空でない場合は、その言語で応答するよう指示を付与します。
+// includeAuthor が false の場合は投稿者名を含めません。
+func buildDigestPrompt(comments []youtube.Comment, language string, includeAuthor bool) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Here are the %d live chat comments received since your last reply:\n", len(comments))
+	for _, c := range comments {
+		if includeAuthor {
+			fmt.Fprintf(&sb, "- [%s] %s: %s\n", c.Timestamp.Format(time.TimeOnly), c.Author, c.Message)
+		} else {
+			fmt.Fprintf(&sb, "- [%s] %s\n", c.Timestamp.Format(time.TimeOnly), c.Message)
+		}
+	}
+	sb.WriteString("Write a single consolidated reply that briefly summarizes the discussion and responds to the main questions and points.")
+	if language != "" {
+		fmt.Fprintf(&sb, "\n(Respond in %s.)", language)
+	}
+	return sb.String()
+}
@@ -0,0 +1,141 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+
+	"prompter-live-go/internal/types"
+)
+
+// requiredEmojiFallback は EmojiRequire で応答に絵文字が含まれなかった場合に末尾に付ける絵文字です。
+const requiredEmojiFallback = "😊"
+
+// 絵文字のシーケンスを構成する (単独では絵文字ではない) 文字
+const (
+	zeroWidthJoiner   = '\u200D'
+	variationText     = '\uFE0E'
+	variationEmoji    = '\uFE0F'
+	combiningKeycap   = '\u20E3'
+	tagFirst          = '\U000E0020'
+	tagLast           = '\U000E007F'
+	skinToneFirst     = '\U0001F3FB'
+	skinToneLast      = '\U0001F3FF'
+	regionalIndicator = '\U0001F1E6'
+)
+
+// isEmojiBase は r が絵文字のシーケンスの起点となる文字かどうかを判定します。
+func isEmojiBase(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // 絵記号、顔文字、交通、補助記号、国旗 (地域指示記号) など
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // その他の記号、装飾記号 (☀ ✨ ❤ など)
+		return true
+	case r >= 0x2300 && r <= 0x23FF: // ⌚ ⏰ ⏳ など
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF: // ⬆ ⭐ ⭕ など
+		return true
+	case r == 0x3030 || r == 0x303D || r == 0x3297 || r == 0x3299:
+		return true
+	}
+	return false
+}
+
+// isEmojiModifier は絵文字のシーケンスの途中・末尾に現れる修飾文字かどうかを判定します。
+func isEmojiModifier(r rune) bool {
+	return r == variationEmoji || r == variationText || r == combiningKeycap ||
+		(r >= skinToneFirst && r <= skinToneLast) || (r >= tagFirst && r <= tagLast)
+}
+
+// emojiSequenceEnd は runes[i] から始まる絵文字のシーケンス (肌の色・異体字セレクタ・ZWJ で結合された
+// 家族の絵文字、国旗、キーキャップなど) の終端の位置を返します。絵文字でない場合は i を返します。
+func emojiSequenceEnd(runes []rune, i int) int {
+	r := runes[i]
+	// キーキャップ (#️⃣ 1️⃣ など) は数字・記号に異体字セレクタと結合用キーキャップが続く
+	if strings.ContainsRune("0123456789#*", r) {
+		j := i + 1
+		if j < len(runes) && runes[j] == variationEmoji {
+			j++
+		}
+		if j < len(runes) && runes[j] == combiningKeycap {
+			return j + 1
+		}
+		return i
+	}
+	if !isEmojiBase(r) {
+		return i
+	}
+
+	end := i + 1
+	// 国旗は地域指示記号の 2 文字で 1 つの絵文字になる
+	if isRegionalIndicator(r) && end < len(runes) && isRegionalIndicator(runes[end]) {
+		end++
+	}
+	for end < len(runes) {
+		switch {
+		case isEmojiModifier(runes[end]):
+			end++
+		case runes[end] == zeroWidthJoiner && end+1 < len(runes) && isEmojiBase(runes[end+1]):
+			end += 2
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+// isRegionalIndicator は r が国旗を構成する地域指示記号かどうかを判定します。
+func isRegionalIndicator(r rune) bool {
+	return r >= regionalIndicator && r < regionalIndicator+26
+}
+
+// containsEmoji は text に絵文字が含まれるかどうかを判定します。
+func containsEmoji(text string) bool {
+	runes := []rune(text)
+	for i := range runes {
+		if emojiSequenceEnd(runes, i) > i {
+			return true
+		}
+	}
+	return false
+}
+
+// stripEmoji は text から絵文字のシーケンスを取り除き、取り除いた跡に残る連続した空白をまとめます。
+func stripEmoji(text string) string {
+	runes := []rune(text)
+	var sb strings.Builder
+	for i := 0; i < len(runes); {
+		if end := emojiSequenceEnd(runes, i); end > i {
+			i = end
+			continue
+		}
+		sb.WriteRune(runes[i])
+		i++
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// withEmojiInstruction は絵文字の方針 (--emoji-policy) に応じた指示をプロンプトに付与します。
+func (p *LowLatencyPipeline) withEmojiInstruction(prompt string) string {
+	switch p.pipelineConfig.EmojiPolicy {
+	case types.EmojiStrip:
+		return fmt.Sprintf("%s\n(Do not use emoji.)", prompt)
+	case types.EmojiRequire:
+		return fmt.Sprintf("%s\n(Include at least one emoji that fits the reply.)", prompt)
+	}
+	return prompt
+}
+
+// applyEmojiPolicy は絵文字の方針 (--emoji-policy) を応答に適用します。
+// EmojiStrip では絵文字を取り除き、EmojiRequire では絵文字が含まれない場合に max 文字以内に収まるよう末尾に付けます。
+func (p *LowLatencyPipeline) applyEmojiPolicy(text string, max int) string {
+	switch p.pipelineConfig.EmojiPolicy {
+	case types.EmojiStrip:
+		return stripEmoji(text)
+	case types.EmojiRequire:
+		if text == "" || containsEmoji(text) {
+			return text
+		}
+		return truncateReply(text, max-len([]rune(requiredEmojiFallback))-1) + " " + requiredEmojiFallback
+	}
+	return text
+}
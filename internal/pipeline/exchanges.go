@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"time"
+
+	"prompter-live-go/internal/youtube"
+)
+
+// exchangeState は 1 人の投稿者への連続した応答の回数と、最後に応答した時刻です。
+type exchangeState struct {
+	count       int
+	lastReplyAt time.Time
+}
+
+// exchangeTracker は投稿者ごとの連続した応答の回数を数えます (--max-exchanges-per-author)。
+// 時間ベースのクールダウンとは異なり、特定の視聴者との応答の応酬が続くことを回数で制限します。
+type exchangeTracker struct {
+	authors map[string]exchangeState
+}
+
+// limitReached は投稿者への連続した応答が max 回に達しており、最後の応答から resetGap が経過していなければ true を返します。
+// resetGap が経過した投稿者のエントリはここで削除します。
+func (t *exchangeTracker) limitReached(author string, now time.Time, max int, resetGap time.Duration) bool {
+	for a, s := range t.authors {
+		if now.Sub(s.lastReplyAt) > resetGap {
+			delete(t.authors, a)
+		}
+	}
+	return t.authors[author].count >= max
+}
+
+// record は投稿者への応答を記録します。
+func (t *exchangeTracker) record(author string, now time.Time) {
+	if t.authors == nil {
+		t.authors = make(map[string]exchangeState)
+	}
+	s := t.authors[author]
+	t.authors[author] = exchangeState{count: s.count + 1, lastReplyAt: now}
+}
+
+// exchangeKey は投稿者を識別するキーです。チャンネルIDがない場合 (テスト用のコメントソースなど) は表示名を使用します。
+func exchangeKey(comment youtube.Comment) string {
+	if comment.AuthorID != "" {
+		return comment.AuthorID
+	}
+	return comment.Author
+}
+
+// exchangeLimitReached は投稿者への連続した応答が --max-exchanges-per-author に達しているかどうかを判定します。
+func (p *LowLatencyPipeline) exchangeLimitReached(comment youtube.Comment) bool {
+	if p.pipelineConfig.MaxExchangesPerAuthor <= 0 {
+		return false
+	}
+	return p.exchanges.limitReached(exchangeKey(comment), time.Now(), p.pipelineConfig.MaxExchangesPerAuthor, p.pipelineConfig.ExchangeResetGap)
+}
+
+// recordExchange は投稿者への応答を記録します (--max-exchanges-per-author 指定時のみ)。
+func (p *LowLatencyPipeline) recordExchange(comment youtube.Comment) {
+	if p.pipelineConfig.MaxExchangesPerAuthor <= 0 {
+		return
+	}
+	p.exchanges.record(exchangeKey(comment), time.Now())
+}
@@ -0,0 +1,174 @@
+package pipeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"prompter-live-go/internal/types"
+	"prompter-live-go/internal/youtube"
+)
+
+// プロンプトに含める FAQ の選択に関する設定
+const (
+	// maxFAQEntries はプロンプトに含める FAQ の最大件数です (プロンプトを小さく保つため)。
+	maxFAQEntries = 3
+	// faqStrongMatch は質問の語句のうちコメントに含まれる割合がこの値以上の場合に、FAQ に明確に一致するとみなす閾値です。
+	faqStrongMatch = 0.5
+)
+
+// faqStopWords はキーワードの一致から除外する英語の頻出語です。
+var faqStopWords = map[string]bool{
+	"the": true, "is": true, "are": true, "a": true, "an": true, "to": true, "of": true, "in": true,
+	"do": true, "does": true, "you": true, "your": true, "what": true, "how": true, "and": true, "or": true,
+	"it": true, "on": true, "for": true, "can": true, "i": true, "my": true, "me": true, "be": true,
+}
+
+// LoadFAQFile は FAQ ファイル (--faq-file) を読み込みます。
+// 拡張子が .json の場合は [{"question": "...", "answer": "..."}] 形式の JSON、
+// それ以外は見出し行 (# で始まる行) を質問、続く本文を回答とする Markdown として解析します。
+func LoadFAQFile(path string) ([]types.FAQEntry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FAQ file: %w", err)
+	}
+
+	var entries []types.FAQEntry
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if err := json.Unmarshal(b, &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse FAQ JSON: %w", err)
+		}
+	} else {
+		entries = parseFAQMarkdown(string(b))
+	}
+
+	valid := entries[:0]
+	for _, e := range entries {
+		e.Question = strings.TrimSpace(e.Question)
+		e.Answer = strings.TrimSpace(e.Answer)
+		if e.Question != "" && e.Answer != "" {
+			valid = append(valid, e)
+		}
+	}
+	if len(valid) == 0 {
+		return nil, fmt.Errorf("FAQ file %s contains no question/answer pairs", path)
+	}
+	return valid, nil
+}
+
+// parseFAQMarkdown は見出し行を質問、次の見出しまでの本文を回答として Markdown を解析します。
+func parseFAQMarkdown(text string) []types.FAQEntry {
+	var entries []types.FAQEntry
+	var current *types.FAQEntry
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			entries = append(entries, types.FAQEntry{Question: strings.TrimLeft(line, "# ")})
+			current = &entries[len(entries)-1]
+			continue
+		}
+		if current != nil {
+			current.Answer += line + "\n"
+		}
+	}
+	return entries
+}
+
+// faqKeywords はキーワードの一致判定に使用する語句の集合を返します。
+// 英語などの単語は小文字化して頻出語を除き、空白で区切られない日本語・中国語は連続する 2 文字 (bigram) に分割します。
+func faqKeywords(text string) map[string]bool {
+	keywords := make(map[string]bool)
+	var word []rune
+	var prevCJK rune
+	flush := func() {
+		if w := string(word); len(word) >= 2 && !faqStopWords[w] {
+			keywords[w] = true
+		}
+		word = word[:0]
+	}
+	for _, r := range strings.ToLower(text) {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			flush()
+			if prevCJK != 0 {
+				keywords[string([]rune{prevCJK, r})] = true
+			}
+			prevCJK = r
+			continue
+		}
+		prevCJK = 0
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			word = append(word, r)
+		} else {
+			flush()
+		}
+	}
+	flush()
+	return keywords
+}
+
+// faqMatch は FAQ の 1 件とコメントとの一致度です。
+type faqMatch struct {
+	entry types.FAQEntry
+	// score は質問の語句のうちコメントに含まれる割合 (0〜1) です。
+	score float64
+}
+
+// matchFAQ はコメントとキーワードが一致する FAQ を、一致度の高い順に最大 maxFAQEntries 件返します。
+func matchFAQ(entries []types.FAQEntry, message string) []faqMatch {
+	commentKeywords := faqKeywords(message)
+	if len(commentKeywords) == 0 {
+		return nil
+	}
+
+	var matches []faqMatch
+	for _, e := range entries {
+		questionKeywords := faqKeywords(e.Question)
+		if len(questionKeywords) == 0 {
+			continue
+		}
+		shared := 0
+		for k := range questionKeywords {
+			if commentKeywords[k] {
+				shared++
+			}
+		}
+		if shared > 0 {
+			matches = append(matches, faqMatch{entry: e, score: float64(shared) / float64(len(questionKeywords))})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	if len(matches) > maxFAQEntries {
+		matches = matches[:maxFAQEntries]
+	}
+	return matches
+}
+
+// withFAQ は FAQ (--faq-file) のうちコメントに関連する項目を参考資料としてプロンプトに付与します。
+// コメントが FAQ の質問に明確に一致する場合は、その回答に基づいて応答するよう指示します。
+func (p *LowLatencyPipeline) withFAQ(prompt string, comment youtube.Comment) string {
+	if len(p.pipelineConfig.FAQ) == 0 || comment.Event != youtube.EventNone {
+		return prompt
+	}
+	matches := matchFAQ(p.pipelineConfig.FAQ, comment.Message)
+	if len(matches) == 0 {
+		return prompt
+	}
+
+	var sb strings.Builder
+	sb.WriteString(prompt)
+	if matches[0].score >= faqStrongMatch {
+		sb.WriteString("\n(This comment matches the first FAQ entry below. Base your answer on its answer.)")
+	} else {
+		sb.WriteString("\n(Reference FAQ. Use it only if it is relevant to the comment.)")
+	}
+	for _, m := range matches {
+		fmt.Fprintf(&sb, "\nQ: %s\nA: %s", m.entry.Question, m.entry.Answer)
+	}
+	return sb.String()
+}
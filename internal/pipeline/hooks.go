@@ -0,0 +1,53 @@
+package pipeline
+
+import (
+	"log"
+
+	"prompter-live-go/internal/youtube"
+)
+
+// PreGenerateHook は AI 応答の生成前に呼び出されるフックです。
+// 変更したコメント (プロンプトの書き換えなど) を返すか、skip に true を返して応答を取りやめます。
+type PreGenerateHook func(comment youtube.Comment) (modified youtube.Comment, skip bool)
+
+// PostGenerateHook は AI 応答の生成後、投稿前に呼び出されるフックです。
+// 変更した応答を返すか、skip に true を返して投稿を取りやめます (拒否)。
+type PostGenerateHook func(comment youtube.Comment, reply string) (modified string, skip bool)
+
+// AddPreGenerateHook は応答生成前のフックを登録します。フックは登録順に呼び出されます。
+// Run の開始前に登録する必要があります。
+func (p *LowLatencyPipeline) AddPreGenerateHook(hook PreGenerateHook) {
+	p.preGenerateHooks = append(p.preGenerateHooks, hook)
+}
+
+// AddPostGenerateHook は応答生成後・投稿前のフックを登録します。フックは登録順に呼び出されます。
+// Run の開始前に登録する必要があります。
+func (p *LowLatencyPipeline) AddPostGenerateHook(hook PostGenerateHook) {
+	p.postGenerateHooks = append(p.postGenerateHooks, hook)
+}
+
+// runPreGenerateHooks は登録されたフックを順に適用します。いずれかのフックが skip を返した時点で false を返します。
+func (p *LowLatencyPipeline) runPreGenerateHooks(comment youtube.Comment) (youtube.Comment, bool) {
+	for _, hook := range p.preGenerateHooks {
+		var skip bool
+		comment, skip = hook(comment)
+		if skip {
+			log.Printf("Pre-generate hook skipped comment from %s.", comment.Author)
+			return comment, false
+		}
+	}
+	return comment, true
+}
+
+// runPostGenerateHooks は登録されたフックを順に適用します。いずれかのフックが skip を返した時点で false を返します。
+func (p *LowLatencyPipeline) runPostGenerateHooks(comment youtube.Comment, reply string) (string, bool) {
+	for _, hook := range p.postGenerateHooks {
+		var skip bool
+		reply, skip = hook(comment, reply)
+		if skip {
+			log.Printf("Post-generate hook vetoed reply to %s.", comment.Author)
+			return reply, false
+		}
+	}
+	return reply, true
+}
@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// 応答の投稿の再試行と重複投稿の防止に関する設定
+const (
+	// postAttempts は 1 件の応答を投稿する最大試行回数です。
+	postAttempts = 2
+	// postRetryDelay は投稿を再試行するまでの待ち時間です。
+	postRetryDelay = 2 * time.Second
+	// postAttemptTimeout は 1 回の投稿の待ち時間の上限です。
+	// 超えた場合は投稿が成功したかどうか判別できないため、再試行しません。
+	postAttemptTimeout = 10 * time.Second
+	// postDedupWindow は同じコメントへの同じ応答の再投稿を防ぐ期間です。
+	postDedupWindow = 2 * time.Minute
+)
+
+// errDuplicatePost は同じコメントへの同じ応答を最近投稿済みのため、投稿しなかったことを示します。
+var errDuplicatePost = errors.New("the same reply to this comment was already posted recently")
+
+// recentPosts は最近投稿した (または投稿された可能性がある) 応答を、コメントIDと応答内容のハッシュで保持します。
+// YouTube API はクライアント側の冪等キーに対応していないため、タイムアウトした投稿が実際には成功していた場合に
+// 再試行で同じ応答を二重に投稿しないよう、投稿前にここで確認します。
+type recentPosts struct {
+	entries map[uint64]time.Time
+}
+
+// seen は同じ応答を postDedupWindow 以内に投稿済みかどうかを返します。期限切れのエントリはここで削除します。
+func (r *recentPosts) seen(key uint64, now time.Time) bool {
+	for k, t := range r.entries {
+		if now.Sub(t) > postDedupWindow {
+			delete(r.entries, k)
+		}
+	}
+	_, ok := r.entries[key]
+	return ok
+}
+
+// mark は応答を投稿済み (または投稿された可能性がある) として記録します。
+func (r *recentPosts) mark(key uint64, now time.Time) {
+	if r.entries == nil {
+		r.entries = make(map[uint64]time.Time)
+	}
+	r.entries[key] = now
+}
+
+// postKey はコメントIDと応答内容から重複判定のキーを作成します。
+func postKey(commentID, text string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(commentID))
+	h.Write([]byte{0})
+	h.Write([]byte(text))
+	return h.Sum64()
+}
+
+// isAmbiguousPostError は投稿が成功したかどうか判別できないエラーかどうかを判定します。
+// リクエストの送信後、応答を受け取る前のタイムアウトや接続の切断 (ECONNRESET、EOF) が該当し、
+// YouTube 側では投稿が成功している可能性があります。接続の確立に失敗した場合 (isNetworkError) は何も送信されていないため含みません。
+func isAmbiguousPostError(err error) bool {
+	if err == nil || isNetworkError(err) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// isRetryablePostError は投稿を再試行する価値があるエラーかどうかを判定します。
+// 再試行するのは YouTube が投稿を受け付けなかったことが明らかなエラー (429 と 5xx) だけです。
+// タイムアウトや接続の切断 (isAmbiguousPostError) は投稿が成功している可能性があるため、再試行しません。
+func isRetryablePostError(err error) bool {
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) {
+		return gErr.Code == http.StatusTooManyRequests || gErr.Code >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// postComment は応答を投稿し、一時的なエラー (isRetryablePostError) の場合は postAttempts 回まで再試行します。
+// 投稿前に recentPosts を確認し、同じコメントへの同じ応答を投稿済みの場合は errDuplicatePost を返します。
+// タイムアウトや接続の切断で結果が分からない投稿は成功している可能性があるため投稿済みとして記録し、再試行しません。
+// 記録は postDedupWindow の間有効なため、送信キュー (--offline-queue) からの再送でも同じ応答は投稿されません。
+func (p *LowLatencyPipeline) postComment(ctx context.Context, commentID, text string) error {
+	key := postKey(commentID, text)
+	if p.recentPosts.seen(key, time.Now()) {
+		return errDuplicatePost
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, postAttemptTimeout)
+		err := p.poster.PostComment(attemptCtx, text)
+		cancel()
+		if err == nil {
+			p.recentPosts.mark(key, time.Now())
+			return nil
+		}
+		if isAmbiguousPostError(err) {
+			p.recentPosts.mark(key, time.Now())
+			log.Printf("Posting the reply timed out or the connection dropped; not retrying because it may have been posted: %v", err)
+			return err
+		}
+		if attempt >= postAttempts || !isRetryablePostError(err) || ctx.Err() != nil {
+			return err
+		}
+
+		log.Printf("Error posting comment to YouTube (attempt %d/%d): %v. Retrying in %v.", attempt, postAttempts, err, postRetryDelay)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(postRetryDelay):
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+)
+
+// timeoutError は応答を受け取る前にタイムアウトしたことを表す net.Error です。
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// fakePoster は投稿を記録し、あらかじめ指定したエラーを順に返す commentPoster です。
+// 指定したエラーを使い切った後は成功を返します。
+type fakePoster struct {
+	errs   []error
+	calls  int
+	posted []string
+	// noDeadline は期限のないコンテキストで呼び出された回数です。
+	noDeadline int
+}
+
+func (f *fakePoster) PostComment(ctx context.Context, text string) error {
+	f.calls++
+	if _, ok := ctx.Deadline(); !ok {
+		f.noDeadline++
+	}
+	var err error
+	if f.calls <= len(f.errs) {
+		err = f.errs[f.calls-1]
+	}
+	if err == nil {
+		f.posted = append(f.posted, text)
+	}
+	return err
+}
+
+func TestPostCommentIdempotency(t *testing.T) {
+	tests := []struct {
+		name string
+		errs []error
+		// wantFirstErr は 1 回目の postComment がエラーを返すかどうかです。
+		wantFirstErr bool
+		// wantSecondErr は同じ応答を再度 postComment したときに期待するエラーです。
+		wantSecondErr error
+		wantCalls     int
+		wantPosted    int
+	}{
+		{
+			// タイムアウトしたが実際には投稿されていた場合、再試行でも再送でも二重に投稿しない
+			name:          "timeout then success",
+			errs:          []error{timeoutError{}, nil},
+			wantFirstErr:  true,
+			wantSecondErr: errDuplicatePost,
+			wantCalls:     1,
+			wantPosted:    0,
+		},
+		{
+			name:          "deadline exceeded then success",
+			errs:          []error{context.DeadlineExceeded, nil},
+			wantFirstErr:  true,
+			wantSecondErr: errDuplicatePost,
+			wantCalls:     1,
+			wantPosted:    0,
+		},
+		{
+			name:          "success",
+			errs:          nil,
+			wantSecondErr: errDuplicatePost,
+			wantCalls:     1,
+			wantPosted:    1,
+		},
+		{
+			// 投稿が拒否されたことが明らかなエラーは記録しないため、同じ応答を後で投稿できる
+			name:          "rejected then success",
+			errs:          []error{&googleapi.Error{Code: http.StatusBadRequest}},
+			wantFirstErr:  true,
+			wantSecondErr: nil,
+			wantCalls:     2,
+			wantPosted:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poster := &fakePoster{errs: tt.errs}
+			p := &LowLatencyPipeline{poster: poster}
+			ctx := context.Background()
+
+			err := p.postComment(ctx, "comment-1", "こんにちは！")
+			if (err != nil) != tt.wantFirstErr {
+				t.Fatalf("first postComment error = %v, want error %v", err, tt.wantFirstErr)
+			}
+			err = p.postComment(ctx, "comment-1", "こんにちは！")
+			if !errors.Is(err, tt.wantSecondErr) {
+				t.Fatalf("second postComment error = %v, want %v", err, tt.wantSecondErr)
+			}
+
+			if poster.calls != tt.wantCalls {
+				t.Errorf("PostComment called %d times, want %d", poster.calls, tt.wantCalls)
+			}
+			if len(poster.posted) != tt.wantPosted {
+				t.Errorf("visible posts = %d, want %d", len(poster.posted), tt.wantPosted)
+			}
+			if poster.noDeadline != 0 {
+				t.Errorf("PostComment called %d times without a per-attempt deadline", poster.noDeadline)
+			}
+		})
+	}
+}
+
+func TestIsRetryablePostError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"net timeout", timeoutError{}, false},
+		{"deadline exceeded", context.DeadlineExceeded, false},
+		{"too many requests", &googleapi.Error{Code: http.StatusTooManyRequests}, true},
+		{"server error", &googleapi.Error{Code: http.StatusServiceUnavailable}, true},
+		{"bad request", &googleapi.Error{Code: http.StatusBadRequest}, false},
+		{"other", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryablePostError(tt.err); got != tt.want {
+				t.Errorf("isRetryablePostError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
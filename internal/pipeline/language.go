@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 検出可能な言語名 (プロンプトの "respond in <lang>" に使用)
+const (
+	languageJapanese = "Japanese"
+	languageKorean   = "Korean"
+	languageEnglish  = "English"
+)
+
+// minEnglishLetterRatio は英語とみなすために必要な、文字 (letter) 全体に占める ASCII の英字の割合です。
+const minEnglishLetterRatio = 0.8
+
+// englishFunctionWords は英語の判定に使用する、英語の文に頻出する機能語・定型語です。
+// スペイン語やローマ字などでも使われる短い語 ("a"、"no"、"me"、"to" など) は誤判定を避けるため含めません。
+var englishFunctionWords = map[string]bool{
+	"the": true, "is": true, "are": true, "was": true, "were": true, "be": true, "been": true,
+	"what": true, "what's": true, "how": true, "why": true, "who": true, "where": true, "when": true, "which": true,
+	"this": true, "that": true, "these": true, "those": true, "it": true, "it's": true,
+	"you": true, "your": true, "you're": true, "my": true, "i'm": true, "we": true, "they": true,
+	"and": true, "of": true, "for": true, "with": true, "from": true, "about": true, "not": true, "just": true,
+	"do": true, "does": true, "did": true, "don't": true, "can": true, "could": true, "would": true, "will": true, "have": true, "has": true,
+	"hello": true, "thanks": true, "thank": true, "please": true,
+}
+
+// detectLanguage はコメントの文字種と語からおおよその言語を推定します。
+// 外部辞書を使わない軽量なヒューリスティックで、言語を特定できる場合のみその言語を返し、
+// それ以外は空文字列を返します (言語の指示を付けず、モデルの判断に任せます)。
+//   - かな (ひらがな・カタカナ) を 1 文字でも含めば日本語 (漢字や英単語が混ざっていても日本語)
+//   - かなを含まずハングルを含めば韓国語
+//   - 文字のほとんど (minEnglishLetterRatio 以上) が ASCII の英字で、英語の機能語 (englishFunctionWords) を含めば英語
+//
+// 漢字のみ ("草"、"初見") は日本語と中国語を区別できないため判定しません。機能語を含まないラテン文字のみのコメント
+// ("GG"、"nice"、ローマ字の "konnichiwa"、スペイン語など) も英語と区別できないため判定しません。
+func detectLanguage(text string) string {
+	var kana, hangul, letters, ascii int
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		}
+		if unicode.IsLetter(r) {
+			letters++
+			if r < unicode.MaxASCII {
+				ascii++
+			}
+		}
+	}
+
+	switch {
+	case kana > 0:
+		// かなは日本語に固有のため最優先
+		return languageJapanese
+	case hangul > 0:
+		return languageKorean
+	case letters > 0 && float64(ascii) >= minEnglishLetterRatio*float64(letters) && hasEnglishFunctionWord(text):
+		return languageEnglish
+	}
+	return ""
+}
+
+// hasEnglishFunctionWord は text が英語の機能語 (englishFunctionWords) を含むかどうかを判定します。
+func hasEnglishFunctionWord(text string) bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	for _, w := range words {
+		if englishFunctionWords[strings.Trim(w, "'")] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package pipeline
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "japanese hiragana", text: "こんにちは、今日も配信ありがとう", want: languageJapanese},
+		{name: "japanese katakana", text: "ゲームスタート", want: languageJapanese},
+		{name: "english question", text: "hello, what game is this?", want: languageEnglish},
+		{name: "english statement", text: "Thanks for the stream, you're awesome!", want: languageEnglish},
+		{name: "english contraction", text: "IT'S SO GOOD", want: languageEnglish},
+		{name: "english with emoji", text: "how long have you been playing? 🎮", want: languageEnglish},
+		{name: "english with one kanji", text: "what does 草 mean", want: languageEnglish},
+		{name: "english without function words", text: "GG nice play", want: ""},
+		{name: "single english word", text: "lol", want: ""},
+		{name: "french", text: "c'est très bien", want: ""},
+		{name: "spanish", text: "¿qué juego es este?", want: ""},
+		{name: "romaji", text: "konnichiwa", want: ""},
+		{name: "kanji only", text: "草", want: ""},
+		{name: "kanji only phrase", text: "初見 神回", want: ""},
+		{name: "mixed japanese and english", text: "GG! ナイスプレイ", want: languageJapanese},
+		{name: "mixed kanji and english", text: "上手 nice", want: ""},
+		{name: "mostly kanji with english word", text: "初見です is", want: languageJapanese},
+		{name: "mostly kanji with an english function word", text: "上手上手上手 the", want: ""},
+		{name: "korean", text: "안녕하세요", want: languageKorean},
+		{name: "korean with hanja", text: "大韓民國 만세", want: languageKorean},
+		{name: "japanese wins over hangul", text: "こんにちは 안녕", want: languageJapanese},
+		{name: "emoji only", text: "👏👏👏", want: ""},
+		{name: "empty", text: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectLanguage(tt.text); got != tt.want {
+				t.Errorf("detectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package pipeline
+
+import (
+	"time"
+
+	"prompter-live-go/internal/stats"
+	"prompter-live-go/internal/util"
+	"prompter-live-go/internal/youtube"
+)
+
+// replyTimings はコメントの処理中に記録する、応答の所要時間の内訳の計測点です。
+type replyTimings struct {
+	// started はコメントの処理を開始した時刻です。
+	started time.Time
+	// generateStart と generateEnd は応答の生成の開始・完了時刻です (生成していない場合はゼロ値)。
+	generateStart time.Time
+	generateEnd   time.Time
+	// posted は応答の投稿を終えた時刻です。
+	posted time.Time
+}
+
+// recordLatency は生成して投稿した応答について、コメントの投稿から応答の投稿までの所要時間の内訳を統計に記録します。
+// fetchedAt はコメントを取得した時刻です。
+func (p *LowLatencyPipeline) recordLatency(comment youtube.Comment, timings replyTimings, fetchedAt time.Time) {
+	if timings.generateEnd.IsZero() || timings.posted.IsZero() || fetchedAt.IsZero() {
+		return
+	}
+	postedAt := timings.posted
+	latency := stats.Latency{
+		Fetch:    commentAge(comment, fetchedAt),
+		Queue:    timings.started.Sub(fetchedAt),
+		Generate: timings.generateEnd.Sub(timings.generateStart),
+		Post:     postedAt.Sub(timings.generateEnd),
+		Total:    commentAge(comment, postedAt),
+	}
+	p.recorder.RecordLatency(latency)
+	util.Debugf("Reply latency for %s: %v total (fetch %v, queue %v, generate %v, post %v)", comment.Author,
+		latency.Total.Truncate(time.Millisecond), latency.Fetch.Truncate(time.Millisecond), latency.Queue.Truncate(time.Millisecond),
+		latency.Generate.Truncate(time.Millisecond), latency.Post.Truncate(time.Millisecond))
+}
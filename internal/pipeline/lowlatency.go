@@ -4,38 +4,143 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"log"
+	"math/rand"
+	"regexp"
+	"strings"
 	"time"
 
 	"prompter-live-go/internal/gemini"
+	"prompter-live-go/internal/overlay"
+	"prompter-live-go/internal/state"
+	"prompter-live-go/internal/stats"
+	"prompter-live-go/internal/transcript"
 	"prompter-live-go/internal/types"
 	"prompter-live-go/internal/youtube"
 )
 
+// linkPattern はコメント内の URL (http/https、www.、主要な短縮 URL、一般的な TLD のドメイン) を検出します。
+var linkPattern = regexp.MustCompile(`(?i)(?:https?://|www\.)\S+|\b(?:bit\.ly|t\.co|goo\.gl|tinyurl\.com|ow\.ly|is\.gd|buff\.ly|cutt\.ly|x\.gd|rb\.gy|youtu\.be)/\S*|\b[a-z0-9-]+(?:\.[a-z0-9-]+)*\.(?:com|net|org|io|gg|me|co|jp|xyz|info|tv|ly|link|site|shop)(?:/\S*)?\b`)
+
+// thinkingPlaceholderDelay は、応答生成がこの時間内に終わらない場合にのみプレースホルダーを投稿する猶予です。
+// 速く生成できた応答では投稿が 2 回にならないようにし、クォータ消費を抑えます。
+const thinkingPlaceholderDelay = 3 * time.Second
+
+// スキップ理由 (実行統計・終了時のサマリーで使用)
+const (
+	skipDeleted          = "deleted"
+	skipMembershipEvent  = "membership_event"
+	skipLink             = "link"
+	skipProbability      = "reply_probability"
+	skipEmptyResponse    = "empty_response"
+	skipNoPost           = "no_post"
+	skipModelDeclined    = "model_declined"
+	skipPaused           = "paused"
+	skipIgnoredChannel   = "ignored_channel"
+	skipChatRestricted   = "chat_restricted"
+	skipHook             = "hook"
+	skipCommand          = "command"
+	skipRepeatedQuestion = "repeated_question"
+	skipSpamAuthor       = "spam_author"
+	skipExchangeLimit    = "exchange_limit"
+	skipTooOld           = "too_old"
+	skipInactive         = "inactive_window"
+)
+
 // LowLatencyPipeline はライブチャットのリアルタイム処理を管理します。
 type LowLatencyPipeline struct {
-	geminiClient   *gemini.Client
-	youtubeClient  *youtube.Client
+	geminiClient *gemini.Client
+	// source はコメントの取得元 (読み取り側) です。
+	source CommentSource
+	// youtubeClient は応答の投稿先 (書き込み側) です。
+	youtubeClient *youtube.Client
+	// poster は応答の投稿に使用します。通常は youtubeClient です。
+	poster         commentPoster
 	geminiConfig   types.LiveAPIConfig
 	pipelineConfig types.PipelineConfig
 
-	// セッション管理用
-	session gemini.Session
+	// responder は AI 応答の生成に使用するバックエンドです。
+	// nil の場合は Run の開始時に geminiClient のセッションから作成します。
+	responder Responder
+
+	// rng は応答確率やスタイル指示の選択など、パイプラインのすべての無作為な判定に使用する乱数生成器です。
+	// math/rand のグローバルな乱数は使用せず、--rng-seed で結果を再現できるようにします。
+	rng *rand.Rand
+	// recorder は実行統計 (ダッシュボードなどで使用) を記録します。
+	recorder *stats.Recorder
+	// verifier は投稿の表示確認を行います (--verify-posts 指定時のみ有効)。
+	verifier *postVerifier
+	// restriction は配信途中でのチャットの制限 (登録者限定・メンバー限定) への切り替えを追跡します。
+	restriction chatRestriction
+	// paused はモデレーターのチャットコマンド (!bot pause / !bot resume) による一時停止状態です。
+	paused bool
+	// preGenerateHooks / postGenerateHooks は応答生成の前後に呼び出される拡張用のフックです。
+	preGenerateHooks  []PreGenerateHook
+	postGenerateHooks []PostGenerateHook
+	// digest はダイジェストモード (--digest-interval 指定時) で投稿待ちのコメントを保持します。
+	digest digestBuffer
+	// transcript はコメントと応答の記録先です (--transcript 指定時のみ有効)。
+	transcript *transcript.Writer
+	// csvExport は処理結果の CSV の書き出し先です (--csv-out 指定時のみ有効)。
+	csvExport *transcript.CSVWriter
+	// overlay は最新の応答を書き出す OBS 用のファイルです (--overlay-file 指定時のみ有効)。
+	overlay *overlay.Writer
+	// state は最近応答した質問 (--question-cooldown) などの状態の保存先です。既定はメモリ上のストアです。
+	state state.Store
+	// recentPosts は二重投稿を防ぐための最近投稿した応答です。
+	recentPosts recentPosts
+	// exchanges は投稿者ごとの連続した応答の回数です (--max-exchanges-per-author 指定時のみ使用)。
+	exchanges exchangeTracker
+	// activeWindow は応答を生成・投稿する期間 (--active-after / --active-window) の状態です。
+	activeWindow activeWindow
+	// viewerMilestones は視聴者数の節目の到達状況です (--viewer-milestone-step 指定時のみ使用)。
+	viewerMilestones viewerMilestones
+	// offline はネットワークエラーで投稿できなかった応答です (--offline-queue 指定時のみ使用)。
+	offline offlineQueue
+	// recap は配信中に寄せられた質問の集計です (!bot recap / --recap-on-exit で使用)。
+	recap recapLog
+	// streamChatID は配信ごとの状態が属するライブチャットIDです (コメントソースが streamIdentifier の場合のみ)。
+	streamChatID string
+	// lastFetchAt は直近にコメントを取得した時刻です (応答の所要時間の内訳に使用)。
+	lastFetchAt time.Time
+	// zeroIntervalLogged は API がポーリング間隔を返さないことをログに出力済みかどうかです (毎回出力しないため)。
+	zeroIntervalLogged bool
+	// shadowClient と shadow はシャドーモード (--shadow-instruction-file) の Gemini Client とそのセッションです。
+	shadowClient *gemini.Client
+	shadow       Responder
 }
 
 // NewLowLatencyPipeline は新しいパイプラインインスタンスを作成します。
+// responder に nil を渡すと Gemini (geminiClient) で応答を生成し、
+// それ以外の場合は geminiClient を使用せず responder で応答を生成します。
 func NewLowLatencyPipeline(
 	geminiClient *gemini.Client,
+	responder Responder,
+	source CommentSource,
 	youtubeClient *youtube.Client,
 	geminiConfig types.LiveAPIConfig,
 	pipelineConfig types.PipelineConfig,
+	recorder *stats.Recorder,
 ) *LowLatencyPipeline {
+	seed := pipelineConfig.RandomSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	// 同じ判定を再現できるよう、使用したシードを出力する
+	log.Printf("Random seed: %d (pass --rng-seed %d to reproduce)", seed, seed)
+
 	return &LowLatencyPipeline{
 		geminiClient:   geminiClient,
+		responder:      responder,
+		source:         source,
 		youtubeClient:  youtubeClient,
+		poster:         youtubeClient,
 		geminiConfig:   geminiConfig,
 		pipelineConfig: pipelineConfig,
+		rng:            rand.New(rand.NewSource(seed)),
+		recorder:       recorder,
+		activeWindow:   activeWindow{startedAt: time.Now()},
+		state:          state.NewMemoryStore(),
 	}
 }
 
@@ -43,115 +148,588 @@ func NewLowLatencyPipeline(
 func (p *LowLatencyPipeline) Run(ctx context.Context) error {
 	log.Println("Pipeline started.")
 
-	// 1. Geminiセッションの初期化
-	session, err := p.geminiClient.StartSession(ctx, p.geminiConfig)
-	if err != nil {
-		return fmt.Errorf("failed to start Gemini session: %w", err)
+	// 1. 応答バックエンドの初期化 (外部の Responder が指定されていなければ Gemini セッションを使用)
+	if p.responder == nil {
+		session, err := p.startGeminiSession(ctx)
+		if err != nil {
+			return err
+		}
+		defer session.Close()
+		p.responder = &sessionResponder{session: session}
 	}
-	p.session = session
-	defer p.session.Close()
-
-	// 💡 修正点 1: システム指示をセッションの最初のメッセージとして送信
-	if p.geminiConfig.SystemInstruction != "" {
-		log.Println("Sending System Instruction as initial message...")
-
-		// システム指示を送信
-		if err := p.session.Send(ctx, types.LiveStreamData{Text: p.geminiConfig.SystemInstruction}); err != nil {
-			return fmt.Errorf("failed to send system instruction: %w", err)
+	if p.shadowClient != nil {
+		session, err := p.startShadowSession(ctx)
+		if err != nil {
+			return err
 		}
+		defer session.Close()
+		p.shadow = &sessionResponder{session: session}
+		log.Println("Shadow mode enabled: generating a second reply per comment with the shadow instruction (logged, not posted).")
+	}
 
-		// AIからの最初の応答 (システム指示に対する確認応答) を待つ
-		// ここでの応答は通常空であるか、短い確認応答ですが、必ず RecvResponse を呼び出してチャネルをクリアする必要があります。
-		// この処理をブロックすることで、システム指示が確実にAIに届くまで待機します。
-		if _, err := p.session.RecvResponse(); err != nil && !errors.Is(err, io.EOF) {
-			// io.EOF は正常終了と見なす
-			log.Printf("Warning: Failed to receive initial AI response for system instruction: %v", err)
+	// 投稿の表示確認には、ボット自身のチャンネルIDが必要
+	if p.pipelineConfig.VerifyPosts {
+		selfID, err := p.youtubeClient.SelfChannelID(ctx)
+		if err != nil {
+			log.Printf("Warning: Post verification disabled because the bot's channel ID could not be determined: %v", err)
+		} else {
+			p.verifier = newPostVerifier(selfID)
 		}
-		log.Println("System Instruction processed.")
 	}
 
 	// 2. メインループの実行
 	return p.runLoop(ctx)
 }
 
+// startGeminiSession は Gemini セッションを開始します。
+// システム指示はセッション作成時に会話履歴として適用されるため、ここで送受信は行いません。
+func (p *LowLatencyPipeline) startGeminiSession(ctx context.Context) (gemini.Session, error) {
+	session, err := p.geminiClient.StartSession(ctx, p.geminiConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start Gemini session: %w", err)
+	}
+	return session, nil
+}
+
 // runLoop は定期的なポーリングとAI応答処理を行うメインのループです。
 func (p *LowLatencyPipeline) runLoop(ctx context.Context) error {
 	// YouTube Live Chat API から推奨されるポーリング間隔を初期値として設定
-	nextPollDelay := p.pipelineConfig.PollingInterval
+	// ダイジェストの投稿などでポーリングの待ち時間がやり直しにならないよう、タイマーはポーリングの後にのみ再設定する
+	pollTimer := time.NewTimer(p.pipelineConfig.PollingInterval)
+	defer pollTimer.Stop()
+
+	// ダイジェストモードでは、一定間隔でバッファ内のコメントをまとめて応答する
+	var digestTick <-chan time.Time
+	if p.pipelineConfig.DigestInterval > 0 {
+		ticker := time.NewTicker(p.pipelineConfig.DigestInterval)
+		defer ticker.Stop()
+		digestTick = ticker.C
+		log.Printf("Digest mode enabled. Posting a consolidated reply every %v.", p.pipelineConfig.DigestInterval)
+	}
 
+	nextPollDelay := p.pipelineConfig.PollingInterval
 	for {
 		select {
 		case <-ctx.Done():
 			// アプリケーション終了シグナルを受け取る
 			log.Println("Pipeline context cancelled. Shutting down.")
+			p.flushDigestOnExit()
+			p.postRecapOnExit()
 			return ctx.Err()
-		case <-time.After(nextPollDelay):
+		case <-digestTick:
+			p.flushDigest(ctx)
+		case <-pollTimer.C:
 			// ポーリング間隔が経過したら実行
-
-			// 1. YouTube から新しいコメントを取得
-			comments, pollingInterval, err := p.youtubeClient.FetchLiveChatMessages(ctx)
-
-			// 2. エラー処理
+			delay, err := p.poll(ctx, nextPollDelay)
 			if err != nil {
-				if errors.Is(err, youtube.ErrLiveChatEnded) {
-					log.Println("Live chat ended. Waiting 30s before trying to find a new chat.")
-					// ライブチャットが終了した場合は、次の再試行まで長めに待つ
-					nextPollDelay = 30 * time.Second
-					continue
-				}
-				log.Printf("Error fetching live chat messages: %v. Retrying in %v.", err, nextPollDelay)
-				// その他のエラーの場合は、次のポーリング間隔まで待って再試行
-				continue
+				return err
 			}
+			nextPollDelay = delay
+			pollTimer.Reset(nextPollDelay)
+		}
+	}
+}
 
-			// APIが推奨するポーリング間隔に更新
-			if pollingInterval > 0 {
-				nextPollDelay = pollingInterval
-			} else {
-				log.Println("API returned 0s polling interval. Using default.")
-			}
+// poll はコメントを 1 回取得して処理し、次のポーリングまでの待ち時間を返します。delay は現在の待ち時間です。
+// 取得の失敗は待ち時間を調整して再試行しますが、チャットを利用できず再試行しない設定の場合はエラーを返します。
+func (p *LowLatencyPipeline) poll(ctx context.Context, delay time.Duration) (time.Duration, error) {
+	// 1. コメントソース (通常は YouTube) から新しいコメントを取得
+	comments, pollingInterval, err := p.source.FetchLiveChatMessages(ctx)
 
-			// 3. 取得したコメントを AI に送信し、応答処理を開始
-			for _, comment := range comments {
-				log.Printf("New Comment received from %s: %s", comment.Author, comment.Message)
-
-				// AIにコメントを送信 (非同期で応答ストリームを開始する)
-				data := types.LiveStreamData{
-					Text: fmt.Sprintf("%s says: %s", comment.Author, comment.Message),
-					// Modalitiesなどの追加情報をここに追加可能
-				}
-				if err := p.session.Send(ctx, data); err != nil {
-					log.Printf("Error sending message to Gemini: %v", err)
-					continue
-				}
-
-				// 4. AI応答の受信と YouTube への投稿（ブロック）
-				p.handleAIResponse(ctx)
+	// 2. エラー処理
+	if err != nil {
+		if errors.Is(err, youtube.ErrLiveChatEnded) {
+			log.Println("Live chat ended. Waiting 30s before trying to find a new chat.")
+			// ライブチャットが終了した場合は、次の再試行まで長めに待つ
+			return 30 * time.Second, nil
+		}
+		if errors.Is(err, youtube.ErrLiveChatRestricted) {
+			p.restriction.fetchRestricted()
+		}
+		if errors.Is(err, youtube.ErrLiveChatDisabled) || errors.Is(err, youtube.ErrLiveChatRestricted) {
+			// チャットが無効化・登録者限定などの場合は、汎用エラーではなく対処可能なメッセージを表示
+			if p.pipelineConfig.ChatUnavailableRetry <= 0 {
+				return 0, fmt.Errorf("chat is disabled or subscribers/members-only for this stream; enable chat for everyone or use an account that can participate: %w", err)
 			}
+			log.Printf("Chat is disabled or subscribers/members-only for this stream (%v). Retrying in %v in case chat is enabled later.", err, p.pipelineConfig.ChatUnavailableRetry)
+			return p.pipelineConfig.ChatUnavailableRetry, nil
 		}
+		log.Printf("Error fetching live chat messages: %v. Retrying in %v.", err, delay)
+		p.recorder.RecordError()
+		// その他のエラーの場合は、次のポーリング間隔まで待って再試行
+		return delay, nil
+	}
+
+	// 別の配信のライブチャットに切り替わった場合は、前の配信の状態を引き継がない
+	p.checkStreamChange()
+	p.restriction.fetchSucceeded()
+	p.lastFetchAt = time.Now()
+	// コメントを取得できた (接続が回復した) ため、ネットワークエラーで保留していた応答を投稿する
+	p.flushOfflineQueue(ctx)
+
+	// APIが推奨するポーリング間隔に更新 (下限を下回る値は下限に切り上げ)
+	// API がポーリング間隔を返さない状態が続く間は、毎回ログに出力しない
+	delay = pollDelay(pollingInterval, p.pipelineConfig.PollingFallback)
+	if pollingInterval > 0 {
+		p.zeroIntervalLogged = false
+	} else if !p.zeroIntervalLogged {
+		log.Printf("API returned no polling interval. Polling every %v until it does.", delay)
+		p.zeroIntervalLogged = true
 	}
+	p.recorder.SetPollInterval(delay)
+
+	// 投稿した応答がチャットに表示されたかを確認
+	if p.verifier != nil {
+		p.verifier.observe(comments, time.Now())
+	}
+
+	// 3. 取得したコメントを AI に送信し、応答処理を開始
+	// (--prioritize 指定時は Super Chat・モデレーター・メンバーのコメントを先に処理する)
+	if p.pipelineConfig.Prioritize {
+		prioritizeComments(comments)
+	}
+	for _, comment := range comments {
+		p.processComment(ctx, comment)
+	}
+	return delay, nil
 }
 
-// handleAIResponse はAIからの応答を受け取り、YouTubeに投稿します。
-func (p *LowLatencyPipeline) handleAIResponse(ctx context.Context) {
-	// RecvResponse は完全な応答が来るまで待機し、一度だけ返します。
-	resp, err := p.session.RecvResponse()
-	if err != nil {
-		if errors.Is(err, io.EOF) {
-			// ストリーム完了（正常終了）
+// pollDelay は次のポーリングまでの待ち時間を返します。API の推奨値 suggested は下限 (MinPollingInterval) に切り上げ、
+// 推奨値がない (0 以下の) 場合は設定されたポーリング間隔ではなく、専用のフォールバック間隔 fallback
+// (下限 MinPollingFallback) を使用します。
+func pollDelay(suggested, fallback time.Duration) time.Duration {
+	if suggested > 0 {
+		return max(suggested, types.MinPollingInterval)
+	}
+	return max(fallback, types.MinPollingFallback)
+}
+
+// processComment は 1 件のコメントを AI に送信し、応答を YouTube に投稿します。
+func (p *LowLatencyPipeline) processComment(ctx context.Context, comment youtube.Comment) {
+	// ボット自身の投稿は表示確認にのみ使用し、応答しない
+	if p.verifier != nil && p.verifier.isSelf(comment) {
+		return
+	}
+
+	// 処理結果を CSV エクスポート (--csv-out) とトランスクリプトに書き出す (受信したままのコメントを記録する)
+	outcome := &commentOutcome{timings: replyTimings{started: time.Now()}}
+	defer p.recordOutcome(comment, outcome)
+
+	// 無視リスト (--ignore-channels) のチャンネル (他のボットなど) のコメントには一切応答しない
+	if p.isIgnoredChannel(comment.AuthorID) {
+		p.skip(outcome, skipIgnoredChannel)
+		return
+	}
+
+	// 古いコメント (再起動前の取りこぼしなど) には、今さら応答しない (--max-comment-age)
+	// 時計のずれで未来の時刻になっているコメントは、今投稿されたものとして扱う
+	if maxAge := p.pipelineConfig.MaxCommentAge; maxAge > 0 {
+		if age := commentAge(comment, time.Now()); age > maxAge {
+			log.Printf("Skipping comment from %s posted %v ago (older than %v).", comment.Author, age.Truncate(time.Second), maxAge)
+			p.skip(outcome, skipTooOld)
 			return
 		}
-		log.Printf("Error receiving Gemini response: %v", err)
+	}
+
+	// トランスクリプトには整形前の表示名をそのまま記録する
+	p.writeTranscript(transcript.KindComment, comment.ID, comment.AuthorID, comment.Author, comment.Message)
+
+	// スパムの疑いがある表示名の投稿者には、生成前に応答をスキップする (--skip-spam-authors)。
+	// 見た目の似た文字を検出するため、整形前の表示名で判定する
+	spamPattern, spam := p.isSpamAuthor(comment.Author)
+
+	// 長すぎる・制御文字を含む表示名がプロンプトやログを乱さないよう整える
+	comment.Author = sanitizeAuthor(comment.Author)
+
+	if spam {
+		log.Printf("Skipping comment from %s because the author name matches spam pattern %q.", comment.Author, spamPattern)
+		p.skip(outcome, skipSpamAuthor)
+		return
+	}
+
+	log.Printf("New Comment received from %s: %s", comment.Author, comment.Message)
+	p.recorder.RecordComment(comment.Author, comment.Message)
+
+	// 所有者・モデレーターのチャットコマンド (!bot pause など) は応答せずに処理する
+	if p.handleBotCommand(ctx, comment) {
+		p.skip(outcome, skipCommand)
+		return
+	}
+
+	// まとめ用に質問を集計する (一時停止中・期間外の質問も含める)
+	p.recap.noteQuestion(comment.Message, time.Now())
+
+	// 一時停止中はコメントの取得 (重複排除) のみ行い、応答しない
+	if p.paused {
+		p.skip(outcome, skipPaused)
+		return
+	}
+
+	// 応答する期間 (--active-after / --active-window) の外では、コメントの取得 (重複排除) のみ行う
+	if !p.isActive(time.Now()) {
+		p.skip(outcome, skipInactive)
+		return
+	}
+
+	// 同じバッチ内で既に削除が通知されているコメントには応答しない
+	if p.pipelineConfig.RespectDeletions && p.source.IsCommentDeleted(comment.ID) {
+		log.Printf("Skipping deleted comment from %s (%s).", comment.Author, comment.ID)
+		p.skip(outcome, skipDeleted)
+		return
+	}
+
+	// メンバーシップ関連イベントは --celebrate-members 指定時のみ応答
+	if comment.Event != youtube.EventNone && !p.pipelineConfig.CelebrateMembers {
+		p.skip(outcome, skipMembershipEvent)
+		return
+	}
+
+	// リンクを含むコメントには応答しない (--skip-links)
+	if p.pipelineConfig.SkipLinks && containsLink(comment.Message) {
+		log.Printf("Skipping comment from %s because it contains a link.", comment.Author)
+		p.skip(outcome, skipLink)
+		return
+	}
+
+	// 応答確率に基づいてスキップを判定 (コメントIDは取得時に記録済みのため重複処理されない)
+	if !p.shouldReply() {
+		log.Printf("Skipping comment from %s (reply probability %.2f).", comment.Author, p.pipelineConfig.ReplyProbability)
+		p.skip(outcome, skipProbability)
+		return
+	}
+
+	// 投稿が制限されている間は、投稿できない応答の生成 (コスト) を見合わせる
+	if p.restriction.postingPaused(time.Now()) {
+		p.skip(outcome, skipChatRestricted)
+		return
+	}
+
+	// 長すぎるコメントはトークン消費と遅延を抑えるため切り詰める (--max-input-chars)
+	comment.Message = p.truncateInput(comment)
+
+	// 登録されたフックでコメントを書き換える (またはスキップする)
+	comment, ok := p.runPreGenerateHooks(comment)
+	if !ok {
+		p.skip(outcome, skipHook)
+		return
+	}
+
+	// ダイジェストモードでは個別に応答せず、次のダイジェストまでバッファに溜める
+	if p.pipelineConfig.DigestInterval > 0 {
+		p.digest.add(comment)
+		outcome.skipReason = outcomeDigest
+		return
+	}
+
+	// 同じ投稿者への連続した応答が上限に達した場合は、しばらく間が空くまで応答しない (--max-exchanges-per-author)
+	if p.exchangeLimitReached(comment) {
+		log.Printf("Skipping comment from %s (reached %d consecutive replies; cooling off).", comment.Author, p.pipelineConfig.MaxExchangesPerAuthor)
+		p.skip(outcome, skipExchangeLimit)
+		return
+	}
+
+	// 最近応答した質問の繰り返しには、新しい応答を生成しない (--question-cooldown)
+	if prior, ok := p.repeatedQuestion(ctx, comment); ok {
+		log.Printf("Skipping repeated question from %s (answered %v ago).", comment.Author, time.Since(prior.AnsweredAt).Truncate(time.Second))
+		p.skip(outcome, skipRepeatedQuestion)
+		if p.pipelineConfig.RepostRepeatedAnswer {
+			outcome.reply = prior.Reply
+			outcome.posted = p.postReply(ctx, comment.ID, comment.Author, prior.Reply)
+		}
+		p.recap.noteAnswer(comment.Message, prior.Reply)
 		return
 	}
 
+	// AIにコメントを送信し、完全な応答を待つ
+	prompt := withStyleHint(p.withFAQ(p.commentPrompt(comment), comment), p.pickStyleVariant())
+	prompt = withLengthHint(prompt, p.replyLimit(comment))
+	prompt = p.withViewerContext(prompt)
+	data := types.LiveStreamData{
+		Text:   p.withActionInstruction(p.withEmojiInstruction(p.withSentenceLimit(prompt))),
+		Author: comment.Author,
+		// Modalitiesなどの追加情報をここに追加可能
+	}
+	stopPlaceholder := p.startThinkingPlaceholder(ctx, comment)
+	// シャドーモードでは同じプロンプトで別のシステム指示の応答を並行して生成し、投稿の後に記録する
+	finishShadow := p.startShadow(ctx, comment, data)
+	outcome.timings.generateStart = time.Now()
+	resp, err := p.responder.GenerateResponse(ctx, data)
+	outcome.timings.generateEnd = time.Now()
+	stopPlaceholder()
+	if err != nil {
+		log.Printf("Error generating AI response: %v", err)
+		p.recorder.RecordError()
+		outcome.skipReason = outcomeError
+		finishShadow()
+		return
+	}
+
+	// 4. AI応答の YouTube への投稿
+	p.handleAIResponse(ctx, comment, resp, outcome)
+	finishShadow()
+}
+
+// buildPrompt はコメントから Gemini に送信するプロンプトを組み立てます。
+// メンバーシップ関連イベントの場合は、お祝いを促すイベント専用のヒントを付与します。
+// Super Chat の場合は、金額を含めて支援への感謝を促すヒントを付与します。
+// language が空でない場合は、その言語で応答するよう指示を付与します。
+// includeAuthor が false の場合は投稿者名を含めず、コメント本文のみを送信します (--include-author=false)。
+func buildPrompt(comment youtube.Comment, language string, includeAuthor bool) string {
+	var prompt string
+	if comment.Event != youtube.EventNone {
+		message := comment.Message
+		if !includeAuthor {
+			// イベントの説明文は YouTube の表示名から生成されているため、名前を伏せる
+			message = strings.Replace(message, comment.Author, "A viewer", 1)
+		}
+		prompt = fmt.Sprintf("[Membership event] %s. Please congratulate them warmly in a short message.", message)
+	} else if comment.SuperChatAmountMicros > 0 {
+		prompt = fmt.Sprintf("[Super Chat %s] %s\n(This is a paid message. Thank them for their support and respond to the message.)", comment.SuperChatAmount, framedMessage(comment, includeAuthor))
+	} else {
+		prompt = framedMessage(comment, includeAuthor)
+	}
+	return withLanguageHint(prompt, language)
+}
+
+// withLanguageHint は language が空でない場合に、その言語で応答するよう指示をプロンプトに付与します。
+func withLanguageHint(prompt, language string) string {
+	if language == "" {
+		return prompt
+	}
+	return prompt + fmt.Sprintf("\n(Respond in %s.)", language)
+}
+
+// framedMessage はプロンプトに含めるコメント本文を、投稿者名を付けて ("<author> says: <message>") 返します。
+// includeAuthor が false の場合は本文のみを返します。
+func framedMessage(comment youtube.Comment, includeAuthor bool) string {
+	if !includeAuthor {
+		return comment.Message
+	}
+	return fmt.Sprintf("%s says: %s", comment.Author, comment.Message)
+}
+
+// responseLanguage は応答に使用する言語を決定します。
+// --response-language が指定されていればそれを優先し、未指定の場合はコメントの言語を推定します。
+func (p *LowLatencyPipeline) responseLanguage(comment youtube.Comment) string {
+	if p.pipelineConfig.ResponseLanguage != "" {
+		return p.pipelineConfig.ResponseLanguage
+	}
+	// メンバーシップイベントのメッセージは YouTube が生成した定型文のため判定しない
+	if comment.Event != youtube.EventNone {
+		return ""
+	}
+	return detectLanguage(comment.Message)
+}
+
+// truncateInput はコメント本文が --max-input-chars を超える場合に、文字 (rune) 単位で切り詰めた本文を返します。
+func (p *LowLatencyPipeline) truncateInput(comment youtube.Comment) string {
+	limit := p.pipelineConfig.MaxInputChars
+	if limit <= 0 {
+		return comment.Message
+	}
+	runes := []rune(comment.Message)
+	if len(runes) <= limit {
+		return comment.Message
+	}
+	log.Printf("Truncating comment from %s from %d to %d characters.", comment.Author, len(runes), limit)
+	return string(runes[:limit]) + "…"
+}
+
+// isIgnoredChannel はチャンネルIDが --ignore-channels に含まれているかどうかを判定します。
+func (p *LowLatencyPipeline) isIgnoredChannel(channelID string) bool {
+	for _, id := range p.pipelineConfig.IgnoreChannels {
+		if id == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// containsLink はテキストに URL が含まれているかどうかを判定します。
+func containsLink(text string) bool {
+	return linkPattern.MatchString(text)
+}
+
+// shouldReply は設定された応答確率に従い、このコメントに応答するかどうかを判定します。
+func (p *LowLatencyPipeline) shouldReply() bool {
+	if p.pipelineConfig.ReplyProbability >= 1 {
+		return true
+	}
+	return p.rng.Float64() < p.pipelineConfig.ReplyProbability
+}
+
+// startThinkingPlaceholder は応答生成が遅い場合に備え、thinkingPlaceholderDelay 経過後に
+// プレースホルダー (--thinking-placeholder) を投稿するタイマーを開始します。
+// 戻り値の関数は生成完了時に呼び出し、まだ投稿されていなければ投稿を取り消します。
+func (p *LowLatencyPipeline) startThinkingPlaceholder(ctx context.Context, comment youtube.Comment) (stop func()) {
+	placeholder := p.pipelineConfig.ThinkingPlaceholder
+	if placeholder == "" || p.pipelineConfig.NoPost {
+		return func() {}
+	}
+
+	timer := time.AfterFunc(thinkingPlaceholderDelay, func() {
+		log.Printf("Response for %s is taking a while. Posting placeholder: %s", comment.Author, placeholder)
+		if err := p.poster.PostComment(ctx, placeholder); err != nil {
+			log.Printf("Error posting thinking placeholder to YouTube: %v", err)
+			p.recorder.RecordError()
+		}
+	})
+	return func() { timer.Stop() }
+}
+
+// pickStyleVariant は --style-variants-file のスタイル指示から 1 つを無作為に選びます。
+// 指示が設定されていない場合は空文字列を返します。
+func (p *LowLatencyPipeline) pickStyleVariant() string {
+	variants := p.pipelineConfig.StyleVariants
+	if len(variants) == 0 {
+		return ""
+	}
+	return variants[p.rng.Intn(len(variants))]
+}
+
+// withStyleHint はプロンプトの末尾にスタイル指示を付与します。style が空の場合はそのまま返します。
+func withStyleHint(prompt string, style string) string {
+	if style == "" {
+		return prompt
+	}
+	return fmt.Sprintf("%s\n(Style for this reply: %s)", prompt, style)
+}
+
+// handleAIResponse はAIからの応答を YouTube に投稿します。
+func (p *LowLatencyPipeline) handleAIResponse(ctx context.Context, comment youtube.Comment, resp *types.LowLatencyResponse, outcome *commentOutcome) {
+	// 生成に失敗した応答は、エラーの内容をライブチャットに投稿せずにログに記録する
+	if resp.Err != nil {
+		log.Printf("Error generating AI response for %s: %v", comment.Author, resp.Err)
+		p.recorder.RecordError()
+		outcome.skipReason = outcomeError
+		return
+	}
+	p.recorder.RecordTokens(resp.PromptTokens, resp.ResponseTokens)
+
+	// 複数の応答候補を生成した場合は、投稿する候補を選ぶ (--candidate-strategy)
+	resp.ResponseText = p.selectCandidate(resp)
+
+	// 構造化アクションモードでは、モデルが "answer" を選んだ場合のみ投稿する
+	if p.geminiConfig.StructuredActions && resp.ResponseText != "" {
+		text, ok := p.resolveAction(resp.ResponseText)
+		if !ok {
+			log.Printf("Model declined to reply to %s.", comment.Author)
+			outcome.skipReason = skipModelDeclined
+			return
+		}
+		resp.ResponseText = text
+	}
+
+	// 前後の空白・コードブロック、(--strip-meta 指定時は) 先頭のメタ的な前置きを取り除き、文数・文字数を制限する
+	resp.ResponseText = p.finishReply(resp.ResponseText, p.replyLimit(comment))
+	// Super Chat には定型の感謝の一文を先頭に付ける (--super-chat-template)
+	resp.ResponseText = p.withSuperChatAck(comment, resp.ResponseText)
+
+	// 応答が空 (安全性によるブロックなど) の場合は、設定された定型の応答で代替する (--empty-reply-fallback)
+	fallback := false
+	if resp.ResponseText == "" && p.pipelineConfig.EmptyReplyFallback != "" {
+		log.Printf("Generated reply for %s is empty. Posting the fallback reply instead.", comment.Author)
+		resp.ResponseText = p.pipelineConfig.EmptyReplyFallback
+		fallback = true
+	}
+
 	// 応答テキストが空でなければ投稿
 	if resp.ResponseText != "" {
 		log.Printf("AI Response: %s", resp.ResponseText)
 
-		// YouTube にコメントを投稿
-		if err := p.youtubeClient.PostComment(ctx, resp.ResponseText); err != nil {
-			log.Printf("Error posting comment to YouTube: %v", err)
+		// 登録されたフックで応答を書き換える (または投稿を拒否する)
+		text, ok := p.runPostGenerateHooks(comment, resp.ResponseText)
+		if !ok {
+			p.skip(outcome, skipHook)
+			return
+		}
+		resp.ResponseText = text
+
+		// 応答生成中に元のコメントが削除された場合は投稿しない (--respect-deletions)
+		if p.pipelineConfig.RespectDeletions && p.source.IsCommentDeleted(comment.ID) {
+			log.Printf("Skipping reply to %s because the original comment (%s) was deleted.", comment.Author, comment.ID)
+			p.skip(outcome, skipDeleted)
+			return
+		}
+
+		outcome.reply = resp.ResponseText
+		outcome.posted = p.postReply(ctx, comment.ID, comment.Author, resp.ResponseText)
+		outcome.timings.posted = time.Now()
+		switch {
+		case outcome.posted && fallback:
+			// 定型の応答は質問への回答として記録しない (繰り返しの質問には改めて応答を生成する)
+			p.recordExchange(comment)
+		case outcome.posted:
+			p.recordAnswer(ctx, comment, resp.ResponseText)
+			p.recap.noteAnswer(comment.Message, resp.ResponseText)
+			p.recordExchange(comment)
+		case p.pipelineConfig.NoPost:
+			outcome.skipReason = skipNoPost
+		default:
+			outcome.skipReason = outcomeError
+		}
+		return
+	}
+	p.skip(outcome, skipEmptyResponse)
+}
+
+// metaPatterns は応答の先頭から取り除く前置きのパターンを返します。--strip-meta が無効の場合は nil です。
+func (p *LowLatencyPipeline) metaPatterns() []*regexp.Regexp {
+	if !p.pipelineConfig.StripMeta {
+		return nil
+	}
+	if len(p.pipelineConfig.MetaPatterns) > 0 {
+		return p.pipelineConfig.MetaPatterns
+	}
+	return defaultMetaPatterns
+}
+
+// postReply は応答を YouTube に投稿し、統計と表示確認に記録します。commentID は応答先のコメントID、
+// author は応答先の表示名です (ダイジェストの場合はどちらも "digest")。投稿できた場合は true を返します。
+func (p *LowLatencyPipeline) postReply(ctx context.Context, commentID, author, text string) (posted bool) {
+	// プレビューモード (--no-post) では、生成した応答をログに出力するだけで投稿しない
+	if p.pipelineConfig.NoPost {
+		log.Printf("[no-post] Would reply to %s: %s", author, text)
+		p.recorder.RecordSkip(skipNoPost)
+		return false
+	}
+
+	// 即座に応答すると機械的に見えるため、設定された時間だけ待ってから投稿する (シャットダウン時は中断)
+	if delay := p.pipelineConfig.PostDelay; delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			log.Printf("Discarding pending reply to %s due to shutdown.", author)
+			return false
+		}
+	}
+
+	if err := p.postComment(ctx, commentID, text); err != nil {
+		if errors.Is(err, errDuplicatePost) {
+			log.Printf("Skipping duplicate reply to %s: %v", author, err)
+			return false
 		}
+		if p.queueOffline(commentID, author, text, err) {
+			return false
+		}
+		log.Printf("Error posting comment to YouTube: %v", err)
+		p.recorder.RecordError()
+		if errors.Is(err, youtube.ErrLiveChatRestricted) {
+			p.restriction.postForbidden(time.Now(), p.pipelineConfig.ChatUnavailableRetry)
+		}
+		return false
+	}
+	p.recordPosted(author, text)
+	return true
+}
+
+// recordPosted は投稿に成功した応答を統計・トランスクリプト・オーバーレイ・表示確認に記録します。
+func (p *LowLatencyPipeline) recordPosted(author, text string) {
+	p.restriction.postSucceeded()
+	p.recorder.RecordReply(author, text)
+	p.writeTranscript(transcript.KindReply, "", "", author, text)
+	p.updateOverlay(author, text)
+	if p.verifier != nil {
+		p.verifier.track(text, time.Now())
 	}
 }
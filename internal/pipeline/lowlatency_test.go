@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"prompter-live-go/internal/types"
+	"prompter-live-go/internal/youtube"
+)
+
+func TestPollDelay(t *testing.T) {
+	tests := []struct {
+		name      string
+		suggested time.Duration
+		fallback  time.Duration
+		want      time.Duration
+	}{
+		{name: "api suggestion", suggested: 5 * time.Second, fallback: 10 * time.Second, want: 5 * time.Second},
+		{name: "sub-second suggestion is floored", suggested: 200 * time.Millisecond, fallback: 10 * time.Second, want: time.Second},
+		{name: "no suggestion uses fallback", suggested: 0, fallback: 10 * time.Second, want: 10 * time.Second},
+		{name: "negative suggestion uses fallback", suggested: -time.Second, fallback: 10 * time.Second, want: 10 * time.Second},
+		{name: "fallback is floored", suggested: 0, fallback: 0, want: 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pollDelay(tt.suggested, tt.fallback); got != tt.want {
+				t.Errorf("pollDelay(%v, %v) = %v, want %v", tt.suggested, tt.fallback, got, tt.want)
+			}
+		})
+	}
+}
+
+// countingSource はコメントの取得回数を数え、常に err を返すコメントソースです。
+type countingSource struct {
+	err     error
+	fetches int
+}
+
+func (s *countingSource) FetchLiveChatMessages(ctx context.Context) ([]youtube.Comment, time.Duration, error) {
+	s.fetches++
+	return nil, 0, s.err
+}
+func (s *countingSource) IsCommentDeleted(commentID string) bool { return false }
+
+// TestRunLoopPollsWithShortDigestInterval は、ダイジェストの間隔がポーリングの待ち時間より短くても
+// ダイジェストの処理でポーリングの待ち時間がやり直しにならず、ポーリングが続くことを確認します。
+func TestRunLoopPollsWithShortDigestInterval(t *testing.T) {
+	tests := []struct {
+		name           string
+		digestInterval time.Duration
+	}{
+		{name: "digest disabled", digestInterval: 0},
+		{name: "digest interval shorter than the poll delay", digestInterval: 5 * time.Millisecond},
+		{name: "digest interval much shorter than the poll delay", digestInterval: time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &countingSource{err: youtube.ErrLiveChatRestricted}
+			p := newTestPipeline(&stubResponder{}, &fakePoster{})
+			p.source = source
+			p.pipelineConfig.PollingInterval = 20 * time.Millisecond
+			p.pipelineConfig.ChatUnavailableRetry = 20 * time.Millisecond
+			p.pipelineConfig.DigestInterval = tt.digestInterval
+
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+			if err := p.runLoop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("runLoop() = %v, want context.DeadlineExceeded", err)
+			}
+			// 300ms の間に 20ms ごとに取得する (タイマーの遅れを見込んで少なめに判定する)
+			if source.fetches < 5 {
+				t.Errorf("fetches = %d in 300ms with a 20ms poll delay, want at least 5", source.fetches)
+			}
+		})
+	}
+}
+
+func TestRunLoopFlushesDigestOnExit(t *testing.T) {
+	tests := []struct {
+		name       string
+		buffered   int
+		wantPosted int
+	}{
+		{name: "buffered comments are answered", buffered: 2, wantPosted: 1},
+		{name: "empty buffer posts nothing", buffered: 0, wantPosted: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poster := &fakePoster{}
+			p := newTestPipeline(&stubResponder{resp: &types.LowLatencyResponse{ResponseText: "Thanks for all the comments!", Done: true}}, poster)
+			p.source = &countingSource{}
+			p.pipelineConfig.PollingInterval = time.Hour
+			p.pipelineConfig.DigestInterval = time.Hour
+			for i := range tt.buffered {
+				p.digest.add(youtube.Comment{ID: fmt.Sprintf("msg-%d", i), Author: "Alice", Message: "hello!"})
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := p.runLoop(ctx); !errors.Is(err, context.Canceled) {
+				t.Fatalf("runLoop() = %v, want context.Canceled", err)
+			}
+			if len(poster.posted) != tt.wantPosted {
+				t.Errorf("posted %q on exit, want %d digest reply(s)", poster.posted, tt.wantPosted)
+			}
+			if poster.noDeadline > 0 {
+				t.Error("the digest was posted without a deadline")
+			}
+			if len(p.digest.comments) != 0 {
+				t.Errorf("%d comments left in the digest buffer after exit", len(p.digest.comments))
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"syscall"
+	"time"
+)
+
+// queuedReply はネットワークエラーで投稿できず、接続の回復後に投稿を再試行する応答です (--offline-queue)。
+type queuedReply struct {
+	commentID string
+	author    string
+	text      string
+	queuedAt  time.Time
+}
+
+// offlineQueue は投稿待ちの応答を古い順に保持する上限付きのキューです。
+type offlineQueue struct {
+	replies []queuedReply
+}
+
+// push は応答を追加します。上限を超える場合は最も古い応答を破棄します。
+func (q *offlineQueue) push(r queuedReply, limit int) {
+	q.replies = append(q.replies, r)
+	if len(q.replies) > limit {
+		dropped := q.replies[0]
+		q.replies = q.replies[1:]
+		log.Printf("Offline queue is full; dropping the oldest pending reply to %s.", dropped.author)
+	}
+}
+
+// isNetworkError は投稿のリクエストが YouTube に届かなかったことが明らかなネットワークエラー
+// (接続の確立の失敗: 接続の拒否、名前解決の失敗、経路なしなど) かどうかを判定します。
+// 投稿内容や権限によるエラー (API のエラー応答) は再試行しても成功しないため含みません。
+// 接続後の切断 (ECONNRESET や応答の読み取り中の EOF) やタイムアウトは投稿が成功している可能性があるため
+// (isAmbiguousPostError)、二重投稿を避けて含みません。
+func isNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ENETUNREACH) || errors.Is(err, syscall.EHOSTUNREACH)
+}
+
+// queueOffline は --offline-queue が有効で、err がネットワークエラーの場合に応答をキューに追加し、true を返します。
+func (p *LowLatencyPipeline) queueOffline(commentID, author, text string, err error) bool {
+	if p.pipelineConfig.OfflineQueueSize <= 0 || !isNetworkError(err) {
+		return false
+	}
+	p.offline.push(queuedReply{commentID: commentID, author: author, text: text, queuedAt: time.Now()}, p.pipelineConfig.OfflineQueueSize)
+	log.Printf("Network error while posting the reply to %s; queued it to retry when connectivity returns (%d pending).", author, len(p.offline.replies))
+	return true
+}
+
+// flushOfflineQueue は接続が回復した (コメントの取得に成功した) 後に、キューの応答を古い順に投稿します。
+// OfflineQueueMaxAge を過ぎた応答は会話の流れに合わないため破棄します。
+// 再びネットワークエラーになった場合は残りを次の機会まで保持し、それ以外のエラーの応答は破棄します。
+func (p *LowLatencyPipeline) flushOfflineQueue(ctx context.Context) {
+	if len(p.offline.replies) == 0 {
+		return
+	}
+
+	pending := p.offline.replies
+	p.offline.replies = nil
+	for i, r := range pending {
+		if age := time.Since(r.queuedAt); age > p.pipelineConfig.OfflineQueueMaxAge {
+			log.Printf("Dropping queued reply to %s because it is %v old (--offline-queue-max-age %v).", r.author, age.Truncate(time.Second), p.pipelineConfig.OfflineQueueMaxAge)
+			continue
+		}
+
+		err := p.postComment(ctx, r.commentID, r.text)
+		if err == nil {
+			log.Printf("Posted queued reply to %s after connectivity returned.", r.author)
+			p.recordPosted(r.author, r.text)
+			continue
+		}
+		if isNetworkError(err) {
+			// まだ接続が不安定なため、残りは次の機会に投稿する
+			p.offline.replies = append(p.offline.replies, pending[i:]...)
+			log.Printf("Network is still unavailable; keeping %d queued replies: %v", len(p.offline.replies), err)
+			return
+		}
+		if !errors.Is(err, errDuplicatePost) {
+			log.Printf("Dropping queued reply to %s: %v", r.author, err)
+			p.recorder.RecordError()
+		}
+	}
+}
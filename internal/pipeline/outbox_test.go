@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// httpError は http.Client が返す形 (*url.Error) で err を包みます。
+func httpError(err error) error {
+	return &url.Error{Op: "Post", URL: "https://youtube.googleapis.com/youtube/v3/liveChat/messages", Err: err}
+}
+
+var (
+	errDialRefused = httpError(&net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}})
+	errDialTimeout = httpError(&net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}})
+	errDNS         = httpError(&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "youtube.googleapis.com", IsNotFound: true}})
+	errReadReset   = httpError(&net.OpError{Op: "read", Net: "tcp", Err: &os.SyscallError{Syscall: "read", Err: syscall.ECONNRESET}})
+	errWriteBroken = httpError(&net.OpError{Op: "write", Net: "tcp", Err: &os.SyscallError{Syscall: "write", Err: syscall.EPIPE}})
+	errReadEOF     = httpError(io.EOF)
+	errShortBody   = fmt.Errorf("failed to post comment to live chat: %w", io.ErrUnexpectedEOF)
+)
+
+func TestPostErrorClassification(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantNetwork   bool // 何も送信されていない (送信キューに入れて後で投稿してよい)
+		wantAmbiguous bool // 投稿済みの可能性がある (再送しない)
+	}{
+		{name: "nil", err: nil},
+		{name: "connection refused", err: errDialRefused, wantNetwork: true},
+		{name: "dial timeout", err: errDialTimeout, wantNetwork: true},
+		{name: "dns failure", err: errDNS, wantNetwork: true},
+		{name: "bare connection refused", err: syscall.ECONNREFUSED, wantNetwork: true},
+		{name: "connection reset while reading", err: errReadReset, wantAmbiguous: true},
+		{name: "broken pipe while writing", err: errWriteBroken, wantAmbiguous: true},
+		{name: "eof while reading the response", err: errReadEOF, wantAmbiguous: true},
+		{name: "unexpected eof", err: errShortBody, wantAmbiguous: true},
+		{name: "response timeout", err: httpError(timeoutError{}), wantAmbiguous: true},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, wantAmbiguous: true},
+		{name: "api error", err: &googleapi.Error{Code: http.StatusForbidden}},
+		{name: "other", err: errors.New("boom")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNetworkError(tt.err); got != tt.wantNetwork {
+				t.Errorf("isNetworkError(%v) = %v, want %v", tt.err, got, tt.wantNetwork)
+			}
+			if got := isAmbiguousPostError(tt.err); got != tt.wantAmbiguous {
+				t.Errorf("isAmbiguousPostError(%v) = %v, want %v", tt.err, got, tt.wantAmbiguous)
+			}
+		})
+	}
+}
+
+// TestOfflineQueueNeverRepostsAmbiguousErrors は、何も送信されていないエラーの応答だけを送信キューに入れて
+// 接続の回復後に投稿し、投稿済みの可能性がある応答は再送しないことを確認します。
+func TestOfflineQueueNeverRepostsAmbiguousErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantQueued int
+		wantCalls  int // 投稿の試行回数 (最初の投稿と接続の回復後の再送)
+		wantPosted int
+	}{
+		{name: "connection refused is queued and posted", err: errDialRefused, wantQueued: 1, wantCalls: 2, wantPosted: 1},
+		{name: "dns failure is queued and posted", err: errDNS, wantQueued: 1, wantCalls: 2, wantPosted: 1},
+		{name: "reset while reading is not reposted", err: errReadReset, wantCalls: 1},
+		{name: "eof while reading is not reposted", err: errReadEOF, wantCalls: 1},
+		{name: "unexpected eof is not reposted", err: errShortBody, wantCalls: 1},
+		{name: "timeout is not reposted", err: httpError(timeoutError{}), wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poster := &fakePoster{errs: []error{tt.err}}
+			p := newTestPipeline(&stubResponder{}, poster)
+			p.pipelineConfig.OfflineQueueSize = 10
+			p.pipelineConfig.OfflineQueueMaxAge = time.Hour
+			ctx := context.Background()
+
+			if p.postReply(ctx, "msg-1", "Alice", "Welcome!") {
+				t.Fatal("postReply() = true, want false for a failed post")
+			}
+			if got := len(p.offline.replies); got != tt.wantQueued {
+				t.Errorf("queued replies = %d, want %d", got, tt.wantQueued)
+			}
+
+			// 接続が回復した (コメントの取得に成功した) ものとして送信キューを処理する
+			p.flushOfflineQueue(ctx)
+			if poster.calls != tt.wantCalls {
+				t.Errorf("PostComment calls = %d, want %d", poster.calls, tt.wantCalls)
+			}
+			if len(poster.posted) != tt.wantPosted {
+				t.Errorf("posted %q, want %d reply(s)", poster.posted, tt.wantPosted)
+			}
+			if len(p.offline.replies) != 0 {
+				t.Errorf("%d replies left in the offline queue after the flush", len(p.offline.replies))
+			}
+		})
+	}
+}
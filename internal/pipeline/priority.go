@@ -0,0 +1,26 @@
+package pipeline
+
+import (
+	"sort"
+
+	"prompter-live-go/internal/youtube"
+)
+
+// prioritizeComments は応答待ちのコメントを優先度順に並べ替えます (--prioritize)。
+// Super Chat の金額の高い順、モデレーター (所有者を含む)、メンバーの順に優先し、
+// 同じ優先度のコメントは到着順 (FIFO) を維持します。
+func prioritizeComments(comments []youtube.Comment) {
+	sort.SliceStable(comments, func(i, j int) bool {
+		a, b := comments[i], comments[j]
+		if a.SuperChatAmountMicros != b.SuperChatAmountMicros {
+			return a.SuperChatAmountMicros > b.SuperChatAmountMicros
+		}
+		if am, bm := a.IsModerator || a.IsOwner, b.IsModerator || b.IsOwner; am != bm {
+			return am
+		}
+		if a.IsMember != b.IsMember {
+			return a.IsMember
+		}
+		return false
+	})
+}
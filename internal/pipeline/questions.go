@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"prompter-live-go/internal/state"
+	"prompter-live-go/internal/youtube"
+)
+
+// answeredQuestion は最近応答した質問の応答時刻と応答内容です。
+// 状態のストア (--state-store) に JSON として保存し、Redis を共有するインスタンス間でも繰り返しを判定できるようにします。
+type answeredQuestion struct {
+	AnsweredAt time.Time `json:"answered_at"`
+	Reply      string    `json:"reply"`
+}
+
+// questionStoreKey は正規化した質問文のハッシュから、状態のストアのキーを作成します。
+// 前の配信で応答した質問を新しい配信で繰り返しと扱わないよう、ライブチャットID (不明な場合は空) をキーに含めます。
+func questionStoreKey(chatID string, key uint64) string {
+	if chatID == "" {
+		return fmt.Sprintf("question:%016x", key)
+	}
+	return fmt.Sprintf("question:%s:%016x", chatID, key)
+}
+
+// SetStateStore は最近応答した質問 (--question-cooldown) などの状態の保存先を設定します。Run の開始前に呼び出す必要があります。
+func (p *LowLatencyPipeline) SetStateStore(store state.Store) {
+	p.state = store
+}
+
+// questionKey はコメントが質問であれば、正規化した質問文のハッシュを返します。
+// 正規化では大文字小文字・空白・句読点・記号 (絵文字を含む) の違いを無視します。
+// 質問でない (挨拶などの) コメントは繰り返されても応答すべきため、ok は false になります。
+func questionKey(message string) (key uint64, ok bool) {
+	if !isQuestion(message) {
+		return 0, false
+	}
+
+	var sb strings.Builder
+	for _, r := range strings.ToLower(message) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			sb.WriteRune(r)
+		}
+	}
+	if sb.Len() == 0 {
+		return 0, false
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(sb.String()))
+	return h.Sum64(), true
+}
+
+// isQuestion はコメントが質問らしいかどうかを判定します (疑問符、または日本語の疑問の文末)。
+func isQuestion(message string) bool {
+	if strings.ContainsAny(message, "?？") {
+		return true
+	}
+	trimmed := strings.TrimRightFunc(message, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+	})
+	for _, suffix := range []string{"ですか", "ますか", "でしょうか", "かな"} {
+		if strings.HasSuffix(trimmed, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// repeatedQuestion は --question-cooldown 以内に (投稿者に関わらず) 応答済みの質問であれば、その応答を返します。
+// ストアの読み込みに失敗した場合は、繰り返しではないものとして応答を生成します。
+func (p *LowLatencyPipeline) repeatedQuestion(ctx context.Context, comment youtube.Comment) (answeredQuestion, bool) {
+	if p.pipelineConfig.QuestionCooldown <= 0 {
+		return answeredQuestion{}, false
+	}
+	key, ok := questionKey(comment.Message)
+	if !ok {
+		return answeredQuestion{}, false
+	}
+
+	value, found, err := p.state.Get(ctx, questionStoreKey(p.streamChatID, key))
+	if err != nil {
+		log.Printf("Warning: Failed to look up repeated question in state store: %v", err)
+		return answeredQuestion{}, false
+	}
+	if !found {
+		return answeredQuestion{}, false
+	}
+	var prior answeredQuestion
+	if err := json.Unmarshal([]byte(value), &prior); err != nil {
+		log.Printf("Warning: Ignoring malformed answered question in state store: %v", err)
+		return answeredQuestion{}, false
+	}
+	return prior, true
+}
+
+// recordAnswer はコメントが質問であれば、投稿した応答を記録します (--question-cooldown 指定時のみ)。
+// 有効期限は --question-cooldown で、期限切れのエントリはストアが削除します。
+func (p *LowLatencyPipeline) recordAnswer(ctx context.Context, comment youtube.Comment, reply string) {
+	if p.pipelineConfig.QuestionCooldown <= 0 {
+		return
+	}
+	key, ok := questionKey(comment.Message)
+	if !ok {
+		return
+	}
+	value, err := json.Marshal(answeredQuestion{AnsweredAt: time.Now(), Reply: reply})
+	if err != nil {
+		return
+	}
+	if err := p.state.Put(ctx, questionStoreKey(p.streamChatID, key), string(value), p.pipelineConfig.QuestionCooldown); err != nil {
+		log.Printf("Warning: Failed to record answered question in state store: %v", err)
+	}
+}
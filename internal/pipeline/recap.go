@@ -0,0 +1,205 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"prompter-live-go/internal/types"
+)
+
+// まとめ (!bot recap / --recap-on-exit) に関する設定
+const (
+	// recapTopQuestions はまとめに含める質問の最大数です。
+	recapTopQuestions = 5
+	// recapMaxQuestions は記録する (正規化して) 異なる質問の最大数です。超えた分は記録しません。
+	recapMaxQuestions = 500
+	// recapMaxChunks はまとめを分割して投稿する最大のメッセージ数です。
+	recapMaxChunks = 3
+	// recapExitTimeout は終了時のまとめ (とダイジェスト) の生成と投稿を待つ最大時間です。
+	recapExitTimeout = 30 * time.Second
+)
+
+// recapQuestion は配信中に寄せられた 1 つの (正規化して同じ) 質問の記録です。
+type recapQuestion struct {
+	// question は最初に寄せられた質問文です。
+	question string
+	// count は同じ質問が寄せられた回数です。
+	count int
+	// answer は直近に投稿した応答です。まだ応答していない場合は空です。
+	answer string
+	// firstAsked は最初に寄せられた時刻です (回数が同じ質問の並び順に使用)。
+	firstAsked time.Time
+}
+
+// recapLog は配信中に寄せられた質問を、正規化した質問文のハッシュ (questionKey) ごとに集計します。
+type recapLog struct {
+	questions map[uint64]*recapQuestion
+}
+
+// noteQuestion はコメントが質問であれば、寄せられた回数を記録します。
+func (r *recapLog) noteQuestion(message string, now time.Time) {
+	key, ok := questionKey(message)
+	if !ok {
+		return
+	}
+	if q, ok := r.questions[key]; ok {
+		q.count++
+		return
+	}
+	if r.questions == nil {
+		r.questions = make(map[uint64]*recapQuestion)
+	}
+	if len(r.questions) >= recapMaxQuestions {
+		return
+	}
+	r.questions[key] = &recapQuestion{question: message, count: 1, firstAsked: now}
+}
+
+// noteAnswer はコメントが記録済みの質問であれば、投稿した応答を記録します。
+func (r *recapLog) noteAnswer(message, reply string) {
+	key, ok := questionKey(message)
+	if !ok {
+		return
+	}
+	if q, ok := r.questions[key]; ok {
+		q.answer = reply
+	}
+}
+
+// top は寄せられた回数の多い順 (同数の場合は早く寄せられた順) に最大 n 件の質問を返します。
+func (r *recapLog) top(n int) []recapQuestion {
+	questions := make([]recapQuestion, 0, len(r.questions))
+	for _, q := range r.questions {
+		questions = append(questions, *q)
+	}
+	sort.Slice(questions, func(i, j int) bool {
+		if questions[i].count != questions[j].count {
+			return questions[i].count > questions[j].count
+		}
+		return questions[i].firstAsked.Before(questions[j].firstAsked)
+	})
+	if len(questions) > n {
+		questions = questions[:n]
+	}
+	return questions
+}
+
+// buildRecapPrompt はよく寄せられた質問とその応答から、まとめを生成させるプロンプトを組み立てます。
+func buildRecapPrompt(questions []recapQuestion, language string) string {
+	var sb strings.Builder
+	sb.WriteString("The stream is wrapping up. Here are the questions viewers asked most often during the stream, with how many times each was asked and the answer you gave:\n")
+	for _, q := range questions {
+		answer := q.answer
+		if answer == "" {
+			answer = "(not answered)"
+		}
+		fmt.Fprintf(&sb, "- (%dx) Q: %s\n  A: %s\n", q.count, q.question, answer)
+	}
+	fmt.Fprintf(&sb, "Write a short recap of these top questions and their answers for the live chat. Keep it under %d characters.", maxReplyLength*recapMaxChunks)
+	if language != "" {
+		fmt.Fprintf(&sb, "\n(Respond in %s.)", language)
+	}
+	return sb.String()
+}
+
+// PostRecap はよく寄せられた質問とその応答のまとめを生成し、ライブチャットに投稿します。
+// まとめが 1 件のメッセージの上限を超える場合は、文の区切りで最大 recapMaxChunks 件に分けて投稿します。
+func (p *LowLatencyPipeline) PostRecap(ctx context.Context) {
+	questions := p.recap.top(recapTopQuestions)
+	if len(questions) == 0 {
+		log.Println("No questions were asked during the stream. Skipping recap.")
+		return
+	}
+
+	log.Printf("Generating recap of the top %d questions.", len(questions))
+	resp, err := p.responder.GenerateResponse(ctx, types.LiveStreamData{
+		Text:   p.withActionInstruction(buildRecapPrompt(questions, p.pipelineConfig.ResponseLanguage)),
+		Author: "recap",
+	})
+	if err != nil {
+		log.Printf("Error generating recap: %v", err)
+		p.recorder.RecordError()
+		return
+	}
+	p.recorder.RecordTokens(resp.PromptTokens, resp.ResponseTokens)
+
+	text := resp.ResponseText
+	if p.geminiConfig.StructuredActions {
+		answer, ok := p.resolveAction(text)
+		if !ok {
+			log.Println("Model declined to post a recap.")
+			return
+		}
+		text = answer
+	}
+	text = sanitizeReply(text, p.metaPatterns())
+	if p.pipelineConfig.NormalizeWidth {
+		text = normalizeWidth(text)
+	}
+	if text == "" {
+		p.recorder.RecordSkip(skipEmptyResponse)
+		return
+	}
+
+	for _, chunk := range chunkReply(text, maxReplyLength, recapMaxChunks) {
+		if !p.postReply(ctx, "recap", "recap", chunk) {
+			return
+		}
+	}
+}
+
+// postRecapOnExit は --recap-on-exit 指定時に、シャットダウン時のまとめを投稿します。
+// アプリケーションのコンテキストはキャンセル済みのため、独自のタイムアウト付きコンテキストを使用します。
+func (p *LowLatencyPipeline) postRecapOnExit() {
+	if !p.pipelineConfig.RecapOnExit {
+		return
+	}
+	if p.youtubeClient != nil && !p.youtubeClient.HasActiveChat() && !p.pipelineConfig.NoPost {
+		log.Println("Live chat is no longer active. Skipping recap.")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), recapExitTimeout)
+	defer cancel()
+	p.PostRecap(ctx)
+}
+
+// chunkReply は text を max 文字以下のメッセージに分割します。できるだけ文の区切り、次に空白で分け、
+// maxChunks 件を超える部分は最後のメッセージを切り詰めます。
+func chunkReply(text string, max, maxChunks int) []string {
+	var chunks []string
+	runes := []rune(strings.TrimSpace(text))
+	for len(runes) > 0 {
+		if len(runes) <= max {
+			chunks = append(chunks, string(runes))
+			break
+		}
+		if len(chunks) == maxChunks-1 {
+			chunks = append(chunks, truncateReply(string(runes), max))
+			break
+		}
+
+		cut := chunkCut(runes, max)
+		chunks = append(chunks, strings.TrimSpace(string(runes[:cut])))
+		runes = []rune(strings.TrimSpace(string(runes[cut:])))
+	}
+	return chunks
+}
+
+// chunkCut は runes の先頭 max 文字以内で分割する位置を返します (文末、空白、上限の順に探す)。
+func chunkCut(runes []rune, max int) int {
+	for i := max - 1; i >= max/2; i-- {
+		if isSentenceEnd(runes, i) {
+			return i + 1
+		}
+	}
+	for i := max - 1; i >= max/2; i-- {
+		if runes[i] == ' ' || runes[i] == '　' {
+			return i + 1
+		}
+	}
+	return max
+}
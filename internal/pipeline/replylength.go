@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+
+	"prompter-live-go/internal/youtube"
+)
+
+// replyLimit はコメントへの応答の文字数の上限を返します。
+// --reply-length-factor が指定されている場合はコメントの長さに比例させ (短いコメントには短く、詳しい質問には長く)、
+// --reply-length-min〜--reply-length-max の範囲に収めます。指定されていない場合は maxReplyLength です。
+func (p *LowLatencyPipeline) replyLimit(comment youtube.Comment) int {
+	factor := p.pipelineConfig.ReplyLengthFactor
+	if factor <= 0 {
+		return maxReplyLength
+	}
+	limit := int(factor * float64(len([]rune(comment.Message))))
+	limit = max(limit, p.pipelineConfig.ReplyLengthMin)
+	limit = min(limit, p.pipelineConfig.ReplyLengthMax, maxReplyLength)
+	return limit
+}
+
+// withLengthHint は応答の文字数の上限が maxReplyLength より小さい場合に、その長さに収めるようプロンプトに指示を付与します。
+func withLengthHint(prompt string, limit int) string {
+	if limit >= maxReplyLength {
+		return prompt
+	}
+	return fmt.Sprintf("%s\n(Keep the reply under %d characters.)", prompt, limit)
+}
+
+// truncateAtSentence は text を max 文字以内に切り詰めます。上限の手前に文末があれば文の途中で切らずにそこで切り、
+// 残る文が短くなりすぎる (上限の半分未満) 場合は truncateReply と同じく末尾に "…" を付けて切り詰めます。
+func truncateAtSentence(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	for i := max - 1; i >= max/2; i-- {
+		if isSentenceEnd(runes, i) {
+			return strings.TrimSpace(string(runes[:i+1]))
+		}
+	}
+	return truncateReply(text, max)
+}
@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"prompter-live-go/internal/gemini"
+	"prompter-live-go/internal/types"
+)
+
+// Responder はコメントに対する AI 応答を生成するバックエンドのインターフェースです。
+// 既定では Gemini のセッションを使用しますが、OpenAI 互換 API などの実装に差し替えられます。
+type Responder interface {
+	// GenerateResponse は data (投稿者とプロンプト) に対する完全な応答を返します。
+	// 応答が空の場合は ResponseText が空の LowLatencyResponse を返します。
+	GenerateResponse(ctx context.Context, data types.LiveStreamData) (*types.LowLatencyResponse, error)
+}
+
+// sessionResponder は gemini.Session を Responder として利用するためのアダプターです。
+type sessionResponder struct {
+	session gemini.Session
+}
+
+// GenerateResponse はメッセージをセッションに送信し、完全な応答を待って返します。
+func (r *sessionResponder) GenerateResponse(ctx context.Context, data types.LiveStreamData) (*types.LowLatencyResponse, error) {
+	if err := r.session.Send(ctx, data); err != nil {
+		return nil, fmt.Errorf("failed to send message to Gemini: %w", err)
+	}
+
+	// RecvResponse は完全な応答が来るまで待機し、一度だけ返します。
+	resp, err := r.session.RecvResponse()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			// ストリーム完了（正常終了）だが応答がない場合は空の応答として扱う
+			return &types.LowLatencyResponse{Done: true}, nil
+		}
+		return nil, fmt.Errorf("failed to receive Gemini response: %w", err)
+	}
+	if resp.Err != nil {
+		return nil, fmt.Errorf("gemini generation failed: %w", resp.Err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"prompter-live-go/internal/stats"
+	"prompter-live-go/internal/types"
+	"prompter-live-go/internal/youtube"
+)
+
+// stubResponder は常に同じ応答 (またはエラー) を返す Responder です。
+type stubResponder struct {
+	resp *types.LowLatencyResponse
+	err  error
+}
+
+func (r *stubResponder) GenerateResponse(ctx context.Context, data types.LiveStreamData) (*types.LowLatencyResponse, error) {
+	return r.resp, r.err
+}
+
+// stubSession は RecvResponse で常に同じ応答を返す gemini.Session です。
+type stubSession struct {
+	resp *types.LowLatencyResponse
+}
+
+func (s *stubSession) Send(ctx context.Context, data types.LiveStreamData) error { return nil }
+func (s *stubSession) RecvResponse() (*types.LowLatencyResponse, error)          { return s.resp, nil }
+func (s *stubSession) Close()                                                    {}
+
+// newTestPipeline は応答の生成に responder を使用し、投稿を poster に記録するパイプラインを作成します。
+func newTestPipeline(responder Responder, poster commentPoster) *LowLatencyPipeline {
+	p := NewLowLatencyPipeline(nil, responder, nil, nil, types.LiveAPIConfig{}, types.PipelineConfig{ReplyProbability: 1}, stats.NewRecorder())
+	p.poster = poster
+	return p
+}
+
+func TestErroredGenerationIsNeverPosted(t *testing.T) {
+	genErr := errors.New("Error: rpc error: code = Unavailable")
+	tests := []struct {
+		name       string
+		responder  Responder
+		wantPosted int
+	}{
+		{
+			name:      "response with Err",
+			responder: &stubResponder{resp: &types.LowLatencyResponse{Err: genErr, Done: true}},
+		},
+		{
+			// Err が設定されていれば、ResponseText に何が入っていても投稿しない
+			name:      "response with Err and text",
+			responder: &stubResponder{resp: &types.LowLatencyResponse{ResponseText: "Error: " + genErr.Error(), Err: genErr, Done: true}},
+		},
+		{
+			name:      "responder error",
+			responder: &stubResponder{err: genErr},
+		},
+		{
+			name:      "session response with Err",
+			responder: &sessionResponder{session: &stubSession{resp: &types.LowLatencyResponse{Err: genErr, Done: true}}},
+		},
+		{
+			// 比較用: 正常な応答は投稿される
+			name:       "successful response",
+			responder:  &stubResponder{resp: &types.LowLatencyResponse{ResponseText: "ありがとう！", Done: true}},
+			wantPosted: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poster := &fakePoster{}
+			p := newTestPipeline(tt.responder, poster)
+
+			p.processComment(context.Background(), youtube.Comment{ID: "comment-1", Author: "viewer", Message: "こんにちは"})
+
+			if poster.calls != tt.wantPosted {
+				t.Fatalf("PostComment called %d times (%q), want %d", poster.calls, poster.posted, tt.wantPosted)
+			}
+		})
+	}
+}
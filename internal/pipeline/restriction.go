@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"log"
+	"time"
+)
+
+// defaultRestrictionProbeInterval は投稿が制限されている間に、投稿を再試行するまでの既定の間隔です。
+// --chat-unavailable-retry が 0 の場合に使用します。
+const defaultRestrictionProbeInterval = time.Minute
+
+// chatRestriction は配信途中でチャットが登録者限定・メンバー限定に切り替わったことを追跡します。
+// 読み取りと投稿のどちらで権限エラーが出たかを区別し、状態が変わったときだけログに出力します。
+type chatRestriction struct {
+	// readable は直近のコメント取得が成功したかどうかです。
+	readable bool
+	// readRestricted は取得中に権限エラーが発生している状態かどうかです。
+	readRestricted bool
+
+	// postPausedUntil は投稿の権限エラーにより、応答の生成・投稿を見合わせる期限です。
+	postPausedUntil time.Time
+	// postRestricted は投稿の権限エラーが発生している状態かどうかです。
+	postRestricted bool
+}
+
+// fetchSucceeded はコメントの取得に成功したことを記録します。
+func (r *chatRestriction) fetchSucceeded() {
+	if r.readRestricted {
+		log.Println("Live chat is readable again; it is no longer restricted for this account.")
+		r.readRestricted = false
+	}
+	r.readable = true
+}
+
+// fetchRestricted はコメントの取得で権限エラーが発生したことを記録します。
+// それまで取得できていた場合は、配信途中での制限への切り替えとしてログに出力します。
+func (r *chatRestriction) fetchRestricted() {
+	if r.readable && !r.readRestricted {
+		log.Println("Live chat switched to subscribers/members-only mid-stream. The bot account cannot read it until the restriction is lifted or the account joins.")
+	}
+	r.readRestricted = true
+	r.readable = false
+}
+
+// postForbidden は投稿で権限エラーが発生したことを記録し、probe の間だけ応答を見合わせます。
+func (r *chatRestriction) postForbidden(now time.Time, probe time.Duration) {
+	if probe <= 0 {
+		probe = defaultRestrictionProbeInterval
+	}
+	if !r.postRestricted {
+		log.Printf("Posting to live chat is now forbidden (likely switched to subscribers/members-only mid-stream). Pausing replies; retrying every %v.", probe)
+	}
+	r.postRestricted = true
+	r.postPausedUntil = now.Add(probe)
+}
+
+// postSucceeded は投稿に成功したことを記録します。
+func (r *chatRestriction) postSucceeded() {
+	if r.postRestricted {
+		log.Println("Posting to live chat works again. Resuming replies.")
+		r.postRestricted = false
+		r.postPausedUntil = time.Time{}
+	}
+}
+
+// postingPaused は投稿の権限エラーにより応答を見合わせている最中かどうかを返します。
+func (r *chatRestriction) postingPaused(now time.Time) bool {
+	return r.postRestricted && now.Before(r.postPausedUntil)
+}
@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// maxAuthorNameLength はプロンプトとログに使用する投稿者名の最大文字数 (rune 数) です。
+const maxAuthorNameLength = 50
+
+// sanitizeAuthor はプロンプトへの埋め込みやログ出力の前に投稿者名を整えます。
+// 制御文字・書式文字 (改行や文字方向の上書きなど) を取り除き、連続する空白を 1 つにまとめ、
+// maxAuthorNameLength を超える部分を切り詰めます。
+func sanitizeAuthor(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsSpace(r):
+			sb.WriteRune(' ')
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			// 制御文字・書式文字は取り除く
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	cleaned := strings.Join(strings.Fields(sb.String()), " ")
+	runes := []rune(cleaned)
+	if len(runes) > maxAuthorNameLength {
+		cleaned = string(runes[:maxAuthorNameLength]) + "…"
+	}
+	return cleaned
+}
+
+// defaultMetaPatterns は応答の先頭から取り除くメタ的な前置き (--strip-meta) の既定のパターンです。
+var defaultMetaPatterns = mustCompileMetaPatterns([]string{
+	// "Sure! Here's a response:" / "Here is my reply:"
+	`(?:sure|okay|ok|certainly|of course|absolutely)?[!,.]?\s*here(?:'s| is) (?:a |an |my |the )?(?:possible |suggested )?(?:response|reply|answer)[^:\n]*:`,
+	// "As an AI language model, ..."
+	`as an ai(?: language model| assistant)?[^,.!]*[,.!]`,
+	// "Response:" / "Reply:"
+	`(?:response|reply|answer)\s*:`,
+	// 「はい、以下が返信です：」「こちらが回答になります。」
+	`(?:はい|了解(?:しました)?|承知(?:しました|いたしました)?|かしこまりました)?[!！、。]?\s*(?:以下が|こちらが)[^:：\n。]*(?:返信|返答|応答|回答)(?:です|になります)?[:：。]`,
+	// 「AIとして、」「AIなので、」
+	`(?:AI|ＡＩ)(?:として|なので|ですので)[^、。]*[、。]`,
+	// 「返信：」「回答例：」
+	`(?:返信|返答|応答|回答)(?:案|例)?\s*[:：]`,
+})
+
+// CompileMetaPatterns は応答の先頭から取り除くメタ的な前置きのパターン (正規表現) をコンパイルします。
+// 文中の同じ語句を取り除かないよう、各パターンは応答の先頭にのみ一致するよう固定され、大文字小文字を区別しません。
+func CompileMetaPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(`(?i)^(?:` + pattern + `)`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid meta pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// mustCompileMetaPatterns は CompileMetaPatterns と同じですが、エラーの場合はパニックします。
+func mustCompileMetaPatterns(patterns []string) []*regexp.Regexp {
+	compiled, err := CompileMetaPatterns(patterns)
+	if err != nil {
+		panic(err)
+	}
+	return compiled
+}
+
+// sanitizeReply は投稿前に AI 応答を整えます。
+// 前後の空白と応答全体を囲むコードブロックを取り除き、metaPatterns が指定されている場合は
+// 先頭のメタ的な前置き ("Sure! Here's a response:" など) を取り除きます。
+func sanitizeReply(text string, metaPatterns []*regexp.Regexp) string {
+	text = stripCodeFence(strings.TrimSpace(text))
+
+	// 前置きが複数重なる場合 ("Sure! Here's a reply: Response: ...") もあるため、一致しなくなるまで繰り返す
+	for stripped := true; stripped; {
+		stripped = false
+		for _, re := range metaPatterns {
+			if loc := re.FindStringIndex(text); loc != nil && loc[1] > 0 {
+				text = strings.TrimSpace(text[loc[1]:])
+				stripped = true
+			}
+		}
+	}
+	return text
+}
+
+// stripCodeFence は応答全体が 1 つの ``` で囲まれている場合に、コードブロックの記号 (と言語名) を取り除きます。
+// 応答が 2 つ以上のコードブロックからなる場合 ("```a``` 説明 ```b```") は、先頭と末尾の記号を
+// 1 つのブロックとみなすと間の文章まで崩れるため、そのまま返します。
+// 出力が途中で打ち切られ閉じる記号がない場合は、開始の記号 (と言語名) のみを取り除きます。
+func stripCodeFence(text string) string {
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	inner := strings.TrimPrefix(text, "```")
+	if strings.HasSuffix(inner, "```") {
+		inner = strings.TrimSuffix(inner, "```")
+	} else if strings.Contains(inner, "```") {
+		// 閉じる記号が末尾以外にある (後ろに文章が続く) 場合は応答全体のブロックではない
+		return text
+	}
+	if strings.Contains(inner, "```") {
+		return text
+	}
+	// 開始行の言語名 (```text など) を取り除く
+	if first, rest, ok := strings.Cut(inner, "\n"); ok && !strings.ContainsAny(strings.TrimSpace(first), " \t") {
+		inner = rest
+	}
+	return strings.TrimSpace(inner)
+}
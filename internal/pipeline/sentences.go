@@ -0,0 +1,99 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"prompter-live-go/internal/youtube"
+)
+
+// maxReplyLength は投稿する応答の最大文字数 (rune 数) です。YouTube ライブチャットのメッセージ上限 (200 文字) に合わせています。
+// --max-sentences による文数の制限に関わらず、常に適用されます。
+const maxReplyLength = youtube.MaxMessageLength
+
+// withSentenceLimit は --max-sentences が指定されている場合に、文数を制限する指示をプロンプトに付与します。
+func (p *LowLatencyPipeline) withSentenceLimit(prompt string) string {
+	if p.pipelineConfig.MaxSentences <= 0 {
+		return prompt
+	}
+	return fmt.Sprintf("%s\n(Answer in at most %d sentence(s).)", prompt, p.pipelineConfig.MaxSentences)
+}
+
+// finishReply は投稿前の応答に整形 (sanitizeReply、--normalize-width)、文数の制限 (--max-sentences)、
+// 絵文字の方針 (--emoji-policy)、文字数の上限 limit (maxReplyLength 以下) を適用します。
+func (p *LowLatencyPipeline) finishReply(text string, limit int) string {
+	text = sanitizeReply(text, p.metaPatterns())
+	if p.pipelineConfig.NormalizeWidth {
+		text = normalizeWidth(text)
+	}
+	if p.pipelineConfig.MaxSentences > 0 {
+		text = limitSentences(text, p.pipelineConfig.MaxSentences)
+	}
+	if limit < maxReplyLength {
+		// コメントの長さに応じた上限 (--reply-length-factor) では、できるだけ文の区切りで切る
+		text = truncateAtSentence(text, limit)
+	}
+	return p.applyEmojiPolicy(truncateReply(text, limit), limit)
+}
+
+// limitSentences は text を先頭から n 文までに切り詰めます。
+// 文末は日本語の 。！？ と英語の . ! ? で判定し、続けて現れる文末記号 ("!?" や "...") と閉じ括弧・引用符は同じ文に含めます。
+// 英語のピリオドは数字の間 ("3.5") や直後に空白がない場合 ("e.g") には文末とみなしません。
+func limitSentences(text string, n int) string {
+	runes := []rune(text)
+	count := 0
+	for i := 0; i < len(runes); i++ {
+		if !isSentenceEnd(runes, i) {
+			continue
+		}
+		// 続く文末記号・閉じ括弧を同じ文に含める
+		end := i + 1
+		for end < len(runes) && (isSentenceTerminator(runes[end]) || isClosingPunct(runes[end])) {
+			end++
+		}
+		count++
+		if count >= n {
+			return strings.TrimSpace(string(runes[:end]))
+		}
+		i = end - 1
+	}
+	return text
+}
+
+// isSentenceEnd は runes[i] が文末記号として文を終えるかどうかを判定します。
+func isSentenceEnd(runes []rune, i int) bool {
+	switch runes[i] {
+	case '。', '！', '？', '!', '?':
+		return true
+	case '.':
+		if i+1 >= len(runes) {
+			return true
+		}
+		next := runes[i+1]
+		if i > 0 && unicode.IsDigit(runes[i-1]) && unicode.IsDigit(next) {
+			return false
+		}
+		return unicode.IsSpace(next) || isSentenceTerminator(next) || isClosingPunct(next)
+	}
+	return false
+}
+
+// isSentenceTerminator は r が文末記号かどうかを判定します。
+func isSentenceTerminator(r rune) bool {
+	return strings.ContainsRune("。！？!?.．", r)
+}
+
+// isClosingPunct は r が文末記号の直後に置かれる閉じ括弧・引用符かどうかを判定します。
+func isClosingPunct(r rune) bool {
+	return strings.ContainsRune("」』）)\"'”’】", r)
+}
+
+// truncateReply は text が max 文字 (rune 数) を超える場合に、末尾を "…" にして max 文字に切り詰めます。
+func truncateReply(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
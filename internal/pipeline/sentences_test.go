@@ -0,0 +1,66 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+
+	"prompter-live-go/internal/types"
+)
+
+func TestLimitSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		n    int
+		want string
+	}{
+		{"日本語の句点", "こんにちは。元気ですか？今日は晴れです。", 2, "こんにちは。元気ですか？"},
+		{"英語の文末", "Hello there. How are you? Great!", 2, "Hello there. How are you?"},
+		{"日英混在", "こんにちは！How are you? 元気です。", 2, "こんにちは！How are you?"},
+		{"日英混在で1文", "Thanks! ありがとう。", 1, "Thanks!"},
+		{"連続する文末記号", "本当に!? すごいですね。", 1, "本当に!?"},
+		{"三点リーダ風のピリオド", "Well... I think so. Maybe.", 1, "Well..."},
+		{"閉じ括弧を含める", "「ありがとう！」と言われました。嬉しいです。", 1, "「ありがとう！」"},
+		{"閉じ引用符を含める", `He said "wow." Then left.`, 1, `He said "wow."`},
+		{"小数点は文末ではない", "Version 3.5 is out. Try it!", 1, "Version 3.5 is out."},
+		{"空白のないピリオドは文末ではない", "Use e.g.this one. Or that.", 1, "Use e.g.this one."},
+		{"全角感嘆符と半角疑問符", "すごい！本当? うん。", 2, "すごい！本当?"},
+		{"文数より大きい n", "一文目。二文目。", 5, "一文目。二文目。"},
+		{"文末記号なし", "文末記号のない応答", 1, "文末記号のない応答"},
+		{"末尾のピリオド", "Only one sentence.", 1, "Only one sentence."},
+		{"空文字列", "", 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := limitSentences(tt.text, tt.n); got != tt.want {
+				t.Errorf("limitSentences(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFinishReplyKeepsCharacterCapWithSentenceLimit(t *testing.T) {
+	long := strings.Repeat("あ", maxReplyLength+50) + "。"
+	tests := []struct {
+		name         string
+		text         string
+		maxSentences int
+		want         string
+	}{
+		{"文数で切り詰め", "こんにちは！How are you? 元気です。", 2, "こんにちは！How are you?"},
+		{"長い1文は文字数上限で切る", long + "二文目。", 1, strings.Repeat("あ", maxReplyLength-1) + "…"},
+		{"制限なし", "一文目。二文目。三文目。", 0, "一文目。二文目。三文目。"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &LowLatencyPipeline{pipelineConfig: types.PipelineConfig{MaxSentences: tt.maxSentences}}
+			got := p.finishReply(tt.text, maxReplyLength)
+			if got != tt.want {
+				t.Errorf("finishReply(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+			if n := len([]rune(got)); n > maxReplyLength {
+				t.Errorf("finishReply returned %d runes, want at most %d", n, maxReplyLength)
+			}
+		})
+	}
+}
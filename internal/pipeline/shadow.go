@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"prompter-live-go/internal/gemini"
+	"prompter-live-go/internal/transcript"
+	"prompter-live-go/internal/types"
+	"prompter-live-go/internal/youtube"
+)
+
+// SetShadowClient はシャドーモード (--shadow-instruction-file) で使用する、別のシステム指示を設定した Gemini Client を設定します。
+// 設定した場合、コメントごとにこの Client でも応答を生成してログとトランスクリプトに記録します (投稿はしません)。
+// Run の開始前に設定する必要があります。Client のクローズは呼び出し元が行います。
+func (p *LowLatencyPipeline) SetShadowClient(client *gemini.Client) {
+	p.shadowClient = client
+}
+
+// startShadowSession はシャドーモードの Gemini セッションを開始します。
+func (p *LowLatencyPipeline) startShadowSession(ctx context.Context) (gemini.Session, error) {
+	session, err := p.shadowClient.StartSession(ctx, p.geminiConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start shadow Gemini session: %w", err)
+	}
+	return session, nil
+}
+
+// startShadow はシャドーモードの場合に、本来の応答と並行してシャドーのシステム指示での応答の生成を開始します。
+// 返す関数は生成の完了を待ち、結果をログとトランスクリプトに記録します (パイプラインのゴルーチンから呼び出します)。
+func (p *LowLatencyPipeline) startShadow(ctx context.Context, comment youtube.Comment, data types.LiveStreamData) func() {
+	if p.shadow == nil {
+		return func() {}
+	}
+
+	var resp *types.LowLatencyResponse
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err = p.shadow.GenerateResponse(ctx, data)
+	}()
+
+	return func() {
+		<-done
+		p.recordShadow(comment, resp, err)
+	}
+}
+
+// recordShadow はシャドーのシステム指示で生成した応答を、投稿する応答と同じ整形をしたうえで記録します。
+func (p *LowLatencyPipeline) recordShadow(comment youtube.Comment, resp *types.LowLatencyResponse, err error) {
+	if err == nil && resp.Err != nil {
+		err = resp.Err
+	}
+	if err != nil {
+		log.Printf("[shadow] Error generating shadow reply for %s: %v", comment.Author, err)
+		return
+	}
+	p.recorder.RecordTokens(resp.PromptTokens, resp.ResponseTokens)
+
+	text := resp.ResponseText
+	if p.geminiConfig.StructuredActions && text != "" {
+		answer, ok, err := parseAction(text)
+		if err != nil {
+			log.Printf("[shadow] %v", err)
+		}
+		if !ok {
+			text = ""
+		} else {
+			text = answer
+		}
+	}
+	text = p.finishReply(text, p.replyLimit(comment))
+
+	log.Printf("[shadow] Would reply to %s: %s", comment.Author, text)
+	p.writeTranscript(transcript.KindShadow, comment.ID, comment.AuthorID, comment.Author, text)
+}
@@ -0,0 +1,41 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+
+	"prompter-live-go/internal/youtube"
+)
+
+// CommentSource はパイプラインにコメントを供給する読み取り側のインターフェースです。
+// youtube.Client が標準の実装ですが、Twitch やローカルのテスト用ソースなども実装できます。
+type CommentSource interface {
+	// FetchLiveChatMessages は前回の呼び出し以降の新しいコメントと、次回取得までの推奨待機時間を返します。
+	// 推奨待機時間が不明な場合は 0 を返します。
+	// コメントは投稿時刻の古い順に返す必要があります (投稿時刻が不明なコメントは最後)。
+	FetchLiveChatMessages(ctx context.Context) ([]youtube.Comment, time.Duration, error)
+	// IsCommentDeleted は指定したコメントが削除済みとして通知されているかどうかを返します。
+	IsCommentDeleted(commentID string) bool
+}
+
+// youtube.Client が CommentSource を満たすことをコンパイル時に保証します。
+var _ CommentSource = (*youtube.Client)(nil)
+
+// streamIdentifier は現在のライブチャットを識別できるコメントソースが実装する、省略可能なインターフェースです。
+// 実装している場合、パイプラインは別のライブチャットへの切り替えを検出して配信ごとの状態をクリアします。
+type streamIdentifier interface {
+	// LiveChatID は現在のライブチャットのIDを返します。接続していない場合は空文字列を返します。
+	LiveChatID() string
+}
+
+var _ streamIdentifier = (*youtube.Client)(nil)
+
+// commentPoster は応答の投稿先 (書き込み側) のインターフェースです。
+// 標準の実装は youtube.Client で、テストでは投稿を記録する偽の実装に差し替えます。
+type commentPoster interface {
+	// PostComment はライブチャットにコメントを投稿します。
+	PostComment(ctx context.Context, text string) error
+}
+
+// youtube.Client が commentPoster を満たすことをコンパイル時に保証します。
+var _ commentPoster = (*youtube.Client)(nil)
@@ -0,0 +1,38 @@
+package pipeline
+
+import (
+	"log"
+)
+
+// checkStreamChange はコメントソースのライブチャットIDを確認し、別の配信のチャットに切り替わった場合は
+// resetStreamState で配信ごとの状態をクリアします。コメントの取得に成功した直後、コメントを処理する前に呼び出します。
+// 同じチャットへの再接続 (一時的なエラーの後など) では状態を保持します。
+func (p *LowLatencyPipeline) checkStreamChange() {
+	source, ok := p.source.(streamIdentifier)
+	if !ok {
+		return
+	}
+	chatID := source.LiveChatID()
+	if chatID == "" || chatID == p.streamChatID {
+		return
+	}
+	if p.streamChatID != "" {
+		p.resetStreamState()
+	}
+	p.streamChatID = chatID
+}
+
+// resetStreamState は配信ごとの状態をクリアします。
+// 最近応答した質問 (--question-cooldown) は共有ストアにあり削除できないため、
+// ライブチャットIDを含むキー (questionStoreKey) で配信ごとに分けています。
+func (p *LowLatencyPipeline) resetStreamState() {
+	log.Printf("New live chat detected. Resetting per-stream state (%d authors in exchange limits, %d recap questions, %d digest comments, %d queued replies).",
+		len(p.exchanges.authors), len(p.recap.questions), len(p.digest.comments), len(p.offline.replies))
+	p.exchanges = exchangeTracker{}
+	p.recap = recapLog{}
+	p.digest = digestBuffer{}
+	// 前の配信のコメントへの応答を新しい配信に投稿しない
+	p.offline = offlineQueue{}
+	p.viewerMilestones = viewerMilestones{}
+	p.restriction = chatRestriction{}
+}
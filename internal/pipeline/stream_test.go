@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"prompter-live-go/internal/youtube"
+)
+
+// fakeStreamSource は現在のライブチャットIDを切り替えられるコメントソースです。
+type fakeStreamSource struct {
+	chatID string
+}
+
+func (s *fakeStreamSource) FetchLiveChatMessages(ctx context.Context) ([]youtube.Comment, time.Duration, error) {
+	return nil, 0, nil
+}
+func (s *fakeStreamSource) IsCommentDeleted(commentID string) bool { return false }
+func (s *fakeStreamSource) LiveChatID() string                     { return s.chatID }
+
+func TestStreamChangeResetsPerStreamState(t *testing.T) {
+	question := youtube.Comment{ID: "msg-2", AuthorID: "viewer-b", Author: "Bob", Message: "what game is this?"}
+
+	tests := []struct {
+		name string
+		// chatIDs は順に切り替えるライブチャットIDです (空は配信の終了)。
+		chatIDs   []string
+		wantReset bool
+	}{
+		{name: "same chat", chatIDs: []string{"chat-1", "chat-1"}, wantReset: false},
+		{name: "chat ended", chatIDs: []string{"chat-1", ""}, wantReset: false},
+		{name: "reconnect to the same chat after end", chatIDs: []string{"chat-1", "", "chat-1"}, wantReset: false},
+		{name: "end then new chat", chatIDs: []string{"chat-1", "", "chat-2"}, wantReset: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &fakeStreamSource{chatID: tt.chatIDs[0]}
+			p := newTestPipeline(&stubResponder{}, &fakePoster{})
+			p.source = source
+			p.pipelineConfig.QuestionCooldown = time.Hour
+			p.pipelineConfig.MaxExchangesPerAuthor = 1
+			p.pipelineConfig.ExchangeResetGap = time.Hour
+			ctx := context.Background()
+
+			// 最初の配信での状態を作る
+			p.checkStreamChange()
+			p.recordAnswer(ctx, question, "It's Minecraft!")
+			p.recordExchange(question)
+			p.recap.noteQuestion(question.Message, time.Now())
+			p.digest.add(question)
+			p.offline.push(queuedReply{commentID: question.ID, author: question.Author, text: "It's Minecraft!", queuedAt: time.Now()}, 10)
+			p.viewerMilestones = viewerMilestones{baselineSet: true, highest: 100}
+
+			for _, id := range tt.chatIDs[1:] {
+				source.chatID = id
+				p.checkStreamChange()
+			}
+
+			_, repeated := p.repeatedQuestion(ctx, question)
+			kept := map[string]bool{
+				"question cooldown": repeated,
+				"exchange limit":    p.exchangeLimitReached(question),
+				"recap":             len(p.recap.questions) > 0,
+				"digest":            len(p.digest.comments) > 0,
+				"offline queue":     len(p.offline.replies) > 0,
+				"viewer milestones": p.viewerMilestones.baselineSet,
+			}
+			for state, ok := range kept {
+				if ok == tt.wantReset {
+					t.Errorf("%s kept = %v after %v, want %v", state, ok, tt.chatIDs, !tt.wantReset)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package pipeline
+
+import (
+	"strings"
+
+	"prompter-live-go/internal/youtube"
+)
+
+// withSuperChatAck は Super Chat への応答の先頭に、--super-chat-template の感謝の一文を付けます。
+// テンプレートの {amount} は金額の表示 (例: "¥500")、{author} は投稿者名に置き換えます。
+// 通常のコメント、空の応答、テンプレートが未設定の場合はそのまま返します。
+func (p *LowLatencyPipeline) withSuperChatAck(comment youtube.Comment, reply string) string {
+	template := p.pipelineConfig.SuperChatTemplate
+	if template == "" || reply == "" || comment.SuperChatAmountMicros == 0 {
+		return reply
+	}
+
+	ack := strings.NewReplacer("{amount}", comment.SuperChatAmount, "{author}", comment.Author).Replace(template)
+	// 感謝の一文を付けても YouTube の文字数の上限を超えないようにする
+	return truncateReply(ack+reply, maxReplyLength)
+}
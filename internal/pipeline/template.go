@@ -0,0 +1,59 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"prompter-live-go/internal/youtube"
+)
+
+// SamplePromptComment はプロンプトテンプレートの検証 (template validate) で使用するサンプルのコメントです。
+// テンプレートから参照できるすべてのフィールドに値を設定しています。
+var SamplePromptComment = youtube.Comment{
+	ID:                    "sample-comment-id",
+	AuthorID:              "UCxxxxxxxxxxxxxxxxxxxxxx",
+	Author:                "Sample Viewer",
+	Message:               "What game are you playing today?",
+	Timestamp:             time.Date(2025, 1, 1, 20, 0, 0, 0, time.UTC),
+	IsMember:              true,
+	SuperChatAmountMicros: 500000000,
+	SuperChatAmount:       "¥500",
+}
+
+// ParsePromptTemplate はプロンプトテンプレート (--prompt-template) を解析します。
+// テンプレートには youtube.Comment のフィールド ({{.Author}}、{{.Message}} など) を記述できます。
+// name はエラーメッセージに表示するテンプレート名 (通常はファイルパス) です。
+func ParsePromptTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// RenderPromptTemplate はコメントをテンプレートに適用してプロンプトを生成します。
+// 存在しないフィールドを参照している場合は、その位置 (行:列) と名前を含むエラーを返します。
+func RenderPromptTemplate(tmpl *template.Template, comment youtube.Comment) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, comment); err != nil {
+		return "", fmt.Errorf("failed to render prompt template: %w", err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// commentPrompt はコメントから送信するプロンプトを組み立てます。
+// プロンプトテンプレートが設定されている場合は、メンバーシップ関連イベント以外のコメントにテンプレートを使用します。
+func (p *LowLatencyPipeline) commentPrompt(comment youtube.Comment) string {
+	language := p.responseLanguage(comment)
+	if tmpl := p.pipelineConfig.PromptTemplate; tmpl != nil && comment.Event == youtube.EventNone {
+		prompt, err := RenderPromptTemplate(tmpl, comment)
+		if err == nil {
+			return withLanguageHint(prompt, language)
+		}
+		log.Printf("Warning: %v. Using the default prompt.", err)
+	}
+	return buildPrompt(comment, language, !p.pipelineConfig.OmitAuthor)
+}
@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"log"
+	"time"
+
+	"prompter-live-go/internal/overlay"
+	"prompter-live-go/internal/transcript"
+	"prompter-live-go/internal/youtube"
+)
+
+// SetTranscript はコメントと応答を記録するトランスクリプトを設定します (--transcript 指定時)。
+// Run の開始前に設定する必要があります。Writer のクローズは呼び出し元が行います。
+func (p *LowLatencyPipeline) SetTranscript(w *transcript.Writer) {
+	p.transcript = w
+}
+
+// writeTranscript はトランスクリプトが設定されている場合にエントリを記録します。
+// 記録に失敗してもパイプラインの処理は継続します。
+func (p *LowLatencyPipeline) writeTranscript(kind, commentID, authorID, author, text string) {
+	if p.transcript == nil {
+		return
+	}
+	err := p.transcript.Write(transcript.Entry{
+		Time:      time.Now(),
+		Kind:      kind,
+		CommentID: commentID,
+		AuthorID:  authorID,
+		Author:    author,
+		Text:      text,
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to write transcript: %v", err)
+	}
+}
+
+// CSV エクスポートとトランスクリプトで使用する、スキップ理由 (統計) 以外の結果
+const (
+	outcomeError   = "error"
+	outcomeDigest  = "digest"
+	outcomeReplied = "replied"
+)
+
+// commentOutcome は 1 件のコメントの処理結果です (--csv-out の 1 行、トランスクリプトの処理結果の行に対応します)。
+type commentOutcome struct {
+	reply      string
+	posted     bool
+	skipReason string
+	// timings は応答の所要時間の内訳の計測点です。
+	timings replyTimings
+}
+
+// SetCSVExport は処理したコメントと応答を書き出す CSV を設定します (--csv-out 指定時)。
+// Run の開始前に設定する必要があります。Writer のクローズは呼び出し元が行います。
+func (p *LowLatencyPipeline) SetCSVExport(w *transcript.CSVWriter) {
+	p.csvExport = w
+}
+
+// skip はスキップ理由を統計とコメントの処理結果の両方に記録します。
+func (p *LowLatencyPipeline) skip(outcome *commentOutcome, reason string) {
+	p.recorder.RecordSkip(reason)
+	outcome.skipReason = reason
+}
+
+// recordOutcome はコメントの処理結果を CSV エクスポートとトランスクリプトに記録します。
+// comment には整形・切り詰め前の受信したままのコメントを渡します。
+func (p *LowLatencyPipeline) recordOutcome(comment youtube.Comment, outcome *commentOutcome) {
+	p.writeCSVRow(comment, outcome)
+	p.writeDisposition(comment, outcome)
+	if outcome.posted {
+		p.recordLatency(comment, outcome.timings, p.lastFetchAt)
+	}
+}
+
+// writeDisposition はトランスクリプトが設定されている場合に、コメントの処理結果
+// (応答した、またはスキップした理由) を 1 行として記録します。
+func (p *LowLatencyPipeline) writeDisposition(comment youtube.Comment, outcome *commentOutcome) {
+	if p.transcript == nil {
+		return
+	}
+	disposition := outcome.skipReason
+	if outcome.posted {
+		disposition = outcomeReplied
+	}
+	err := p.transcript.Write(transcript.Entry{
+		Time:        time.Now(),
+		Kind:        transcript.KindDisposition,
+		CommentID:   comment.ID,
+		AuthorID:    comment.AuthorID,
+		Author:      comment.Author,
+		Text:        outcome.reply,
+		Disposition: disposition,
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to write transcript: %v", err)
+	}
+}
+
+// writeCSVRow は CSV エクスポートが設定されている場合に、コメントの処理結果を 1 行として書き出します。
+// comment には整形・切り詰め前の受信したままのコメントを渡します。
+func (p *LowLatencyPipeline) writeCSVRow(comment youtube.Comment, outcome *commentOutcome) {
+	if p.csvExport == nil {
+		return
+	}
+	err := p.csvExport.Write(transcript.CSVRow{
+		Time:       comment.Timestamp,
+		Author:     comment.Author,
+		Comment:    comment.Message,
+		Reply:      outcome.reply,
+		Posted:     outcome.posted,
+		SkipReason: outcome.skipReason,
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to write CSV row: %v", err)
+	}
+}
+
+// SetOverlay は最新の応答を書き出すオーバーレイファイルを設定します (--overlay-file 指定時)。
+// Run の開始前に設定する必要があります。
+func (p *LowLatencyPipeline) SetOverlay(w *overlay.Writer) {
+	p.overlay = w
+}
+
+// updateOverlay はオーバーレイファイルが設定されている場合に、投稿した応答で上書きします。
+// 書き込みに失敗してもパイプラインの処理は継続します。
+func (p *LowLatencyPipeline) updateOverlay(author, reply string) {
+	if p.overlay == nil {
+		return
+	}
+	if err := p.overlay.Update(author, reply); err != nil {
+		log.Printf("Warning: Failed to update overlay file: %v", err)
+	}
+}
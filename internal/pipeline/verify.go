@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"prompter-live-go/internal/youtube"
+)
+
+// postVerificationWindow は投稿した応答がライブチャットに表示されるのを待つ期間です。
+// この期間内に確認できなかった投稿は、フィルタリングされた可能性があるとして警告します。
+const postVerificationWindow = 2 * time.Minute
+
+// pendingPost は表示の確認待ちの投稿です。
+type pendingPost struct {
+	text     string
+	postedAt time.Time
+}
+
+// postVerifier は投稿したメッセージが実際にライブチャットに表示されたかを確認します。
+// PostComment が成功してもシャドウモデレーションなどで表示されない場合を検出するために使用します。
+type postVerifier struct {
+	selfChannelID string
+	pending       []pendingPost
+}
+
+// newPostVerifier は指定したボットのチャンネルIDで新しい postVerifier を作成します。
+func newPostVerifier(selfChannelID string) *postVerifier {
+	return &postVerifier{selfChannelID: selfChannelID}
+}
+
+// track は投稿したメッセージを確認待ちとして記録します。
+func (v *postVerifier) track(text string, postedAt time.Time) {
+	v.pending = append(v.pending, pendingPost{text: strings.TrimSpace(text), postedAt: postedAt})
+}
+
+// isSelf はコメントがボット自身の投稿かどうかを返します。
+func (v *postVerifier) isSelf(comment youtube.Comment) bool {
+	return comment.AuthorID == v.selfChannelID
+}
+
+// observe は取得したコメントの中からボット自身の投稿を探して確認済みにし、
+// 確認期間を過ぎた投稿について警告を出力します。
+func (v *postVerifier) observe(comments []youtube.Comment, now time.Time) {
+	for _, comment := range comments {
+		if !v.isSelf(comment) {
+			continue
+		}
+		text := strings.TrimSpace(comment.Message)
+		for i, post := range v.pending {
+			if post.text == text {
+				v.pending = append(v.pending[:i], v.pending[i+1:]...)
+				break
+			}
+		}
+	}
+
+	remaining := v.pending[:0]
+	for _, post := range v.pending {
+		if now.Sub(post.postedAt) > postVerificationWindow {
+			log.Printf("Warning: Posted reply was not seen in live chat within %v; it may have been filtered: %q", postVerificationWindow, post.text)
+			continue
+		}
+		remaining = append(remaining, post)
+	}
+	v.pending = remaining
+}
@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// viewerStatsMaxAge はプロンプトに含める視聴者数の鮮度の上限です。これより古い値 (取得の失敗が続いている場合など) は使用しません。
+const viewerStatsMaxAge = 10 * time.Minute
+
+// viewerMilestones は視聴者数の節目 (--viewer-milestone-step) の到達状況です。
+type viewerMilestones struct {
+	// baselineSet は起動後の最初の値を基準として記録したかどうかです。
+	// 起動時点で既に超えている節目は、到達したばかりとは扱いません。
+	baselineSet bool
+	// highest はこれまでに到達した最も大きな節目です。
+	highest uint64
+}
+
+// withViewerContext は --track-viewers 指定時に、直近の同時視聴者数と高評価数をプロンプトに追加します。
+// 新しい節目 (--viewer-milestone-step の倍数) に到達した直後は、それに触れるよう指示します。
+func (p *LowLatencyPipeline) withViewerContext(prompt string) string {
+	if !p.pipelineConfig.TrackViewers || p.youtubeClient == nil {
+		return prompt
+	}
+	stats, ok := p.youtubeClient.ViewerStats()
+	if !ok || time.Since(stats.UpdatedAt) > viewerStatsMaxAge {
+		return prompt
+	}
+
+	prompt += fmt.Sprintf("\n\n(Live stream stats: %d viewers watching now, %d likes. Mention them only if it fits naturally.)", stats.ConcurrentViewers, stats.LikeCount)
+	if milestone, ok := p.reachedViewerMilestone(stats.ConcurrentViewers); ok {
+		log.Printf("Viewer milestone reached: %d concurrent viewers.", milestone)
+		prompt += fmt.Sprintf("\n(The stream just reached %d concurrent viewers! Celebrate it briefly in your reply.)", milestone)
+	}
+	return prompt
+}
+
+// reachedViewerMilestone は viewers が前回までより大きな節目に到達した場合に、その節目を返します。
+// 同じ節目は一度しか返しません。
+func (p *LowLatencyPipeline) reachedViewerMilestone(viewers uint64) (uint64, bool) {
+	step := uint64(p.pipelineConfig.ViewerMilestoneStep)
+	if step == 0 {
+		return 0, false
+	}
+	reached := viewers / step * step
+
+	m := &p.viewerMilestones
+	if !m.baselineSet {
+		m.baselineSet = true
+		m.highest = reached
+		return 0, false
+	}
+	if reached <= m.highest {
+		return 0, false
+	}
+	m.highest = reached
+	return reached, true
+}
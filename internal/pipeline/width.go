@@ -0,0 +1,48 @@
+package pipeline
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizeWidth は日本語の応答で混在しがちな文字幅を揃えます (--normalize-width)。
+//   - 全角の英数字 (Ａ〜Ｚ、ａ〜ｚ、０〜９) は半角に変換します。
+//   - 半角カタカナ (ｶﾀｶﾅ、濁点・半濁点を含む) は全角に変換します。
+//
+// NFKC 全体は適用しないため、全角の句読点・括弧 (！？（）「」など)、全角スペース、絵文字は変更しません。
+func normalizeWidth(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case isFullWidthAlnum(r):
+			// 全角英数字は対応する ASCII から 0xFEE0 だけずれた位置にある
+			b.WriteRune(r - 0xFEE0)
+		case isHalfWidthKana(r):
+			// 濁点・半濁点 (ﾞﾟ) を直前の文字と合成できるよう、半角カタカナの連続をまとめて NFKC で変換する
+			j := i
+			for j < len(runes) && isHalfWidthKana(runes[j]) {
+				j++
+			}
+			b.WriteString(norm.NFKC.String(string(runes[i:j])))
+			i = j - 1
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isFullWidthAlnum は r が全角の英数字かどうかを返します。
+func isFullWidthAlnum(r rune) bool {
+	return (r >= '０' && r <= '９') || (r >= 'Ａ' && r <= 'Ｚ') || (r >= 'ａ' && r <= 'ｚ')
+}
+
+// isHalfWidthKana は r が半角カタカナ (句読点・長音記号・濁点を含む U+FF61〜U+FF9F) かどうかを返します。
+func isHalfWidthKana(r rune) bool {
+	return r >= 0xFF61 && r <= 0xFF9F
+}
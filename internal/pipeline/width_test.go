@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"testing"
+
+	"prompter-live-go/internal/types"
+)
+
+func TestNormalizeWidth(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "full-width alphanumerics", input: "ＧｏとＰｙｔｈｏｎ３を比較", want: "GoとPython3を比較"},
+		{name: "mixed widths", input: "Ｖｅｒ2.0で１０％速くなりました", want: "Ver2.0で10％速くなりました"},
+		{name: "half-width katakana", input: "ｱﾘｶﾞﾄｳｺﾞｻﾞｲﾏｽ", want: "アリガトウゴザイマス"},
+		{name: "handakuten", input: "ﾊﾟｰﾃｨｰ", want: "パーティー"},
+		{name: "half-width kana punctuation", input: "｢ｵﾂｶﾚ｣､ﾏﾀﾈ｡", want: "「オツカレ」、マタネ。"},
+		{name: "full-width punctuation unchanged", input: "すごい！本当？（笑）「はい」、。", want: "すごい！本当？（笑）「はい」、。"},
+		{name: "full-width space unchanged", input: "こんにちは　世界", want: "こんにちは　世界"},
+		{name: "emoji unchanged", input: "ありがとう🎉👍🏽❤️", want: "ありがとう🎉👍🏽❤️"},
+		{name: "keycap emoji unchanged", input: "1️⃣ #️⃣ 🇯🇵", want: "1️⃣ #️⃣ 🇯🇵"},
+		{name: "ascii unchanged", input: "Hello, world! 123", want: "Hello, world! 123"},
+		{name: "empty", input: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeWidth(tt.input); got != tt.want {
+				t.Errorf("normalizeWidth(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFinishReplyNormalizeWidth(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		input   string
+		want    string
+	}{
+		{name: "enabled", enabled: true, input: "  ＯＫです！ｱﾘｶﾞﾄｳ😊  ", want: "OKです！アリガトウ😊"},
+		{name: "disabled", enabled: false, input: "  ＯＫです！ｱﾘｶﾞﾄｳ😊  ", want: "ＯＫです！ｱﾘｶﾞﾄｳ😊"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &LowLatencyPipeline{pipelineConfig: types.PipelineConfig{NormalizeWidth: tt.enabled}}
+			if got := p.finishReply(tt.input, maxReplyLength); got != tt.want {
+				t.Errorf("finishReply(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
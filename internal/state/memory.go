@@ -0,0 +1,120 @@
+package state
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval は期限切れのエントリをまとめて削除する間隔です。
+const sweepInterval = time.Minute
+
+// memoryEntry はメモリ上のストアの値と有効期限です。
+type memoryEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// MemoryStore はプロセス内のマップに状態を保存するストアです (既定)。
+// 期限切れのエントリは書き込み時に定期的に削除します。
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+// NewMemoryStore は新しい MemoryStore を作成します。
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry), lastSweep: time.Now()}
+}
+
+// MarkSeen は id を処理済みとして記録し、まだ記録されていなかった場合に true を返します。
+func (s *MemoryStore) MarkSeen(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := s.lookup(seenKey(id), now); ok {
+		return false, nil
+	}
+	s.set(seenKey(id), "1", ttl, now)
+	return true, nil
+}
+
+// MarkSeenBatch は ids のそれぞれを処理済みとして記録し、まだ記録されていなかったかどうかを ids の順に返します。
+func (s *MemoryStore) MarkSeenBatch(ctx context.Context, ids []string, ttl time.Duration) ([]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	isNew := make([]bool, len(ids))
+	for i, id := range ids {
+		if _, ok := s.lookup(seenKey(id), now); ok {
+			continue
+		}
+		s.set(seenKey(id), "1", ttl, now)
+		isNew[i] = true
+	}
+	return isNew, nil
+}
+
+// Seen は id が処理済みとして記録されているかどうかを返します。
+func (s *MemoryStore) Seen(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.lookup(seenKey(id), time.Now())
+	return ok, nil
+}
+
+// Put は key に value を ttl の間保存します。
+func (s *MemoryStore) Put(ctx context.Context, key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.set(key, value, ttl, time.Now())
+	return nil
+}
+
+// Get は key の値を返します。
+func (s *MemoryStore) Get(ctx context.Context, key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.lookup(key, time.Now())
+	return value, ok, nil
+}
+
+// Close は何もしません。
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// lookup は期限内の値を返します。呼び出し元は s.mu を保持している必要があります。
+func (s *MemoryStore) lookup(key string, now time.Time) (string, bool) {
+	e, ok := s.entries[key]
+	if !ok || !now.Before(e.expiresAt) {
+		return "", false
+	}
+	return e.value, true
+}
+
+// set は値を保存し、必要に応じて期限切れのエントリを削除します。呼び出し元は s.mu を保持している必要があります。
+func (s *MemoryStore) set(key, value string, ttl time.Duration, now time.Time) {
+	s.entries[key] = memoryEntry{value: value, expiresAt: now.Add(ttl)}
+
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for k, e := range s.entries {
+		if !now.Before(e.expiresAt) {
+			delete(s.entries, k)
+		}
+	}
+}
+
+// seenKey は処理済みの記録に使用するキーです (Put/Get のキーと衝突しないよう接頭辞を付ける)。
+func seenKey(id string) string {
+	return "seen:" + id
+}
@@ -0,0 +1,50 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreMarkSeenBatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		prior []string
+		ids   []string
+		want  []bool
+	}{
+		{name: "all new", ids: []string{"a", "b"}, want: []bool{true, true}},
+		{name: "already seen", prior: []string{"a"}, ids: []string{"a", "b"}, want: []bool{false, true}},
+		{name: "duplicate within batch", ids: []string{"a", "a"}, want: []bool{true, false}},
+		{name: "empty", ids: nil, want: []bool{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStore()
+			ctx := context.Background()
+			for _, id := range tt.prior {
+				s.MarkSeen(ctx, id, time.Minute)
+			}
+			got, err := s.MarkSeenBatch(ctx, tt.ids, time.Minute)
+			if err != nil {
+				t.Fatalf("MarkSeenBatch: %v", err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("MarkSeenBatch(%v) = %v, want %v", tt.ids, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreMarkSeenBatchExpires(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	s.MarkSeenBatch(ctx, []string{"a"}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	got, _ := s.MarkSeenBatch(ctx, []string{"a"}, time.Minute)
+	if len(got) != 1 || !got[0] {
+		t.Errorf("MarkSeenBatch after expiry = %v, want [true]", got)
+	}
+}
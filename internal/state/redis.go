@@ -0,0 +1,283 @@
+package state
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// redisKeyPrefix は Redis のキーに付ける接頭辞です (同じ Redis を他の用途と共有できるようにする)。
+const redisKeyPrefix = "prompter-live:"
+
+// redisDialTimeout は Redis への接続のタイムアウトです。
+const redisDialTimeout = 5 * time.Second
+
+// redisCommandTimeout は context に期限がない場合の、1 回のコマンドのタイムアウトです。
+const redisCommandTimeout = 5 * time.Second
+
+// errRedisNil は Redis が nil (キーが存在しない、SET NX が設定しなかった) を返したことを示します。
+var errRedisNil = errors.New("redis: nil")
+
+// RedisStore は Redis に状態を保存するストアです。
+// 外部ライブラリに依存しないよう、必要なコマンドのみを RESP プロトコルで直接送信します。
+// 接続は 1 本のみで、コマンドは順に実行します (MarkSeenBatch はパイプラインでまとめて送信します)。
+// 通信エラーの後は次のコマンドで再接続します。
+type RedisStore struct {
+	addr     string
+	password string
+	db       int
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// NewRedisStore は Redis に接続し、新しい RedisStore を作成します。
+// 起動時に設定の誤りに気付けるよう、接続と認証はここで行います。
+func NewRedisStore(addr, password string, db int) (*RedisStore, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("redis address is required")
+	}
+	s := &RedisStore{addr: addr, password: password, db: db}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisDialTimeout)
+	defer cancel()
+	if _, err := s.do(ctx, "PING"); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to connect to redis at %s: %w", addr, err)
+	}
+	return s, nil
+}
+
+// MarkSeen は SET NX で id を記録し、まだ記録されていなかった場合に true を返します。
+func (s *RedisStore) MarkSeen(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	_, err := s.do(ctx, "SET", redisKeyPrefix+seenKey(id), "1", "NX", "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	if errors.Is(err, errRedisNil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// MarkSeenBatch は ids のそれぞれの SET NX をパイプラインで送信し、まだ記録されていなかったかどうかを ids の順に返します。
+func (s *RedisStore) MarkSeenBatch(ctx context.Context, ids []string, ttl time.Duration) ([]bool, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	px := strconv.FormatInt(ttl.Milliseconds(), 10)
+	cmds := make([][]string, len(ids))
+	for i, id := range ids {
+		cmds[i] = []string{"SET", redisKeyPrefix + seenKey(id), "1", "NX", "PX", px}
+	}
+
+	_, errs, err := s.pipeline(ctx, cmds)
+	if err != nil {
+		return nil, err
+	}
+	isNew := make([]bool, len(ids))
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			isNew[i] = true
+		case errors.Is(err, errRedisNil):
+		default:
+			return nil, err
+		}
+	}
+	return isNew, nil
+}
+
+// Seen は id が記録されているかどうかを返します。
+func (s *RedisStore) Seen(ctx context.Context, id string) (bool, error) {
+	n, err := s.do(ctx, "EXISTS", redisKeyPrefix+seenKey(id))
+	if err != nil {
+		return false, err
+	}
+	return n == "1", nil
+}
+
+// Put は key に value を ttl の間保存します。
+func (s *RedisStore) Put(ctx context.Context, key, value string, ttl time.Duration) error {
+	_, err := s.do(ctx, "SET", redisKeyPrefix+key, value, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// Get は key の値を返します。
+func (s *RedisStore) Get(ctx context.Context, key string) (string, bool, error) {
+	value, err := s.do(ctx, "GET", redisKeyPrefix+key)
+	if errors.Is(err, errRedisNil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// Close は Redis との接続を閉じます。
+func (s *RedisStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeConn()
+}
+
+// do はコマンドを送信し、応答を文字列として返します (整数は 10 進表記)。
+// 通信エラーの場合は接続を閉じ、次の呼び出しで再接続します。
+func (s *RedisStore) do(ctx context.Context, args ...string) (string, error) {
+	replies, errs, err := s.pipeline(ctx, [][]string{args})
+	if err != nil {
+		return "", err
+	}
+	return replies[0], errs[0]
+}
+
+// pipeline は cmds をまとめて送信し、それぞれの応答を cmds の順に返します (往復は 1 回)。
+// コマンドごとのエラー (errRedisNil と Redis のエラー応答) は errs に、通信エラーは err に返します。
+// 通信エラーの場合は接続を閉じ、次の呼び出しで再接続します。
+func (s *RedisStore) pipeline(ctx context.Context, cmds [][]string) (replies []string, errs []error, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	replies, errs, err = s.roundTrip(ctx, cmds)
+	if err != nil {
+		// 応答の途中で失敗した接続は再利用できない
+		s.closeConn()
+	}
+	return replies, errs, err
+}
+
+// connect は Redis に接続し、必要に応じて AUTH と SELECT を実行します。呼び出し元は s.mu を保持している必要があります。
+func (s *RedisStore) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: redisDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	s.rd = bufio.NewReader(conn)
+
+	if s.password != "" {
+		if err := s.setup(ctx, "AUTH", s.password); err != nil {
+			s.closeConn()
+			return fmt.Errorf("redis AUTH failed: %w", err)
+		}
+	}
+	if s.db != 0 {
+		if err := s.setup(ctx, "SELECT", strconv.Itoa(s.db)); err != nil {
+			s.closeConn()
+			return fmt.Errorf("redis SELECT %d failed: %w", s.db, err)
+		}
+	}
+	return nil
+}
+
+// setup は接続直後の設定のコマンドを実行します。呼び出し元は s.mu を保持している必要があります。
+func (s *RedisStore) setup(ctx context.Context, args ...string) error {
+	_, errs, err := s.roundTrip(ctx, [][]string{args})
+	if err != nil {
+		return err
+	}
+	return errs[0]
+}
+
+// closeConn は接続を閉じます。呼び出し元は s.mu を保持している必要があります。
+func (s *RedisStore) closeConn() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	s.rd = nil
+	return err
+}
+
+// roundTrip は cmds をそれぞれ RESP の配列として 1 回で送信し、応答を cmds の数だけ読み取ります。
+// 呼び出し元は s.mu を保持している必要があります。
+// コマンドごとのエラー (errRedisNil と redisError) は errs に、通信エラー (接続を再利用できない) は err に返します。
+func (s *RedisStore) roundTrip(ctx context.Context, cmds [][]string) ([]string, []error, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisCommandTimeout)
+	}
+	if err := s.conn.SetDeadline(deadline); err != nil {
+		return nil, nil, err
+	}
+
+	var buf []byte
+	for _, args := range cmds {
+		buf = fmt.Appendf(buf, "*%d\r\n", len(args))
+		for _, arg := range args {
+			buf = fmt.Appendf(buf, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if _, err := s.conn.Write(buf); err != nil {
+		return nil, nil, err
+	}
+
+	replies := make([]string, len(cmds))
+	errs := make([]error, len(cmds))
+	for i := range cmds {
+		reply, err := readRESP(s.rd)
+		var redisErr redisError
+		if err != nil && !errors.Is(err, errRedisNil) && !errors.As(err, &redisErr) {
+			return nil, nil, err
+		}
+		replies[i], errs[i] = reply, err
+	}
+	return replies, errs, nil
+}
+
+// redisError は Redis が返したエラー応答 (-ERR ...) です。接続自体は引き続き使用できます。
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// readRESP は RESP の応答を 1 つ読み取ります。単純な文字列・エラー・整数・バルク文字列に対応します。
+func readRESP(rd *bufio.Reader) (string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+', ':':
+		return body, nil
+	case '-':
+		return "", redisError(body)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return "", fmt.Errorf("redis: malformed bulk length %q", body)
+		}
+		if n < 0 {
+			return "", errRedisNil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, data); err != nil {
+			return "", err
+		}
+		return string(data[:n]), nil
+	default:
+		return "", fmt.Errorf("redis: unsupported reply type %q", kind)
+	}
+}
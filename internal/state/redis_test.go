@@ -0,0 +1,431 @@
+package state
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis は RedisStore が使用するコマンド (PING, AUTH, SELECT, SET, GET, EXISTS) だけを実装した
+// テスト用の RESP サーバーです。
+type fakeRedis struct {
+	t        *testing.T
+	ln       net.Listener
+	password string
+
+	mu       sync.Mutex
+	data     map[string]string
+	commands [][]string
+	conns    []net.Conn
+	// flushes は応答を書き出した回数 (クライアントから見た往復の回数) です。
+	flushes int
+	// failSet が空でない場合、SET にこのエラー応答を返します。
+	failSet string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakeRedis{t: t, ln: ln, password: password, data: make(map[string]string)}
+	go f.serve()
+	t.Cleanup(func() {
+		ln.Close()
+		f.dropConnections()
+	})
+	return f
+}
+
+func (f *fakeRedis) addr() string { return f.ln.Addr().String() }
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns = append(f.conns, conn)
+		f.mu.Unlock()
+		go f.handle(conn)
+	}
+}
+
+// dropConnections は接続中のすべての接続を閉じます (サーバーの再起動やネットワークの切断の代わり)。
+func (f *fakeRedis) dropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.conns {
+		c.Close()
+	}
+	f.conns = nil
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	wr := bufio.NewWriter(conn)
+	authed := f.password == ""
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, args)
+		reply := f.exec(args, &authed)
+		f.mu.Unlock()
+		wr.WriteString(reply)
+
+		// パイプラインで送られたコマンドは、すべて読み終えてからまとめて応答する
+		if rd.Buffered() == 0 {
+			f.mu.Lock()
+			f.flushes++
+			f.mu.Unlock()
+			if err := wr.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// exec はコマンドを実行し、RESP の応答を返します。呼び出し元は f.mu を保持している必要があります。
+func (f *fakeRedis) exec(args []string, authed *bool) string {
+	cmd := strings.ToUpper(args[0])
+	if cmd == "AUTH" {
+		if len(args) == 2 && args[1] == f.password {
+			*authed = true
+			return "+OK\r\n"
+		}
+		return "-WRONGPASS invalid password\r\n"
+	}
+	if !*authed {
+		return "-NOAUTH Authentication required.\r\n"
+	}
+
+	switch cmd {
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "SET":
+		if f.failSet != "" {
+			return "-" + f.failSet + "\r\n"
+		}
+		key, value := args[1], args[2]
+		nx := false
+		for _, opt := range args[3:] {
+			if strings.ToUpper(opt) == "NX" {
+				nx = true
+			}
+		}
+		if _, ok := f.data[key]; ok && nx {
+			return "$-1\r\n"
+		}
+		f.data[key] = value
+		return "+OK\r\n"
+	case "GET":
+		value, ok := f.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "EXISTS":
+		if _, ok := f.data[args[1]]; ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+// received は受信したコマンドの名前を順に返します。
+func (f *fakeRedis) received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	names := make([]string, len(f.commands))
+	for i, args := range f.commands {
+		names[i] = args[0]
+	}
+	return names
+}
+
+func (f *fakeRedis) flushCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.flushes
+}
+
+// readCommand は RESP の配列で送られたコマンドを 1 つ読み取ります。
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected command line %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		header, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func newTestRedisStore(t *testing.T, server *fakeRedis, password string, db int) *RedisStore {
+	t.Helper()
+	s, err := NewRedisStore(server.addr(), password, db)
+	if err != nil {
+		t.Fatalf("NewRedisStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestRedisStoreMarkSeen(t *testing.T) {
+	server := newFakeRedis(t, "")
+	s := newTestRedisStore(t, server, "", 0)
+	ctx := context.Background()
+
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"msg-1", true},
+		{"msg-1", false},
+		{"msg-2", true},
+	}
+	for _, tt := range tests {
+		got, err := s.MarkSeen(ctx, tt.id, time.Minute)
+		if err != nil {
+			t.Fatalf("MarkSeen(%q): %v", tt.id, err)
+		}
+		if got != tt.want {
+			t.Errorf("MarkSeen(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+
+	seen, err := s.Seen(ctx, "msg-2")
+	if err != nil || !seen {
+		t.Errorf("Seen(msg-2) = %v, %v, want true", seen, err)
+	}
+	seen, err = s.Seen(ctx, "msg-3")
+	if err != nil || seen {
+		t.Errorf("Seen(msg-3) = %v, %v, want false", seen, err)
+	}
+}
+
+func TestRedisStoreMarkSeenBatch(t *testing.T) {
+	server := newFakeRedis(t, "")
+	s := newTestRedisStore(t, server, "", 0)
+	ctx := context.Background()
+
+	if _, err := s.MarkSeen(ctx, "msg-1", time.Minute); err != nil {
+		t.Fatalf("MarkSeen: %v", err)
+	}
+	before := server.flushCount()
+
+	got, err := s.MarkSeenBatch(ctx, []string{"msg-1", "msg-2", "msg-3", "msg-2"}, time.Minute)
+	if err != nil {
+		t.Fatalf("MarkSeenBatch: %v", err)
+	}
+	want := []bool{false, true, true, false}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("MarkSeenBatch = %v, want %v", got, want)
+	}
+	// バッチ全体が 1 回の往復で処理される
+	if n := server.flushCount() - before; n != 1 {
+		t.Errorf("MarkSeenBatch took %d round trips, want 1", n)
+	}
+
+	if got, err := s.MarkSeenBatch(ctx, nil, time.Minute); err != nil || len(got) != 0 {
+		t.Errorf("MarkSeenBatch(nil) = %v, %v, want empty", got, err)
+	}
+}
+
+func TestRedisStorePutGet(t *testing.T) {
+	server := newFakeRedis(t, "")
+	s := newTestRedisStore(t, server, "", 0)
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "question:abc", "答え", time.Minute); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	tests := []struct {
+		key       string
+		wantValue string
+		wantOK    bool
+	}{
+		{"question:abc", "答え", true},
+		{"question:missing", "", false},
+	}
+	for _, tt := range tests {
+		value, ok, err := s.Get(ctx, tt.key)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", tt.key, err)
+		}
+		if value != tt.wantValue || ok != tt.wantOK {
+			t.Errorf("Get(%q) = %q, %v, want %q, %v", tt.key, value, ok, tt.wantValue, tt.wantOK)
+		}
+	}
+
+	// キーには接頭辞が付く
+	server.mu.Lock()
+	_, ok := server.data[redisKeyPrefix+"question:abc"]
+	server.mu.Unlock()
+	if !ok {
+		t.Errorf("stored keys = %v, want %q", server.data, redisKeyPrefix+"question:abc")
+	}
+}
+
+func TestNewRedisStoreAuth(t *testing.T) {
+	tests := []struct {
+		name         string
+		password     string
+		db           int
+		wantErr      bool
+		wantCommands string
+	}{
+		{name: "no auth", wantCommands: "PING"},
+		{name: "password and db", password: "secret", db: 2, wantCommands: "AUTH,SELECT,PING"},
+		{name: "wrong password", password: "wrong", wantErr: true, wantCommands: "AUTH"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverPassword := ""
+			if tt.password != "" {
+				serverPassword = "secret"
+			}
+			server := newFakeRedis(t, serverPassword)
+			s, err := NewRedisStore(server.addr(), tt.password, tt.db)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRedisStore error = %v, want error %v", err, tt.wantErr)
+			}
+			if s != nil {
+				s.Close()
+			}
+			if got := strings.Join(server.received(), ","); got != tt.wantCommands {
+				t.Errorf("commands = %s, want %s", got, tt.wantCommands)
+			}
+		})
+	}
+}
+
+func TestRedisStoreReconnectsAfterConnectionLoss(t *testing.T) {
+	server := newFakeRedis(t, "")
+	s := newTestRedisStore(t, server, "", 0)
+	ctx := context.Background()
+
+	server.dropConnections()
+	// 切断された接続でのコマンドは失敗し、次のコマンドで再接続する
+	if _, err := s.MarkSeen(ctx, "msg-1", time.Minute); err == nil {
+		t.Fatal("MarkSeen on a dropped connection succeeded, want an error")
+	}
+	isNew, err := s.MarkSeen(ctx, "msg-1", time.Minute)
+	if err != nil {
+		t.Fatalf("MarkSeen after reconnecting: %v", err)
+	}
+	if !isNew {
+		t.Error("MarkSeen after reconnecting = false, want true")
+	}
+}
+
+func TestRedisStoreErrorReplyKeepsConnection(t *testing.T) {
+	server := newFakeRedis(t, "")
+	s := newTestRedisStore(t, server, "", 0)
+	ctx := context.Background()
+
+	server.mu.Lock()
+	server.failSet = "READONLY You can't write against a read only replica."
+	server.mu.Unlock()
+
+	var redisErr redisError
+	if _, err := s.MarkSeen(ctx, "msg-1", time.Minute); !errors.As(err, &redisErr) {
+		t.Fatalf("MarkSeen error = %v, want a redis error reply", err)
+	}
+	if _, err := s.MarkSeenBatch(ctx, []string{"msg-1", "msg-2"}, time.Minute); !errors.As(err, &redisErr) {
+		t.Fatalf("MarkSeenBatch error = %v, want a redis error reply", err)
+	}
+
+	// エラー応答の後も同じ接続を使い続ける (パイプラインの応答もすべて読み終えている)
+	if _, err := s.Seen(ctx, "msg-1"); err != nil {
+		t.Fatalf("Seen after an error reply: %v", err)
+	}
+	server.mu.Lock()
+	conns := len(server.conns)
+	server.mu.Unlock()
+	if conns != 1 {
+		t.Errorf("connections = %d, want 1", conns)
+	}
+}
+
+func TestReadRESP(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+		anyErr  bool
+	}{
+		{name: "simple string", input: "+OK\r\n", want: "OK"},
+		{name: "integer", input: ":42\r\n", want: "42"},
+		{name: "bulk string", input: "$5\r\nhello\r\n", want: "hello"},
+		{name: "empty bulk string", input: "$0\r\n\r\n", want: ""},
+		{name: "bulk string with CRLF", input: "$4\r\na\r\nb\r\n", want: "a\r\nb"},
+		{name: "nil bulk string", input: "$-1\r\n", wantErr: errRedisNil},
+		{name: "error", input: "-ERR boom\r\n", wantErr: redisError("ERR boom")},
+		{name: "missing CR", input: "+OK\n", anyErr: true},
+		{name: "unsupported type", input: "*1\r\n", anyErr: true},
+		{name: "bad bulk length", input: "$x\r\n", anyErr: true},
+		{name: "truncated bulk string", input: "$5\r\nhe", anyErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readRESP(bufio.NewReader(strings.NewReader(tt.input)))
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("readRESP(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+				}
+			case tt.anyErr:
+				if err == nil {
+					t.Fatalf("readRESP(%q) = %q, want an error", tt.input, got)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("readRESP(%q): %v", tt.input, err)
+				}
+				if got != tt.want {
+					t.Errorf("readRESP(%q) = %q, want %q", tt.input, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ストアの種別 (--state-store フラグの値)
+const (
+	StoreMemory = "memory"
+	StoreRedis  = "redis"
+)
+
+// Store は重複排除やクールダウンなど、有効期限付きの状態の保存先を抽象化するインターフェースです。
+// 既定のメモリ上のストアはプロセス内でのみ有効です。Redis などの共有ストアを使用すると、
+// 再起動後も状態が残り、同じチャンネルを監視する複数のインスタンスで重複排除を共有できます。
+type Store interface {
+	// MarkSeen は id を処理済みとして ttl の間記録し、まだ記録されていなかった場合に true を返します。
+	// 判定と記録は不可分に行われるため、複数のインスタンスで共有している場合も true を返すのは 1 回だけです。
+	MarkSeen(ctx context.Context, id string, ttl time.Duration) (bool, error)
+	// MarkSeenBatch は ids のそれぞれに MarkSeen を行い、ids と同じ順に結果を返します。
+	// 共有ストアでは 1 回の往復にまとめるため、取得したコメントのバッチ全体の重複排除に使用します。
+	// エラーの場合、どの id が記録されたかは不明です。
+	MarkSeenBatch(ctx context.Context, ids []string, ttl time.Duration) ([]bool, error)
+	// Seen は id が処理済みとして記録されているかどうかを返します。
+	Seen(ctx context.Context, id string) (bool, error)
+	// Put は key に value を ttl の間保存します。
+	Put(ctx context.Context, key, value string, ttl time.Duration) error
+	// Get は key の値を返します。存在しない (期限切れを含む) 場合は false を返します。
+	Get(ctx context.Context, key string) (string, bool, error)
+	// Close はストアの接続などを解放します。
+	Close() error
+}
+
+// Options はストアの作成に使用する設定です。
+type Options struct {
+	// RedisAddr は Redis サーバーのアドレス (host:port) です。
+	RedisAddr string
+	// RedisPassword は Redis の AUTH に使用するパスワードです。空の場合は認証しません。
+	RedisPassword string
+	// RedisDB は使用する Redis のデータベース番号です。
+	RedisDB int
+}
+
+// New は種別名からストアを作成します。
+func New(kind string, opts Options) (Store, error) {
+	switch kind {
+	case StoreMemory, "":
+		return NewMemoryStore(), nil
+	case StoreRedis:
+		return NewRedisStore(opts.RedisAddr, opts.RedisPassword, opts.RedisDB)
+	default:
+		return nil, fmt.Errorf("unknown state store %q (use %q or %q)", kind, StoreMemory, StoreRedis)
+	}
+}
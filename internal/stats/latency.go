@@ -0,0 +1,104 @@
+package stats
+
+import "time"
+
+// latencyBuckets は応答の所要時間のヒストグラムのバケットの上限です。最後のバケットの上限を超えた値は超過バケットに数えます。
+var latencyBuckets = [...]time.Duration{
+	500 * time.Millisecond,
+	1 * time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	20 * time.Second,
+	30 * time.Second,
+	60 * time.Second,
+}
+
+// LatencyBuckets はヒストグラムのバケットの上限を返します (Histogram.Counts の超過バケットを除く各要素に対応)。
+func LatencyBuckets() []time.Duration {
+	return latencyBuckets[:]
+}
+
+// Latency は 1 件の応答の、コメントの投稿から応答の投稿までの所要時間の内訳です。
+type Latency struct {
+	// Fetch はコメントの投稿から取得までの時間です (ポーリング間隔の待ちを含む)。
+	Fetch time.Duration
+	// Queue は取得から処理の開始までの時間です (同じバッチの先のコメントの処理待ち)。
+	Queue time.Duration
+	// Generate は応答の生成にかかった時間です。
+	Generate time.Duration
+	// Post は生成の完了から投稿の完了までの時間です (--post-delay の待ちを含む)。
+	Post time.Duration
+	// Total はコメントの投稿から応答の投稿の完了までの時間です。
+	Total time.Duration
+}
+
+// Histogram は所要時間の分布です。
+type Histogram struct {
+	// Counts は各バケット (LatencyBuckets) に入った件数で、最後の要素は最大のバケットを超えた件数です。
+	Counts [len(latencyBuckets) + 1]int
+	Count  int
+	Sum    time.Duration
+	Max    time.Duration
+}
+
+// observe は所要時間を 1 件記録します。
+func (h *Histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBuckets) && d > latencyBuckets[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+	h.Max = max(h.Max, d)
+}
+
+// Mean は平均の所要時間を返します。記録がない場合は 0 です。
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile は q (0〜1) 分位点を含むバケットの上限を返します (超過バケットの場合は最大値)。記録がない場合は 0 です。
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := int(q*float64(h.Count) + 0.5)
+	rank = min(max(rank, 1), h.Count)
+	seen := 0
+	for i, n := range h.Counts {
+		seen += n
+		if seen >= rank {
+			if i < len(latencyBuckets) {
+				return min(latencyBuckets[i], h.Max)
+			}
+			break
+		}
+	}
+	return h.Max
+}
+
+// LatencyHistograms は応答の所要時間の内訳ごとのヒストグラムです。
+type LatencyHistograms struct {
+	Fetch    Histogram
+	Queue    Histogram
+	Generate Histogram
+	Post     Histogram
+	Total    Histogram
+}
+
+// RecordLatency は投稿した応答の所要時間の内訳を記録します。
+func (r *Recorder) RecordLatency(l Latency) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.latency.Fetch.observe(l.Fetch)
+	r.latency.Queue.observe(l.Queue)
+	r.latency.Generate.observe(l.Generate)
+	r.latency.Post.observe(l.Post)
+	r.latency.Total.observe(l.Total)
+}
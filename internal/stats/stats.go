@@ -0,0 +1,159 @@
+package stats
+
+import (
+	"sync"
+	"time"
+)
+
+// Gemini の概算コストの計算に使用する 100 万トークンあたりの単価 (USD)。
+// gemini-2.5-flash の公開価格に基づく目安であり、モデルや料金改定によって実際の請求額とは異なります。
+const (
+	estimatedInputCostPerMillion  = 0.30
+	estimatedOutputCostPerMillion = 2.50
+)
+
+// recentLimit は直近のコメント・応答として保持する最大件数です。
+const recentLimit = 20
+
+// Entry は直近のコメントまたは AI 応答の 1 件を表します。
+type Entry struct {
+	Time   time.Time
+	Author string
+	Text   string
+}
+
+// Snapshot はある時点での実行統計のコピーです。
+type Snapshot struct {
+	StartedAt         time.Time
+	CommentsProcessed int
+	RepliesPosted     int
+	Errors            int
+	SkipsByReason     map[string]int
+	PollInterval      time.Duration
+	PromptTokens      int64
+	ResponseTokens    int64
+	RecentComments    []Entry
+	RecentReplies     []Entry
+	// Latency はコメントの投稿から応答の投稿までの所要時間の内訳です。
+	Latency LatencyHistograms
+}
+
+// Recorder はパイプラインの実行統計を記録します。
+// 複数のゴルーチン (パイプライン、ダッシュボードなど) から安全に利用できます。
+type Recorder struct {
+	mu sync.Mutex
+
+	startedAt         time.Time
+	commentsProcessed int
+	repliesPosted     int
+	errors            int
+	skipsByReason     map[string]int
+	pollInterval      time.Duration
+	promptTokens      int64
+	responseTokens    int64
+
+	// 直近のコメント・応答 (古い順、最大 recentLimit 件)
+	recentComments []Entry
+	recentReplies  []Entry
+
+	// latency は投稿した応答の所要時間の内訳です。
+	latency LatencyHistograms
+}
+
+// NewRecorder は新しい Recorder インスタンスを作成します。
+func NewRecorder() *Recorder {
+	return &Recorder{
+		startedAt:     time.Now(),
+		skipsByReason: make(map[string]int),
+	}
+}
+
+// RecordComment は受信したコメントを記録します。
+func (r *Recorder) RecordComment(author, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.commentsProcessed++
+	r.recentComments = appendRecent(r.recentComments, Entry{Time: time.Now(), Author: author, Text: message})
+}
+
+// RecordReply は投稿した AI 応答を記録します。author は応答先のコメント投稿者です。
+func (r *Recorder) RecordReply(author, reply string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.repliesPosted++
+	r.recentReplies = appendRecent(r.recentReplies, Entry{Time: time.Now(), Author: author, Text: reply})
+}
+
+// RecordError はエラーの発生回数を加算します。
+func (r *Recorder) RecordError() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.errors++
+}
+
+// RecordSkip は応答をスキップしたコメントを理由別に記録します。
+func (r *Recorder) RecordSkip(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.skipsByReason[reason]++
+}
+
+// RecordTokens は Gemini のトークン使用量を加算します。
+func (r *Recorder) RecordTokens(promptTokens, responseTokens int32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.promptTokens += int64(promptTokens)
+	r.responseTokens += int64(responseTokens)
+}
+
+// SetPollInterval は現在のポーリング間隔を記録します。
+func (r *Recorder) SetPollInterval(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pollInterval = d
+}
+
+// Snapshot は現在の統計のコピーを返します。
+func (r *Recorder) Snapshot() Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	skips := make(map[string]int, len(r.skipsByReason))
+	for reason, n := range r.skipsByReason {
+		skips[reason] = n
+	}
+
+	return Snapshot{
+		StartedAt:         r.startedAt,
+		CommentsProcessed: r.commentsProcessed,
+		RepliesPosted:     r.repliesPosted,
+		Errors:            r.errors,
+		SkipsByReason:     skips,
+		PollInterval:      r.pollInterval,
+		PromptTokens:      r.promptTokens,
+		ResponseTokens:    r.responseTokens,
+		RecentComments:    append([]Entry(nil), r.recentComments...),
+		RecentReplies:     append([]Entry(nil), r.recentReplies...),
+		Latency:           r.latency,
+	}
+}
+
+// EstimatedCostUSD はトークン使用量から Gemini の概算コスト (USD) を計算します。
+func (s Snapshot) EstimatedCostUSD() float64 {
+	return float64(s.PromptTokens)/1e6*estimatedInputCostPerMillion + float64(s.ResponseTokens)/1e6*estimatedOutputCostPerMillion
+}
+
+// appendRecent はエントリを追加し、recentLimit を超えた古いエントリを破棄します。
+func appendRecent(entries []Entry, e Entry) []Entry {
+	entries = append(entries, e)
+	if len(entries) > recentLimit {
+		entries = entries[len(entries)-recentLimit:]
+	}
+	return entries
+}
@@ -0,0 +1,101 @@
+package transcript
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// csvHeader は CSV エクスポートのヘッダー行です。
+var csvHeader = []string{"timestamp", "author", "comment", "reply", "posted", "skip_reason"}
+
+// CSVRow は CSV エクスポートの 1 行 (処理した 1 件のコメント) です。
+type CSVRow struct {
+	Time       time.Time
+	Author     string
+	Comment    string
+	Reply      string
+	Posted     bool
+	SkipReason string
+}
+
+// CSVWriter は処理したコメントと応答を、表計算ソフトで開ける CSV ファイルに書き出します (--csv-out)。
+// カンマ・引用符・改行を含むメッセージは encoding/csv により正しく引用符で囲まれます。
+// 書き込みはバッファされ、Close 時にファイルへ書き出されます。
+type CSVWriter struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *csv.Writer
+}
+
+// NewCSVWriter は CSV ファイルを開きます。既存のファイルには追記し、空のファイルの場合はヘッダー行を書き込みます。
+func NewCSVWriter(path string) (*CSVWriter, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create CSV directory: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat CSV file: %w", err)
+	}
+
+	cw := &CSVWriter{file: f, w: csv.NewWriter(f)}
+	if info.Size() == 0 {
+		if err := cw.w.Write(csvHeader); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to write CSV header: %w", err)
+		}
+	}
+	return cw, nil
+}
+
+// Write は 1 行を書き込みます。
+func (c *CSVWriter) Write(row CSVRow) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return fmt.Errorf("CSV writer is closed")
+	}
+	record := []string{
+		row.Time.Format(time.RFC3339),
+		row.Author,
+		row.Comment,
+		row.Reply,
+		strconv.FormatBool(row.Posted),
+		row.SkipReason,
+	}
+	if err := c.w.Write(record); err != nil {
+		return fmt.Errorf("failed to write CSV row: %w", err)
+	}
+	return nil
+}
+
+// Close はバッファを書き出してファイルを閉じます。
+func (c *CSVWriter) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	c.w.Flush()
+	flushErr := c.w.Error()
+	closeErr := c.file.Close()
+	c.file = nil
+	if flushErr != nil {
+		return fmt.Errorf("failed to flush CSV: %w", flushErr)
+	}
+	return closeErr
+}
@@ -0,0 +1,247 @@
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"prompter-live-go/internal/util"
+)
+
+// flushInterval はバッファされた書き込みを定期的にファイルへ書き出す間隔です。
+const flushInterval = 5 * time.Second
+
+// RotateDaily はローテーション指定 (--transcript-rotate) で日付ごとのローテーションを表す値です。
+const RotateDaily = "daily"
+
+// SchemaVersion はトランスクリプトの行の形式のバージョンです。
+// フィールドの削除・意味の変更など、既存の読み手と互換性のない変更を行う場合にのみ上げます。
+const SchemaVersion = 1
+
+// エントリの種別
+const (
+	KindComment = "comment"
+	KindReply   = "reply"
+	// KindDisposition は 1 件のコメントの最終的な処理結果 (応答した、またはスキップした理由) です。
+	KindDisposition = "disposition"
+	// KindShadow はシャドーモード (--shadow-instruction-file) で別のシステム指示から生成した、投稿しない応答です。
+	KindShadow = "shadow"
+)
+
+// Entry はトランスクリプトの 1 行 (JSON Lines) です。
+// Kind が KindReply の場合、Author は応答先のコメント投稿者です。
+// Kind が KindDisposition の場合、Disposition に処理結果 ("replied" またはスキップ理由)、Text に投稿した応答を記録します。
+// Kind が KindShadow の場合、Author は応答先のコメント投稿者で、Text は空のこともあります (モデルが応答しなかった場合)。
+type Entry struct {
+	SchemaVersion int       `json:"schema_version"`
+	Time          time.Time `json:"time"`
+	Kind          string    `json:"kind"`
+	CommentID     string    `json:"comment_id,omitempty"`
+	AuthorID      string    `json:"author_id,omitempty"`
+	Author        string    `json:"author"`
+	Text          string    `json:"text"`
+	Disposition   string    `json:"disposition,omitempty"`
+}
+
+// Rotation はファイルのローテーション条件です。MaxBytes と Daily のどちらも未設定の場合はローテーションしません。
+type Rotation struct {
+	// MaxBytes が 0 より大きい場合、ファイルサイズがこの値を超える前に新しいファイルに切り替えます。
+	MaxBytes int64
+	// Daily が true の場合、日付が変わったときに新しいファイルに切り替えます。
+	Daily bool
+}
+
+// ParseRotation は "100MB" や "daily" のようなローテーション指定を解析します。空文字列はローテーションなしです。
+func ParseRotation(spec string) (Rotation, error) {
+	spec = strings.TrimSpace(strings.ToUpper(spec))
+	if spec == "" {
+		return Rotation{}, nil
+	}
+	if spec == strings.ToUpper(RotateDaily) {
+		return Rotation{Daily: true}, nil
+	}
+
+	size, err := util.ParseByteSize(spec)
+	if err != nil {
+		return Rotation{}, fmt.Errorf("invalid transcript rotation %q (use a size such as 100MB or %q)", spec, RotateDaily)
+	}
+	return Rotation{MaxBytes: size}, nil
+}
+
+// Writer はコメントと応答を JSON Lines 形式でファイルに記録します。
+// 書き込みはバッファされ、定期的および Close 時にファイルへ書き出されます。
+// ローテーションは行単位で行うため、1 行が 2 つのファイルに分割されることはありません。
+type Writer struct {
+	basePath string
+	rotation Rotation
+
+	mu       sync.Mutex
+	file     *os.File
+	buf      *bufio.Writer
+	size     int64
+	openedAt time.Time
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewWriter は basePath を基にしたトランスクリプトファイルを作成し、定期的な書き出しを開始します。
+// ローテーションが有効な場合、ファイル名には作成時刻が付与されます (例: transcript-20060102-150405.jsonl)。
+func NewWriter(basePath string, rotation Rotation) (*Writer, error) {
+	w := &Writer{
+		basePath: basePath,
+		rotation: rotation,
+		done:     make(chan struct{}),
+	}
+	if err := w.openFile(time.Now()); err != nil {
+		return nil, err
+	}
+
+	w.wg.Add(1)
+	go w.flushLoop()
+	return w, nil
+}
+
+// Write はエントリを 1 行の JSON として書き込みます。必要に応じて書き込み前にファイルをローテーションします。
+func (w *Writer) Write(e Entry) error {
+	e.SchemaVersion = SchemaVersion
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode transcript entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return fmt.Errorf("transcript writer is closed")
+	}
+
+	// 行を書き込む前にローテーションを判定する (行の途中で切り替わらないようにする)
+	if w.shouldRotate(e.Time, int64(len(line))) {
+		if err := w.closeFile(); err != nil {
+			log.Printf("Warning: Failed to close transcript file during rotation: %v", err)
+		}
+		if err := w.openFile(e.Time); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.buf.Write(line)
+	w.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write transcript entry: %w", err)
+	}
+	return nil
+}
+
+// Close はバッファを書き出してファイルを閉じ、定期的な書き出しを停止します。
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.file == nil {
+		w.mu.Unlock()
+		return nil
+	}
+	close(w.done)
+	err := w.closeFile()
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	return err
+}
+
+// flushLoop は flushInterval ごとにバッファをファイルへ書き出します。
+func (w *Writer) flushLoop() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			if w.buf != nil {
+				if err := w.buf.Flush(); err != nil {
+					log.Printf("Warning: Failed to flush transcript: %v", err)
+				}
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// shouldRotate は次の行 (size バイト) を書き込む前にファイルを切り替えるべきかを判定します。
+// 呼び出し時には mu を保持している必要があります。
+func (w *Writer) shouldRotate(now time.Time, size int64) bool {
+	if w.rotation.Daily && !sameDay(w.openedAt, now) {
+		return true
+	}
+	// 空のファイルに収まらない大きな行は、そのまま書き込む (ローテーションを繰り返さない)
+	return w.rotation.MaxBytes > 0 && w.size > 0 && w.size+size > w.rotation.MaxBytes
+}
+
+// openFile は新しいトランスクリプトファイルを開きます。呼び出し時には mu を保持している必要があります。
+func (w *Writer) openFile(now time.Time) error {
+	path := w.filePath(now)
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create transcript directory: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open transcript file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat transcript file: %w", err)
+	}
+
+	w.file = f
+	w.buf = bufio.NewWriter(f)
+	w.size = info.Size()
+	w.openedAt = now
+	log.Printf("Writing transcript to %s", path)
+	return nil
+}
+
+// closeFile はバッファを書き出して現在のファイルを閉じます。呼び出し時には mu を保持している必要があります。
+func (w *Writer) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+	flushErr := w.buf.Flush()
+	closeErr := w.file.Close()
+	w.file, w.buf = nil, nil
+	if flushErr != nil {
+		return fmt.Errorf("failed to flush transcript: %w", flushErr)
+	}
+	return closeErr
+}
+
+// filePath はファイルを開く時刻に対応するパスを返します。ローテーションしない場合は basePath そのものです。
+func (w *Writer) filePath(now time.Time) string {
+	if !w.rotation.Daily && w.rotation.MaxBytes <= 0 {
+		return w.basePath
+	}
+	ext := filepath.Ext(w.basePath)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(w.basePath, ext), now.Format("20060102-150405"), ext)
+}
+
+// sameDay は 2 つの時刻が同じ日付 (ローカル時刻) かどうかを判定します。
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Local().Date()
+	by, bm, bd := b.Local().Date()
+	return ay == by && am == bm && ad == bd
+}
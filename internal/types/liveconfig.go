@@ -1,12 +1,39 @@
 package types
 
-import "time"
+import (
+	"fmt"
+	"regexp"
+	"text/template"
+	"time"
+)
+
+// DefaultLegacySystemPromptAck は互換モード (--legacy-system-prompt) の既定の了承の応答です。
+const DefaultLegacySystemPromptAck = "Ok, I understand."
 
 // LiveAPIConfig は Gemini Live API の設定を保持します。
 // NewClient (internal/gemini/client.go) で初期化時に使用されます。
 type LiveAPIConfig struct {
 	ModelName         string
 	SystemInstruction string
+	// MaxConcurrentRequests は同時に実行できる Gemini リクエスト数の上限です。
+	MaxConcurrentRequests int
+	// StructuredActions が true の場合、モデルに JSON 形式のアクション
+	// ({"action":"answer","text":"..."} または {"action":"ignore"}) で応答させ、
+	// "answer" の場合のみ投稿します。モデル自身が応答を見送れるようになります。
+	StructuredActions bool
+	// FallbackModels は主モデル (ModelName) がクォータ超過・レート制限・一時的なエラーで失敗した場合に、
+	// 順に試すモデル名の一覧です。
+	FallbackModels []string
+	// LegacySystemPrompt が true の場合、システム指示をモデルのネイティブなシステム指示ではなく、
+	// 会話の最初のターン (ユーザーの指示と LegacySystemPromptAck の応答) として渡します (互換用)。
+	LegacySystemPrompt bool
+	// LegacySystemPromptAck は互換モードでシステム指示のターンに続けるモデルの了承の応答です
+	// (空の場合は DefaultLegacySystemPromptAck)。
+	LegacySystemPromptAck string
+	// CandidateCount は 1 回の応答で生成する候補の数です (1 未満の場合は 1)。
+	CandidateCount int
+	// Temperature は生成の温度です。nil の場合はモデルの既定値を使用します。
+	Temperature *float32
 }
 
 // LiveStreamData は Live Chat からの入力データ構造体です。
@@ -23,9 +50,183 @@ type LiveStreamData struct {
 type LowLatencyResponse struct {
 	ResponseText string
 	Done         bool // ストリームの終了を示すフラグ
+	// PromptTokens / ResponseTokens は Gemini が報告したトークン使用量です (不明な場合は 0)。
+	PromptTokens   int32
+	ResponseTokens int32
+	// Candidates は複数の応答候補を生成した場合 (--candidate-count) のすべての候補です。
+	// ResponseText は最初の候補です。候補が 1 件の場合は空の場合があります。
+	Candidates []string
+	// Err は応答の生成に失敗した場合のエラーです。この場合 ResponseText は空であり、
+	// エラーの内容を応答テキストとして投稿してはいけません。
+	Err error
+}
+
+// MinPollingInterval はライブチャットをポーリングする間隔の下限です。
+// これより短い間隔は API を過剰に呼び出すため、設定値・API の推奨値ともにこの値で下限を設けます。
+const MinPollingInterval = time.Second
+
+// RecommendedMinPollingInterval は YouTube が一般的に推奨するポーリング間隔の下限です。
+const RecommendedMinPollingInterval = 2 * time.Second
+
+// API がポーリング間隔を返さない (0 の) 場合に使用する間隔の既定値と下限です。
+// 推奨値がない状態で短い間隔のポーリングを続けてクォータを消費しないよう、通常の下限より長くしています。
+const (
+	DefaultPollingFallback = 10 * time.Second
+	MinPollingFallback     = 5 * time.Second
+)
+
+// 応答の絵文字の方針 (--emoji-policy の値)
+const (
+	// EmojiAllow は応答の絵文字をそのまま投稿します。
+	EmojiAllow = "allow"
+	// EmojiStrip は応答から絵文字を取り除きます。
+	EmojiStrip = "strip"
+	// EmojiRequire は応答に少なくとも 1 つの絵文字を含めます。
+	EmojiRequire = "require"
+)
+
+// ActiveWindow は応答を生成・投稿する 1 日の時間帯 (--active-window) です。
+// Start と End は 0 時からの経過時間 (ローカル時刻) で、End が Start より前の場合は日付をまたぎます。
+type ActiveWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// String は時間帯を "HH:MM-HH:MM" 形式で返します。
+func (w ActiveWindow) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return clock(w.Start) + "-" + clock(w.End)
+}
+
+// FAQEntry は FAQ ファイル (--faq-file) の質問と回答の 1 組です。
+type FAQEntry struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
 }
 
 // PipelineConfig はパイプライン動作のための設定を保持します。
 type PipelineConfig struct {
 	PollingInterval time.Duration
+	// PollingFallback は API がポーリング間隔を返さなかった (0 の) 場合に使用する間隔です。
+	PollingFallback time.Duration
+	// ReplyProbability は応答対象のコメントに実際に応答する確率 (0.0〜1.0) です。
+	// 1.0 の場合はすべてのコメントに応答します。
+	ReplyProbability float64
+	// RandomSeed は応答確率・スタイル指示の選択などに使う乱数のシードです。0 の場合は現在時刻から生成します。
+	RandomSeed int64
+	// CelebrateMembers が true の場合、新規メンバー加入やマイルストーンのイベントにお祝いの応答を行います。
+	CelebrateMembers bool
+	// ChatUnavailableRetry はチャットが無効化・登録者限定などで参加できない場合の再試行間隔です。
+	// 0 の場合は再試行せずにパイプラインを終了します。
+	ChatUnavailableRetry time.Duration
+	// SkipLinks が true の場合、URL を含むコメントには応答しません。
+	SkipLinks bool
+	// RespectDeletions が true の場合、モデレーターに削除されたコメントへの応答を投稿しません。
+	RespectDeletions bool
+	// VerifyPosts が true の場合、投稿した応答が次回以降のポーリングでチャットに表示されたかを確認し、
+	// 表示されない場合はフィルタリングされた可能性として警告します。
+	VerifyPosts bool
+	// ResponseLanguage は応答に使用する言語です (例: "Japanese")。
+	// 空の場合はコメントごとに言語を自動判定し、その言語で応答させます。
+	ResponseLanguage string
+	// DigestInterval が 0 より大きい場合、コメントに個別に応答する代わりに、
+	// この間隔ごとに受信したコメントをまとめて 1 件のダイジェスト応答を投稿します。
+	DigestInterval time.Duration
+	// StyleVariants は応答ごとに 1 つを無作為に選んでプロンプトに付与するスタイル指示です。
+	// 書き出しや口調を変化させ、応答が単調になるのを防ぎます。空の場合は付与しません。
+	StyleVariants []string
+	// NoPost が true の場合、AI 応答の生成は実際に行いますが YouTube への投稿は行いません (プレビューモード)。
+	// 生成された応答はログに出力され、トークン使用量も通常どおり記録されます。
+	NoPost bool
+	// ThinkingPlaceholder が空でない場合、応答生成に時間がかかっているときに
+	// このテキストを先に投稿し、生成完了後に本来の応答を投稿します。
+	ThinkingPlaceholder string
+	// EmptyReplyFallback が空でない場合、生成した応答が (安全性によるブロックなどで) 空になったときに、
+	// スキップする代わりにこのテキストを投稿します。
+	EmptyReplyFallback string
+	// PostDelay は応答を生成してから YouTube に投稿するまでの待ち時間です。0 の場合は即座に投稿します。
+	PostDelay time.Duration
+	// MaxInputChars は AI に送信するコメント本文の最大文字数 (rune 数) です。
+	// 超えた部分は切り詰めます。0 以下の場合は制限しません。
+	MaxInputChars int
+	// IgnoreChannels は応答しない投稿者のチャンネルID (UC...) の一覧です。
+	// 同じ配信で動作する他のボットとの応答の応酬を防ぎます。
+	IgnoreChannels []string
+	// Prioritize が true の場合、各ポーリングで取得したコメントを Super Chat の金額、モデレーター、
+	// メンバーの順に並べ替えてから応答します (到着順とは異なる順序で応答する場合があります)。
+	Prioritize bool
+	// StripMeta が true の場合、応答の先頭のメタ的な前置き ("Sure! Here's a response:" など) を取り除いてから投稿します。
+	StripMeta bool
+	// MetaPatterns は StripMeta で取り除く前置きのパターンです。空の場合は既定のパターンを使用します。
+	MetaPatterns []*regexp.Regexp
+	// SkipSpamAuthors が true の場合、表示名がスパムのパターン (末尾の長い数字、見た目の似た文字など) に
+	// 一致する投稿者のコメントには応答を生成しません。
+	SkipSpamAuthors bool
+	// AuthorSpamPatterns は SkipSpamAuthors で使用する表示名のパターンです。空の場合は既定のパターンを使用します。
+	AuthorSpamPatterns []*regexp.Regexp
+	// MaxCommentAge が 0 より大きい場合、投稿からこの時間以上経過したコメントには応答しません。
+	// 再起動直後に取得される過去のコメントにまとめて応答するのを防ぎます。
+	MaxCommentAge time.Duration
+	// ActiveAfter が 0 より大きい場合、起動からこの時間が経過するまで応答を生成・投稿しません (コメントの取得は続けます)。
+	ActiveAfter time.Duration
+	// ActiveWindow が nil でない場合、この時間帯の外では応答を生成・投稿しません (コメントの取得は続けます)。
+	ActiveWindow *ActiveWindow
+	// TrackViewers が true の場合、定期的に取得した同時視聴者数と高評価数をプロンプトに含めます。
+	TrackViewers bool
+	// ViewerMilestoneStep が 0 より大きい場合、同時視聴者数がこの倍数の節目に達した直後の応答でそれに触れるよう指示します。
+	ViewerMilestoneStep int
+	// OfflineQueueSize が 0 より大きい場合、ネットワークエラーで投稿できなかった応答を最大この件数まで保持し、
+	// 接続の回復後に投稿します。OfflineQueueMaxAge より古くなった応答は投稿せずに破棄します。
+	OfflineQueueSize   int
+	OfflineQueueMaxAge time.Duration
+	// RecapOnExit が true の場合、正常終了時によく寄せられた質問とその応答のまとめを生成して投稿します。
+	RecapOnExit bool
+	// OmitAuthor が true の場合、プロンプトに投稿者名を含めず、コメント本文のみをモデルに送信します
+	// (モデルが視聴者を名前で呼ばないようにします)。
+	OmitAuthor bool
+	// PromptTemplate が設定されている場合、コメント (メンバーシップ関連イベントを除く) からプロンプトを組み立てる際に、
+	// 既定の "<author> says: <message>" の代わりにこのテンプレートを使用します。
+	PromptTemplate *template.Template
+	// FAQ はコメントに関連する項目を参考資料としてプロンプトに含める FAQ です。空の場合は使用しません。
+	FAQ []FAQEntry
+	// ReplyLengthFactor が 0 より大きい場合、応答の文字数の上限をコメントの文字数のこの倍数とし、
+	// ReplyLengthMin〜ReplyLengthMax の範囲に収めます (YouTube の 200 文字の上限は常に適用されます)。
+	ReplyLengthFactor float64
+	ReplyLengthMin    int
+	ReplyLengthMax    int
+	// NormalizeWidth が true の場合、投稿前に応答の全角英数字を半角に、半角カタカナを全角に揃えます。
+	NormalizeWidth bool
+	// EmojiPolicy は応答の絵文字の扱いです (EmojiAllow、EmojiStrip、EmojiRequire)。空の場合は EmojiAllow と同じです。
+	EmojiPolicy string
+	// MaxSentences が 0 より大きい場合、モデルに文数の上限を指示し、応答をこの文数までに切り詰めます。
+	// 文字数の上限 (YouTube の 200 文字) は文数に関わらず常に適用されます。
+	MaxSentences int
+	// SuperChatTemplate は Super Chat への応答の先頭に付ける感謝の一文です (例: "💎 Thanks for the {amount} Super Chat! ")。
+	// {amount} は金額の表示、{author} は投稿者名に置き換えられます。空の場合は付けません。
+	SuperChatTemplate string
+	// QuestionCooldown が 0 より大きい場合、この時間内に (投稿者に関わらず) 応答済みの質問と
+	// 正規化して同じ質問には新しい応答を生成しません。
+	QuestionCooldown time.Duration
+	// RepostRepeatedAnswer が true の場合、繰り返された質問には前回の応答を再投稿します (false の場合は応答しません)。
+	RepostRepeatedAnswer bool
+	// MaxExchangesPerAuthor が 0 より大きい場合、同じ投稿者への連続した応答をこの回数までに制限します。
+	// 最後の応答から ExchangeResetGap が経過すると回数はリセットされます。
+	MaxExchangesPerAuthor int
+	// ExchangeResetGap は MaxExchangesPerAuthor の回数をリセットするまでの、最後の応答からの時間です。
+	ExchangeResetGap time.Duration
+	// CandidateStrategy は複数の応答候補から投稿する候補を選ぶ方法です (CandidateFirst など)。
+	CandidateStrategy string
 }
+
+// 応答候補の選択方法 (--candidate-strategy フラグの値)
+const (
+	// CandidateFirst は最初の候補を使用します。
+	CandidateFirst = "first"
+	// CandidateRandom は候補から無作為に選びます。
+	CandidateRandom = "random"
+	// CandidateShortestFit は YouTube の文字数の上限に収まる候補のうち最も短いものを選びます
+	// (収まる候補がなければ最も短い候補)。
+	CandidateShortestFit = "shortest-fit"
+)
@@ -0,0 +1,72 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// ErrInstanceLocked は別のプロセスが同じロックを保持していることを示します。
+var ErrInstanceLocked = errors.New("別のインスタンスが実行中です")
+
+// unsafeLockNameChars はロックファイル名に使用できない文字です。
+var unsafeLockNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// InstanceLock は同じチャンネルに対する多重起動を防ぐためのファイルロックです。
+// ロックは OS が管理するため、プロセスが異常終了した場合も自動的に解放されます。
+type InstanceLock struct {
+	path string
+	file *os.File
+}
+
+// InstanceLockPath は dir 内の key (チャンネルIDなど) に対応するロックファイルのパスを返します。
+func InstanceLockPath(dir, key string) string {
+	return filepath.Join(dir, "prompter_live."+FileNameKey(key)+".lock")
+}
+
+// FileNameKey は key (チャンネルIDなど) をファイル名の一部として使える形に変換します。
+// 英数字・"_"・"-" 以外の文字は "_" に置き換え、空の場合は "default" を返します。
+func FileNameKey(key string) string {
+	if key == "" {
+		return "default"
+	}
+	return unsafeLockNameChars.ReplaceAllString(key, "_")
+}
+
+// AcquireInstanceLock は path のロックファイルの排他ロックを取得します。
+// 別のプロセスがロックを保持している場合は ErrInstanceLocked を返します (待機しません)。
+func AcquireInstanceLock(path string) (*InstanceLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("ロックファイルを開けません: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	// 調査しやすいよう、ロックを保持しているプロセスIDを書き込む
+	if err := f.Truncate(0); err == nil {
+		fmt.Fprintf(f, "%d\n", os.Getpid())
+	}
+	return &InstanceLock{path: path, file: f}, nil
+}
+
+// Path はロックファイルのパスを返します。
+func (l *InstanceLock) Path() string {
+	return l.path
+}
+
+// Release はロックを解放します。ロックファイルは削除しません。
+// 削除すると、削除前に古いファイルを開いたプロセスと、同じパスに新しいファイルを作成したプロセスが
+// 別々のファイルのロックを取得し、両方が起動してしまうためです。
+func (l *InstanceLock) Release() error {
+	// 古いプロセスIDが残らないよう、ロックの保持中に内容を消す
+	l.file.Truncate(0)
+	if err := unlockFile(l.file); err != nil {
+		l.file.Close()
+		return fmt.Errorf("ロックの解放に失敗: %w", err)
+	}
+	return l.file.Close()
+}
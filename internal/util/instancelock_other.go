@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package util
+
+import "os"
+
+// lockFile はファイルロックに対応していないプラットフォームでは何もしません (多重起動は検出されません)。
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile はファイルロックに対応していないプラットフォームでは何もしません。
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockFile は flock でファイルの排他ロックを取得します。
+func lockFile(f *os.File) error {
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return ErrInstanceLocked
+		}
+		return fmt.Errorf("ロックの取得に失敗: %w", err)
+	}
+	return nil
+}
+
+// unlockFile は flock のロックを解放します。
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
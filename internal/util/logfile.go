@@ -0,0 +1,155 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotatingFile はログなどを追記するファイルです。
+// maxBytes が 0 より大きい場合、書き込みでサイズが上限を超える前に現在のファイルを
+// タイムスタンプ付きの名前 (例: app.log.20060102-150405) に変更し、新しいファイルに切り替えます。
+// 書き込みはバッファせずにファイルへ直接行うため、異常終了時にもログが失われません。
+type RotatingFile struct {
+	path     string
+	maxBytes int64
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile は path のファイルを追記モードで開きます。
+func OpenRotatingFile(path string, maxBytes int64) (*RotatingFile, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("ログディレクトリの作成に失敗: %w", err)
+		}
+	}
+	f := &RotatingFile{path: path, maxBytes: maxBytes}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write は p をファイルに書き込みます。log パッケージは 1 行を 1 回の Write で書き込むため、
+// ローテーションによって行が 2 つのファイルに分割されることはありません。
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, fmt.Errorf("ファイルは既に閉じられています: %s", f.path)
+	}
+	if f.maxBytes > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxBytes {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close はファイルを同期して閉じます。
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	f.file.Sync()
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// open は path のファイルを開きます。呼び出し時には mu を保持している (または初期化中である) 必要があります。
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("ファイルのオープンに失敗: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("ファイル情報の取得に失敗: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate は現在のファイルをタイムスタンプ付きの名前に変更し、新しいファイルを開きます。
+// 呼び出し時には mu を保持している必要があります。
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("ローテーション前のファイルのクローズに失敗: %w", err)
+	}
+	f.file = nil
+	rotated := f.path + "." + time.Now().Format("20060102-150405")
+	// 同じ秒に複数回ローテーションした場合に、既存のファイルを上書きしない
+	for i := 1; fileExists(rotated); i++ {
+		rotated = fmt.Sprintf("%s.%s.%d", f.path, time.Now().Format("20060102-150405"), i)
+	}
+	if err := os.Rename(f.path, rotated); err != nil {
+		// 名前を変更できなくても、同じファイルへの書き込みは継続する
+		fmt.Fprintf(os.Stderr, "⚠️ ログファイルのローテーションに失敗: %v\n", err)
+	}
+	return f.open()
+}
+
+// fileExists は path にファイルが存在するかを返します。
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// ParseByteSize は "100MB" や "512KB" のようなサイズ指定をバイト数に変換します (B, KB, MB, GB に対応)。
+func ParseByteSize(s string) (int64, error) {
+	spec := strings.ToUpper(strings.TrimSpace(s))
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+	for _, u := range units {
+		if num, ok := strings.CutSuffix(spec, u.suffix); ok {
+			n, err := strconv.ParseInt(strings.TrimSpace(num), 10, 64)
+			if err != nil || n <= 0 {
+				break
+			}
+			return n * u.size, nil
+		}
+	}
+	return 0, fmt.Errorf("不正なサイズ指定です: %q (例: 100MB)", s)
+}
+
+// SetupLogFile はログの出力先に path のファイルを追加します。
+// quiet が true の場合はコンソール (標準エラー出力) には出力せず、ファイルのみに出力します。
+// maxBytes が 0 より大きい場合はサイズでローテーションします。戻り値はシャットダウン時に閉じる必要があります。
+func SetupLogFile(path string, maxBytes int64, quiet bool) (io.Closer, error) {
+	f, err := OpenRotatingFile(path, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	if quiet {
+		log.SetOutput(f)
+	} else {
+		log.SetOutput(io.MultiWriter(os.Stderr, f))
+	}
+	return f, nil
+}
@@ -0,0 +1,43 @@
+package util
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ログレベル (--log-level フラグの値)
+const (
+	LogLevelInfo  = "info"
+	LogLevelDebug = "debug"
+)
+
+// debugEnabled はデバッグログ (Debugf) を出力するかどうかです。
+var debugEnabled bool
+
+// SetLogLevel はログレベルを設定します。info または debug を指定できます。
+func SetLogLevel(level string) error {
+	switch strings.ToLower(level) {
+	case LogLevelInfo, "":
+		debugEnabled = false
+	case LogLevelDebug:
+		debugEnabled = true
+	default:
+		return fmt.Errorf("不明なログレベルです: %q (info または debug を指定してください)", level)
+	}
+	return nil
+}
+
+// DebugEnabled はデバッグログが有効かどうかを返します。
+// ログの引数の組み立てにコストがかかる場合に、事前の判定に使用します。
+func DebugEnabled() bool {
+	return debugEnabled
+}
+
+// Debugf はログレベルが debug の場合のみ、"[DEBUG] " を付けてログを出力します。
+func Debugf(format string, args ...any) {
+	if !debugEnabled {
+		return
+	}
+	log.Printf("[DEBUG] "+format, args...)
+}
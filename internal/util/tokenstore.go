@@ -0,0 +1,99 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/oauth2"
+)
+
+// トークンストアの種別 (--token-store フラグの値)
+const (
+	TokenStoreFile    = "file"
+	TokenStoreKeyring = "keyring"
+)
+
+// keyringService はキーリングに登録する際のサービス名です。
+const keyringService = "prompter-live-go"
+
+// TokenStore は OAuth2 トークンの保存先を抽象化するインターフェースです。
+type TokenStore interface {
+	Load() (*oauth2.Token, error)
+	Save(token *oauth2.Token) error
+}
+
+// NewTokenStore は種別名からトークンストアを作成します。
+// filePath は種別が file の場合のトークンファイルの保存先です。
+func NewTokenStore(kind string, filePath string) (TokenStore, error) {
+	switch kind {
+	case TokenStoreFile, "":
+		return &FileTokenStore{Path: filePath}, nil
+	case TokenStoreKeyring:
+		return &KeyringTokenStore{Service: keyringService, User: "youtube-oauth-token"}, nil
+	default:
+		return nil, fmt.Errorf("不明なトークンストアです: %q (file または keyring を指定してください)", kind)
+	}
+}
+
+// FileTokenStore はトークンを平文の JSON ファイルとして保存します。
+type FileTokenStore struct {
+	Path string
+}
+
+// Load はファイルからトークンを読み込みます。
+func (s *FileTokenStore) Load() (*oauth2.Token, error) {
+	return LoadToken(s.Path)
+}
+
+// Save はトークンをファイルに保存します。
+func (s *FileTokenStore) Save(token *oauth2.Token) error {
+	return SaveToken(s.Path, token)
+}
+
+// String はログ出力用の保存先の説明を返します。
+func (s *FileTokenStore) String() string {
+	return s.Path
+}
+
+// KeyringTokenStore はトークンを OS のキーリング
+// (macOS Keychain, Windows Credential Manager, Linux Secret Service) に保存します。
+type KeyringTokenStore struct {
+	Service string
+	User    string
+}
+
+// Load はキーリングからトークンを読み込みます。
+func (s *KeyringTokenStore) Load() (*oauth2.Token, error) {
+	secret, err := keyring.Get(s.Service, s.User)
+	if err != nil {
+		if errors.Is(err, keyring.ErrNotFound) {
+			return nil, fmt.Errorf("キーリングにトークンが見つかりません: %w", err)
+		}
+		return nil, fmt.Errorf("キーリングからの読み込みに失敗: %w", err)
+	}
+
+	t := &oauth2.Token{}
+	if err := json.Unmarshal([]byte(secret), t); err != nil {
+		return nil, fmt.Errorf("トークンのデコードに失敗: %w", err)
+	}
+	return t, nil
+}
+
+// Save はトークンをキーリングに保存します。
+func (s *KeyringTokenStore) Save(token *oauth2.Token) error {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("トークンのエンコードに失敗: %w", err)
+	}
+	if err := keyring.Set(s.Service, s.User, string(b)); err != nil {
+		return fmt.Errorf("キーリングへの保存に失敗: %w", err)
+	}
+	return nil
+}
+
+// String はログ出力用の保存先の説明を返します。
+func (s *KeyringTokenStore) String() string {
+	return fmt.Sprintf("system keyring (%s/%s)", s.Service, s.User)
+}
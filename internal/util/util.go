@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 
 	"golang.org/x/oauth2"
@@ -58,6 +59,25 @@ func LoadPromptFile(path string) (string, error) {
 	return string(b), nil
 }
 
+// LoadLinesFile はテキストファイルを 1 行 1 項目として読み込みます。
+// 前後の空白は除去し、空行と "#" で始まるコメント行は無視します。
+func LoadLinesFile(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ファイルの読み込みに失敗: %w", err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
 // GetOAuth2Config は環境変数から認証情報 (Client ID, Secret) を読み込み、
 // OAuth2設定オブジェクトを返します。
 // authPort: OAuth認証サーバーがリッスンするポート番号。RedirectURLの生成に使用されます。
@@ -0,0 +1,29 @@
+package version
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// Version はアプリケーションのバージョンです。
+// リリースビルド時に -ldflags "-X prompter-live-go/internal/version.Version=v1.2.3" で上書きされます。
+var Version = "dev"
+
+// RunID はプロセスごとに生成される実行識別子です。
+// API リクエストの User-Agent に含め、Google へのサポート問い合わせ時に実行を特定できるようにします。
+var RunID = newRunID()
+
+// UserAgent は API リクエストに付与する User-Agent 文字列を返します。
+func UserAgent() string {
+	return fmt.Sprintf("prompter-live-go/%s (run %s)", Version, RunID)
+}
+
+// newRunID はランダムな 8 桁の16進数の実行識別子を生成します。
+func newRunID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
@@ -1,24 +1,44 @@
 package youtube
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/youtube/v3"
+
+	"prompter-live-go/internal/util"
 )
 
 // TokenFileName は保存されたトークンファイルの名前です。
 const TokenFileName = "token.json"
 
+const (
+	// tokenExchangeAttempts は認証コードをトークンに交換する際の最大試行回数です。
+	tokenExchangeAttempts = 3
+	// tokenExchangeBackoff は再試行ごとに増加する待機時間の単位です。
+	tokenExchangeBackoff = 2 * time.Second
+
+	// authCallbackTimeout は認証のコールバック (または貼り付け) を待つ時間です。
+	authCallbackTimeout = 5 * time.Minute
+	// authCallbackAttempts はタイムアウト時にリスナーを再起動して待機する回数 (初回を含む) です。
+	authCallbackAttempts = 2
+)
+
 // GetConfigPath は設定ファイルが置かれるディレクトリを取得します。
 // 実際のアプリケーションでは、ユーザーのホームディレクトリなどに設定されます。
 func GetConfigPath() (string, error) {
@@ -50,51 +70,73 @@ func GetOAuth2Config() (*oauth2.Config, error) {
 	return config, nil
 }
 
-// saveToken はトークンをファイルに保存します。
-func saveToken(token *oauth2.Token) error {
-	configPath, err := GetConfigPath()
-	if err != nil {
-		return err
-	}
+// headlessAuth が true の場合、ローカルのコールバックサーバーとブラウザを使わずに認証します。
+var headlessAuth bool
 
-	tokenFile := filepath.Join(configPath, TokenFileName)
+// SetHeadlessAuth はブラウザのないサーバー向けの認証モードを有効にします。
+// 有効な場合、認証 URL を表示し、別の端末で認証した後のリダイレクト URL (または認証コード) を標準入力から読み取ります。
+func SetHeadlessAuth(enabled bool) {
+	headlessAuth = enabled
+}
 
-	f, err := os.OpenFile(tokenFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return fmt.Errorf("unable to cache oauth token: %w", err)
-	}
-	defer f.Close()
+// tokenStore はトークンの保存先です。nil の場合は設定ディレクトリ内の token.json を使用します。
+var tokenStore util.TokenStore
 
-	log.Printf("Saving token to %s", tokenFile)
-	return json.NewEncoder(f).Encode(token)
+// SetTokenStore はトークンの保存先を設定します。
+// GetToken や GetOAuth2Client を呼び出す前に設定する必要があります。
+func SetTokenStore(store util.TokenStore) {
+	tokenStore = store
 }
 
-// loadToken は保存されたトークンファイルを読み込みます。
-func loadToken() (*oauth2.Token, error) {
+// currentTokenStore は現在有効なトークンストアを返します。
+func currentTokenStore() (util.TokenStore, error) {
+	if tokenStore != nil {
+		return tokenStore, nil
+	}
+
 	configPath, err := GetConfigPath()
 	if err != nil {
 		return nil, err
 	}
+	return &util.FileTokenStore{Path: filepath.Join(configPath, TokenFileName)}, nil
+}
+
+// saveToken はトークンをトークンストアに保存します。
+func saveToken(token *oauth2.Token) error {
+	store, err := currentTokenStore()
+	if err != nil {
+		return err
+	}
 
-	tokenFile := filepath.Join(configPath, TokenFileName)
+	log.Printf("Saving token to %v", store)
+	if err := store.Save(token); err != nil {
+		return fmt.Errorf("unable to cache oauth token: %w", err)
+	}
+	return nil
+}
 
-	f, err := os.Open(tokenFile)
+// loadToken は保存されたトークンをトークンストアから読み込みます。
+func loadToken() (*oauth2.Token, error) {
+	store, err := currentTokenStore()
 	if err != nil {
-		// ファイルが存在しない場合はエラーではない（新規認証が必要）
-		return nil, fmt.Errorf("token file not found: %w", err)
+		return nil, err
 	}
-	defer f.Close()
 
-	log.Printf("Loading token from %s", tokenFile)
-	token := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(token)
+	log.Printf("Loading token from %v", store)
+	token, err := store.Load()
 	if err != nil {
-		return nil, fmt.Errorf("error decoding token file: %w", err)
+		// トークンが存在しない場合はエラーではない（新規認証が必要）
+		return nil, fmt.Errorf("token not found: %w", err)
 	}
 	return token, nil
 }
 
+// errAuthCallbackTimeout は認証のコールバックが待機時間内に届かなかったことを示します。
+var errAuthCallbackTimeout = errors.New("authentication timeout")
+
 // getTokenFromWeb はウェブ認証フローを実行し、トークンを取得します。
+// ローカルのコールバックサーバーでリダイレクトを待ち受けるほか、リダイレクト先の URL (または認証コード) を
+// 標準入力に貼り付けて認証を完了することもできます (ブラウザが localhost に到達できないリモートサーバー向け)。
 func getTokenFromWeb(config *oauth2.Config, oauthPort int) (*oauth2.Token, error) {
 	// HTTPサーバーを立ち上げるポートを設定
 	serverPort := strconv.Itoa(oauthPort)
@@ -103,72 +145,271 @@ func getTokenFromWeb(config *oauth2.Config, oauthPort int) (*oauth2.Token, error
 	}
 
 	redirectURL := "http://localhost:" + serverPort
-	config.RedirectURL = fmt.Sprintf("http://localhost:%d/callback", serverPort)
+	config.RedirectURL = fmt.Sprintf("http://localhost:%s/callback", serverPort)
+
+	state, err := newAuthState()
+	if err != nil {
+		return nil, err
+	}
+	authURL := config.AuthCodeURL(state, oauth2.AccessTypeOffline)
+
+	if headlessAuth {
+		return getTokenHeadless(config, authURL, state)
+	}
 
 	// ユーザーに認証を促す
-	log.Printf("Please go to the following URL in your browser and authorize the app:")
+	log.Printf("Please go to the following URL in your browser and authorize the app:\n\n%s\n", authURL)
 	log.Printf("You will be redirected to: %s", redirectURL)
+	log.Println("If your browser cannot reach this machine's localhost (e.g., on a remote server), copy the full URL of the page you were redirected to, paste it here and press Enter.")
+
+	// ブラウザからのリダイレクトが接続拒否にならないよう、ブラウザを開く前に待ち受けを開始する
+	server, err := startCallbackServer(serverPort, state)
+	if err != nil {
+		return nil, err
+	}
+	util.OpenBrowser(authURL)
+
+	// 貼り付けられたリダイレクト URL / 認証コードを受け付ける
+	pasted := readPastedAuthCodes(state)
+
+	// コールバックを待機 (タイムアウトした場合は一度だけリスナーを再起動する)
+	var code string
+	for attempt := 1; attempt <= authCallbackAttempts; attempt++ {
+		if attempt > 1 {
+			if server, err = startCallbackServer(serverPort, state); err != nil {
+				return nil, err
+			}
+		}
+		code, err = waitForAuthCode(server, pasted)
+		// 認証コードの受信・タイムアウトのいずれの場合もサーバーを停止
+		server.Stop()
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errAuthCallbackTimeout) {
+			return nil, err
+		}
+		if attempt < authCallbackAttempts {
+			log.Printf("No authorization received within %v. Waiting another %v; open the URL above again or paste the redirect URL here.", authCallbackTimeout, authCallbackTimeout)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no authorization received within %v; rerun the command to try again: %w", authCallbackAttempts*authCallbackTimeout, err)
+	}
+
+	// 認証コードを使ってトークンを取得
+	token, err := exchangeWithRetry(config, code)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve token from web: %w", err)
+	}
+	return token, nil
+}
 
-	// ローカルサーバーを立ち上げてリダイレクトを待ち受ける
-	ch := make(chan string)
+// getTokenHeadless はコールバックサーバーを使わずに認証します (auth --no-browser)。
+// 認証 URL を表示し、別の端末のブラウザで認証した後に貼り付けられたリダイレクト URL または認証コードをトークンに交換します。
+func getTokenHeadless(config *oauth2.Config, authURL string, state string) (*oauth2.Token, error) {
+	log.Printf("Open the following URL in a browser on any device and authorize the app:\n\n%s\n", authURL)
+	log.Printf("After authorizing, the browser is redirected to %s, which will fail to load. That is expected.", config.RedirectURL)
+	log.Println("Copy the full URL from the browser's address bar (or just the 'code' value), paste it here and press Enter:")
 
-	srv := &http.Server{Addr: ":" + serverPort}
+	code, ok := <-readPastedAuthCodes(state)
+	if !ok {
+		return nil, fmt.Errorf("standard input closed before an authorization code was entered")
+	}
+
+	// 認証コードを使ってトークンを取得
+	token, err := exchangeWithRetry(config, code)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve token from pasted code: %w", err)
+	}
+	return token, nil
+}
 
-	http.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+// callbackServer は OAuth のリダイレクトを受け取るローカルの HTTP サーバーです。
+type callbackServer struct {
+	srv   *http.Server
+	codes chan string
+	// done は Serve を実行するゴルーチンの終了時に閉じられます。
+	done chan struct{}
+}
+
+// startCallbackServer はコールバックサーバーを起動します。
+// ブラウザを開く前に確実に待ち受けを開始し、ポート使用中などのエラーを呼び出し元に返すため、リッスンは同期的に行います。
+func startCallbackServer(serverPort string, state string) (*callbackServer, error) {
+	// (再起動時にハンドラーが重複登録されないよう、呼び出しごとに専用の ServeMux を使う)
+	s := &callbackServer{
+		codes: make(chan string, 1),
+		done:  make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
 		code := r.FormValue("code")
-		if code != "" {
-			w.Header().Set("Content-Type", "text/plain")
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("Authentication successful. You can close this window now."))
-			ch <- code
-		} else {
-			w.Header().Set("Content-Type", "text/plain")
+		if code == "" || r.FormValue("state") != state {
 			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("Authentication failed. No authorization code received."))
-			ch <- ""
+			w.Write([]byte("Authentication failed. No valid authorization code received."))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Authentication successful. You can close this window now."))
+		select {
+		case s.codes <- code:
+		default:
 		}
 	})
 
-	// サーバーを非同期で起動
+	ln, err := net.Listen("tcp", ":"+serverPort)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for OAuth callback on port %s (is it already in use? choose another with --oauth-port): %w", serverPort, err)
+	}
+
+	s.srv = &http.Server{Handler: mux}
 	go func() {
-		log.Printf("Listening for OAuth callback on http://localhost:%d/callback", serverPort)
-		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		defer close(s.done)
+		if err := s.srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
 			log.Printf("Error: HTTP server failed unexpectedly: %v", err)
-			// errorChan に送信するとブロッキングする可能性があるため、ログ出力のみとする
 		}
 	}()
+	log.Printf("Listening for OAuth callback on http://localhost:%s/callback", serverPort)
+	return s, nil
+}
 
-	// タイムアウトを設定
-	ctxTimeout, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
+// Stop はサーバーを停止し、Serve を実行するゴルーチンの終了を待ちます。
+func (s *callbackServer) Stop() {
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if err := s.srv.Shutdown(shutdownCtx); err != nil {
+		// 猶予時間内に接続が閉じられない場合は強制的に閉じる
+		s.srv.Close()
+	}
+	<-s.done
+}
 
-	var code string
-	select {
-	case code = <-ch:
-		// 認証コードを受け取ったらサーバーを停止
-		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer shutdownCancel()
-		srv.Shutdown(shutdownCtx)
-
-		if code == "" {
-			return nil, fmt.Errorf("authorization code was empty")
+// waitForAuthCode はコールバックサーバーまたは標準入力から認証コードを受け取るまで待機します。
+// authCallbackTimeout を過ぎると errAuthCallbackTimeout を返します。
+func waitForAuthCode(server *callbackServer, pasted <-chan string) (string, error) {
+	timeout := time.After(authCallbackTimeout)
+	for {
+		select {
+		case code := <-server.codes:
+			return code, nil
+		case code, ok := <-pasted:
+			if !ok {
+				// 標準入力が閉じられた場合は、コールバックのみを待つ
+				pasted = nil
+				continue
+			}
+			return code, nil
+		case <-timeout:
+			return "", errAuthCallbackTimeout
 		}
+	}
+}
 
-	case <-ctxTimeout.Done():
-		// タイムアウトしたらサーバーを停止
-		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer shutdownCancel()
-		srv.Shutdown(shutdownCtx)
+// readPastedAuthCodes は標準入力を読み取り、貼り付けられたリダイレクト URL または認証コードを
+// チャネルに送信するゴルーチンを開始します。解釈できない入力はログに出力して無視します。
+// 認証コードを得られないまま標準入力が閉じられた場合は、チャネルを閉じます。
+func readPastedAuthCodes(state string) <-chan string {
+	codes := make(chan string, 1)
+	go func() {
+		defer close(codes)
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			code, err := extractAuthCode(line, state)
+			if err != nil {
+				log.Printf("Could not use the pasted input: %v", err)
+				continue
+			}
+			codes <- code
+			return
+		}
+	}()
+	return codes
+}
 
-		return nil, fmt.Errorf("authentication timeout")
+// extractAuthCode は貼り付けられた入力から認証コードを取り出します。
+// 入力がリダイレクト先の URL の場合は code クエリパラメータを (state が含まれていれば照合したうえで) 返し、
+// それ以外の場合は入力全体を認証コードとみなします。
+func extractAuthCode(input string, state string) (string, error) {
+	if !strings.Contains(input, "://") && !strings.HasPrefix(input, "/") && !strings.Contains(input, "code=") {
+		return input, nil
 	}
 
-	// 認証コードを使ってトークンを取得
-	token, err := config.Exchange(context.Background(), code)
+	u, err := url.Parse(input)
 	if err != nil {
-		return nil, fmt.Errorf("unable to retrieve token from web: %w", err)
+		return "", fmt.Errorf("invalid redirect URL: %w", err)
 	}
-	return token, nil
+	query := u.Query()
+	if u.RawQuery == "" && strings.Contains(input, "code=") {
+		// クエリ部分だけが貼り付けられた場合 (例: "code=...&state=...")
+		query, err = url.ParseQuery(strings.TrimPrefix(input, "?"))
+		if err != nil {
+			return "", fmt.Errorf("invalid redirect URL query: %w", err)
+		}
+	}
+	if errParam := query.Get("error"); errParam != "" {
+		return "", fmt.Errorf("authorization was denied: %s", errParam)
+	}
+	if got := query.Get("state"); got != "" && got != state {
+		return "", fmt.Errorf("state mismatch in redirect URL (is it from this authorization request?)")
+	}
+	code := query.Get("code")
+	if code == "" {
+		return "", fmt.Errorf("no 'code' parameter found in the redirect URL")
+	}
+	return code, nil
+}
+
+// newAuthState は CSRF 対策として認可リクエストに付与するランダムな state 値を生成します。
+func newAuthState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate OAuth state: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// exchangeWithRetry は認証コードをトークンに交換します。
+// ネットワークエラーやサーバー側 (5xx) の一時的なエラーの場合のみ、短い待機を挟んで再試行します。
+// invalid_grant などのクライアント側のエラーは再試行しても成功しないため、即座に返します。
+func exchangeWithRetry(config *oauth2.Config, code string) (*oauth2.Token, error) {
+	var lastErr error
+	for attempt := 1; attempt <= tokenExchangeAttempts; attempt++ {
+		token, err := config.Exchange(context.Background(), code)
+		if err == nil {
+			return token, nil
+		}
+		lastErr = err
+
+		if !isTransientExchangeError(err) {
+			return nil, err
+		}
+		if attempt < tokenExchangeAttempts {
+			delay := time.Duration(attempt) * tokenExchangeBackoff
+			log.Printf("Token exchange failed (attempt %d/%d): %v. Retrying in %v...", attempt, tokenExchangeAttempts, err, delay)
+			time.Sleep(delay)
+		} else {
+			log.Printf("Token exchange failed (attempt %d/%d): %v.", attempt, tokenExchangeAttempts, err)
+		}
+	}
+	return nil, fmt.Errorf("token exchange failed after %d attempts: %w", tokenExchangeAttempts, lastErr)
+}
+
+// isTransientExchangeError はトークン交換のエラーが再試行する価値のある一時的なものかを判定します。
+func isTransientExchangeError(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		// サーバーが応答した場合は 5xx のみ一時的とみなす (invalid_grant などは 4xx)
+		return retrieveErr.Response != nil && retrieveErr.Response.StatusCode >= http.StatusInternalServerError
+	}
+
+	// ネットワークエラー (タイムアウト、接続拒否など)
+	var netErr net.Error
+	return errors.As(err, &netErr)
 }
 
 // GetToken は既存のトークンをロードまたはウェブ認証フローを通じて取得します。
@@ -196,8 +437,18 @@ func GetToken(config *oauth2.Config, oauthPort int) (*oauth2.Token, error) {
 }
 
 // GetOAuth2Client は認証済みの *http.Client を返す、外部パッケージ向けの公開関数です。
-// internal/youtube/client.go の NewClient から呼び出されます。
 func GetOAuth2Client(ctx context.Context, oauthPort int) (*http.Client, error) {
+	ts, err := getOAuth2TokenSource(ctx, oauthPort)
+	if err != nil {
+		return nil, err
+	}
+	return oauth2.NewClient(ctx, ts), nil
+}
+
+// getOAuth2TokenSource は保存されたトークン (または新規認証で取得したトークン) から、
+// 自動でリフレッシュしてトークンストアに保存する TokenSource を作成します。
+// internal/youtube/client.go の NewClient から呼び出されます。
+func getOAuth2TokenSource(ctx context.Context, oauthPort int) (oauth2.TokenSource, error) {
 	// 1. OAuth2 設定の取得
 	config, err := GetOAuth2Config()
 	if err != nil {
@@ -210,7 +461,6 @@ func GetOAuth2Client(ctx context.Context, oauthPort int) (*http.Client, error) {
 		return nil, err
 	}
 
-	// 3. 認証済みクライアントの作成 (トークンの自動リフレッシュ機能を含む)
-	client := config.Client(ctx, token)
-	return client, nil
+	// 3. トークンの自動リフレッシュ (とリフレッシュ後の保存) を行う TokenSource の作成
+	return newRefreshingTokenSource(ctx, config, token), nil
 }
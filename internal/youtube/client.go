@@ -5,11 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 	"google.golang.org/api/youtube/v3"
+
+	"prompter-live-go/internal/state"
+	"prompter-live-go/internal/version"
 )
 
 const (
@@ -17,9 +25,44 @@ const (
 	commentIDRetentionDuration = 1 * time.Hour
 )
 
+// LiveChatMessages.List の 1 回の呼び出しで取得するメッセージ数 (maxResults) の範囲と既定値。
+// API の仕様上 200〜2000 の値のみ受け付けられます。
+const (
+	MinFetchBatchSize     = 200
+	MaxFetchBatchSize     = 2000
+	DefaultFetchBatchSize = 500
+)
+
+// MaxMessageLength はライブチャットに投稿できるメッセージの最大文字数 (rune 数) です。
+const MaxMessageLength = 200
+
+// ライブチャットのイベント種別 (LiveChatMessageSnippet.Type の値)
+const (
+	// EventNone は通常のテキストメッセージを示します。
+	EventNone = ""
+	// EventNewMember は新規メンバー加入イベントを示します。
+	EventNewMember = "newSponsorEvent"
+	// EventMemberMilestone はメンバー継続記念 (マイルストーン) イベントを示します。
+	EventMemberMilestone = "memberMilestoneChatEvent"
+
+	// messageDeletedEvent はモデレーターによるメッセージ削除イベントの種別です。
+	messageDeletedEvent = "messageDeletedEvent"
+)
+
 // ErrLiveChatEnded はライブチャットが終了したことを示すカスタムエラー
 var ErrLiveChatEnded = errors.New("live chat ended")
 
+// ErrLiveChatDisabled は配信のライブチャットが無効化されていることを示すカスタムエラー
+var ErrLiveChatDisabled = errors.New("live chat is disabled for this stream")
+
+// ErrNoLiveBroadcast はチャンネルに配信中のライブブロードキャストが見つからないことを示すカスタムエラー。
+// 配信開始直後は YouTube の検索インデックスに反映されるまで、このエラーになることがあります。
+var ErrNoLiveBroadcast = errors.New("no active live broadcast found")
+
+// ErrLiveChatRestricted はライブチャットが登録者限定・メンバー限定などに制限されており、
+// 認証済みアカウントでは参加できないことを示すカスタムエラー
+var ErrLiveChatRestricted = errors.New("live chat is restricted to subscribers or members for this stream")
+
 // Comment は YouTube のライブチャットメッセージを表す構造体
 type Comment struct {
 	ID        string
@@ -27,6 +70,22 @@ type Comment struct {
 	Author    string
 	Message   string // 💡 修正: メッセージ本体のフィールド名は 'Message'
 	Timestamp time.Time
+	// Event はメンバーシップ関連イベントの種別です。通常のメッセージでは EventNone です。
+	// イベントの場合、Message にはイベント内容を説明する合成テキストが入ります。
+	Event string
+	// IsOwner / IsModerator は投稿者がチャットの所有者・モデレーターであるかを示します。
+	IsOwner     bool
+	IsModerator bool
+	// IsMember は投稿者がチャンネルメンバーであるかを示します。
+	IsMember bool
+	// SuperChatAmountMicros は Super Chat の金額 (マイクロ単位、通貨は問わない) です。通常のメッセージでは 0 です。
+	SuperChatAmountMicros uint64
+	// SuperChatAmount は Super Chat の金額の表示用文字列 (例: "¥500") です。通常のメッセージでは空です。
+	SuperChatAmount string
+	// Raw は変換元の API のメッセージです。絵文字の区切りや Super Sticker の詳細など、Comment に含まれない
+	// 情報をフックなどで参照するために使用します。SetPreserveRaw(true) の場合のみ設定され、それ以外は nil です。
+	// 保持するとコメントごとにメッセージ全体がメモリに残ります。
+	Raw *youtube.LiveChatMessage
 }
 
 // Client は YouTube Live Chat API との連携を管理します。
@@ -36,10 +95,56 @@ type Client struct {
 	// 実際の YouTube SDK サービスインスタンスを保持
 	service *youtube.Service
 
+	// mu は以下の可変な状態 (ライブチャットの状態、重複排除・削除の記録、キャッシュ、再開位置) を保護します。
+	// コメントの取得と投稿が別のゴルーチンから同時に呼び出されても安全です。
+	mu sync.Mutex
+
 	// ライブチャットの状態を管理するためのフィールド
-	liveChatID            string
-	nextPageToken         string
-	lastFetchedCommentIDs map[string]time.Time
+	liveChatID    string
+	nextPageToken string
+	// streamChatID は現在の配信状態 (重複排除・削除の記録) が属するライブチャットIDです。
+	// チャット終了などで liveChatID がリセットされても保持し、別の配信に切り替わったかの判定に使用します。
+	streamChatID string
+
+	// seen は取得済みのコメントIDの記録先です (重複排除)。既定はメモリ上で、SetStateStore で
+	// Redis などの共有ストアに変更すると、複数のインスタンスで重複排除を共有できます。
+	// コメントIDは配信をまたいで一意なため、配信の切り替え時にもクリアしません。
+	seen state.Store
+
+	// 以下は配信ごとの状態で、別のライブチャットに切り替わった時点で resetStreamState によりクリアされます。
+	// deletedCommentIDs は削除イベントで通知されたコメントIDと通知時刻です。
+	deletedCommentIDs map[string]time.Time
+
+	// selfChannelID は認証済みアカウント (ボット自身) のチャンネルIDのキャッシュです。
+	selfChannelID string
+
+	// fetchBatchSize は 1 回のポーリングで取得するメッセージの最大数です。
+	fetchBatchSize int64
+	// preserveRaw が true の場合、Comment.Raw に API のメッセージを保持します。
+	preserveRaw bool
+
+	// resumePath が空でない場合、最後に取得したメッセージの投稿時刻を保存し、再起動後の再開位置とします。
+	resumePath string
+	// resume は保存されている (または読み込んだ) 再開位置です。
+	resume resumeState
+	// resumeMu は再開位置のファイルへの書き込みを直列化します (c.mu の外で書き込むため)。
+	resumeMu sync.Mutex
+
+	// streamInfo は現在のライブチャットが属する配信のタイトルと説明です。
+	streamInfo StreamInfo
+	// videoID は現在のライブチャットが属する配信の動画IDです (視聴者数の取得に使用)。
+	videoID string
+	// viewers は直近に取得した視聴者数と高評価数です (RunViewerTracker の実行中のみ更新されます)。
+	viewers ViewerStats
+
+	// tokenSource は OAuth2 のアクセストークンの取得元です (NewClient で作成した場合のみ設定されます)。
+	tokenSource oauth2.TokenSource
+}
+
+// StreamInfo は配信 (ライブ動画) のタイトルと説明です。
+type StreamInfo struct {
+	Title       string
+	Description string
 }
 
 // NewClient は新しい YouTube Client のインスタンスを作成します。
@@ -50,18 +155,35 @@ func NewClient(ctx context.Context, channelID string, oauthPort int) (*Client, e
 
 	log.Printf("YouTube Client: Starting OAuth2 setup using port %d...", oauthPort)
 
-	// 1. 認証済み HTTP クライアントの取得 (GetOAuth2Clientは同じパッケージのauth.goにあります)
-	// GetOAuth2Clientが未定義の場合、Goのビルドシステムはエラーを出しますが、ここでは存在すると仮定
-	// GetOAuth2Client() が GetToken() に依存しているため、ロジックを auth.go の定義に合わせる
-
-	// トークン設定は auth.go に依存するため、ここでは簡略化し、auth.go が適切なクライアントを返すものと仮定する。
-	client, err := GetOAuth2Client(ctx, oauthPort)
+	// 1. 認証済みトークンの取得 (auth.go)
+	// 先行リフレッシュ (RunTokenRefresher) で使用するため、TokenSource を保持する
+	ts, err := getOAuth2TokenSource(ctx, oauthPort)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get authenticated client: %w", err)
 	}
 
-	// 2. YouTube サービスインスタンスの初期化
-	service, err := youtube.NewService(ctx, option.WithHTTPClient(client))
+	// 2. 認証済みクライアントで YouTube Client を作成
+	c, err := NewClientWithHTTPClient(ctx, channelID, oauth2.NewClient(ctx, ts))
+	if err != nil {
+		return nil, err
+	}
+	c.tokenSource = ts
+	return c, nil
+}
+
+// NewClientWithHTTPClient は事前に構築された *http.Client を使用して YouTube Client を作成します。
+// httpClient は YouTube Data API の認証 (OAuth2 など) を処理できる必要があります。
+// プロキシやタイムアウト、計測用のトランスポートを差し込む場合や、テスト用のサーバーに接続する場合に使用します。
+func NewClientWithHTTPClient(ctx context.Context, channelID string, httpClient *http.Client) (*Client, error) {
+	if channelID == "" {
+		return nil, fmt.Errorf("youtube channel ID is empty")
+	}
+	if httpClient == nil {
+		return nil, fmt.Errorf("http client is nil")
+	}
+
+	// YouTube サービスインスタンスの初期化
+	service, err := youtube.NewService(ctx, option.WithHTTPClient(httpClient), option.WithUserAgent(version.UserAgent()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create YouTube service: %w", err)
 	}
@@ -69,14 +191,142 @@ func NewClient(ctx context.Context, channelID string, oauthPort int) (*Client, e
 	log.Printf("YouTube Service successfully initialized for channel %s.", channelID)
 
 	return &Client{
-		channelID:             channelID,
-		service:               service,
-		lastFetchedCommentIDs: make(map[string]time.Time),
+		channelID:         channelID,
+		service:           service,
+		seen:              state.NewMemoryStore(),
+		deletedCommentIDs: make(map[string]time.Time),
+		fetchBatchSize:    DefaultFetchBatchSize,
 	}, nil
 }
 
-// findLiveChatID はチャンネルの現在のライブブロードキャストを見つけ、そのライブチャットIDを返します。
-func (c *Client) findLiveChatID(ctx context.Context) (string, error) {
+// liveChat は配信のライブチャットと、そのチャットが属する配信の情報です。
+type liveChat struct {
+	chatID  string
+	videoID string
+	info    StreamInfo
+}
+
+// currentLiveChat は現在のライブチャットを返します。呼び出し元は c.mu を保持している必要があります。
+func (c *Client) currentLiveChat() liveChat {
+	return liveChat{chatID: c.liveChatID, videoID: c.videoID, info: c.streamInfo}
+}
+
+// activeLiveChatID は現在のライブチャットIDを返します。未設定の場合は配信を検索して設定します。
+func (c *Client) activeLiveChatID(ctx context.Context) (string, error) {
+	chat, err := c.ensureLiveChatID(ctx)
+	if err != nil {
+		return "", err
+	}
+	return chat.chatID, nil
+}
+
+// ensureLiveChatID は現在のライブチャットを返します。liveChatID が未設定の場合は配信を検索して設定します。
+// 配信の検索 (API の呼び出し) の間は c.mu を保持せず、コメントの取得や投稿を待たせません。
+// そのため呼び出し元は c.mu を保持していてはいけません。
+// 複数の呼び出しが同時に検索した場合は、最初に設定された結果を使用します。
+func (c *Client) ensureLiveChatID(ctx context.Context) (liveChat, error) {
+	c.mu.Lock()
+	if c.liveChatID != "" {
+		chat := c.currentLiveChat()
+		c.mu.Unlock()
+		return chat, nil
+	}
+	c.mu.Unlock()
+
+	found, err := c.findLiveChatID(ctx)
+	if err != nil {
+		return liveChat{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.liveChatID != "" {
+		// 検索している間に他の呼び出しが設定した
+		return c.currentLiveChat(), nil
+	}
+	// 別の配信のチャットに切り替わった場合は、前の配信の状態を引き継がない
+	// (同じチャットへの再接続では、既に応答したコメントへの重複応答を防ぐため状態を保持する)
+	if c.streamChatID != "" && c.streamChatID != found.chatID {
+		c.resetStreamState()
+	}
+	c.liveChatID = found.chatID
+	c.streamChatID = found.chatID
+	c.videoID = found.videoID
+	c.streamInfo = found.info
+	return found, nil
+}
+
+// WaitForLiveChat は起動時に配信のライブチャットを検索し、配信が見つからない (ErrNoLiveBroadcast) 場合は
+// interval ごとに最大 window の間だけ再試行します。配信開始直後に YouTube のインデックスが追いついていない
+// 「早く起動しすぎた」場合を、通常のポーリングのエラー処理とは別に吸収します。
+// window が 0 の場合は再試行しません。その他のエラーは即座に返します。
+func (c *Client) WaitForLiveChat(ctx context.Context, window, interval time.Duration) error {
+	deadline := time.Now().Add(window)
+	for {
+		_, err := c.activeLiveChatID(ctx)
+		if err == nil || !errors.Is(err, ErrNoLiveBroadcast) {
+			return err
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return err
+		}
+
+		log.Printf("No live broadcast found yet; retrying in %v (until %s)...", interval, deadline.Format(time.TimeOnly))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// StreamInfo は現在の配信のタイトルと説明を返します。
+// まだライブチャットに接続していない場合は、配信を検索して接続します。
+func (c *Client) StreamInfo(ctx context.Context) (StreamInfo, error) {
+	chat, err := c.ensureLiveChatID(ctx)
+	if err != nil {
+		return StreamInfo{}, err
+	}
+	return chat.info, nil
+}
+
+// resetStreamState は配信ごとの状態 (重複排除・削除の記録) をクリアします。呼び出し元は c.mu を保持している必要があります。
+func (c *Client) resetStreamState() {
+	log.Printf("[YouTube Client] New live chat detected. Resetting per-stream state (%d deleted IDs).", len(c.deletedCommentIDs))
+	c.deletedCommentIDs = make(map[string]time.Time)
+	c.viewers = ViewerStats{}
+}
+
+// SetFetchBatchSize は 1 回のポーリングで取得するメッセージの最大数を設定します。
+// API が受け付ける範囲 (MinFetchBatchSize〜MaxFetchBatchSize) 外の値はエラーになります。
+func (c *Client) SetFetchBatchSize(n int) error {
+	if n < MinFetchBatchSize || n > MaxFetchBatchSize {
+		return fmt.Errorf("fetch batch size must be between %d and %d, got %d", MinFetchBatchSize, MaxFetchBatchSize, n)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fetchBatchSize = int64(n)
+	return nil
+}
+
+// SetStateStore は取得済みのコメントIDの記録先 (重複排除) を設定します。既定はメモリ上のストアです。
+func (c *Client) SetStateStore(store state.Store) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seen = store
+}
+
+// SetPreserveRaw は取得したコメントに API のメッセージ (Comment.Raw) を保持するかどうかを設定します。
+// 既定では保持しません (メモリ使用量を抑えるため)。
+func (c *Client) SetPreserveRaw(preserve bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.preserveRaw = preserve
+}
+
+// findLiveChatID はチャンネルの現在のライブブロードキャストを見つけ、そのライブチャットを返します。
+// クライアントの状態は変更しません (設定は ensureLiveChatID が行います)。
+func (c *Client) findLiveChatID(ctx context.Context) (liveChat, error) {
 	// 1. Search.List を呼び出し、"live" のブロードキャストを探す
 	call := c.service.Search.List([]string{"id"}).
 		ChannelId(c.channelID).
@@ -86,157 +336,398 @@ func (c *Client) findLiveChatID(ctx context.Context) (string, error) {
 
 	response, err := call.Context(ctx).Do()
 	if err != nil {
-		return "", fmt.Errorf("failed to search live broadcast: %w", err)
+		return liveChat{}, fmt.Errorf("failed to search live broadcast: %w", err)
 	}
 
 	if len(response.Items) == 0 {
-		return "", fmt.Errorf("no active live broadcast found for channel ID: %s", c.channelID)
+		return liveChat{}, fmt.Errorf("%w for channel ID: %s", ErrNoLiveBroadcast, c.channelID)
 	}
 
 	videoID := response.Items[0].Id.VideoId
 
 	// 2. Videos.List を呼び出し、ライブチャット ID を取得
-	videosCall := c.service.Videos.List([]string{"liveStreamingDetails"}).
+	videosCall := c.service.Videos.List([]string{"liveStreamingDetails", "snippet"}).
 		Id(videoID)
 
 	videosResp, err := videosCall.Context(ctx).Do()
 	if err != nil {
-		return "", fmt.Errorf("failed to get video details: %w", err)
+		return liveChat{}, fmt.Errorf("failed to get video details: %w", err)
+	}
+
+	if len(videosResp.Items) == 0 || videosResp.Items[0].LiveStreamingDetails == nil {
+		return liveChat{}, fmt.Errorf("live streaming details not available for video ID: %s", videoID)
 	}
 
-	if len(videosResp.Items) == 0 || videosResp.Items[0].LiveStreamingDetails == nil || videosResp.Items[0].LiveStreamingDetails.ActiveLiveChatId == "" {
-		return "", fmt.Errorf("live streaming details or active chat ID not available for video ID: %s", videoID)
+	// 配信中にもかかわらずアクティブなチャット ID がない場合は、チャットが無効化されている
+	if videosResp.Items[0].LiveStreamingDetails.ActiveLiveChatId == "" {
+		return liveChat{}, fmt.Errorf("no active chat ID for video ID %s: %w", videoID, ErrLiveChatDisabled)
 	}
 
 	liveChatID := videosResp.Items[0].LiveStreamingDetails.ActiveLiveChatId
 
+	// 配信のタイトルと説明を保持 (プロンプトの文脈として使用可能)
+	chat := liveChat{chatID: liveChatID, videoID: videoID}
+	if snippet := videosResp.Items[0].Snippet; snippet != nil {
+		chat.info = StreamInfo{Title: snippet.Title, Description: snippet.Description}
+	}
+
 	log.Printf("Found Active Live Chat ID: %s", liveChatID)
-	return liveChatID, nil
+	return chat, nil
 }
 
 // FetchLiveChatMessages は新しいライブチャットメッセージを取得します。
+// 返すコメントは投稿時刻 (Timestamp) の古い順です。同じ時刻のコメントは API の順序を保ち、
+// 投稿時刻を解析できなかったコメントは最後に並べます。
 // 💡 修正: シグネチャを types.LowLatencyResponse に合わせ、ポーリング間隔を戻り値に含めます。
 func (c *Client) FetchLiveChatMessages(ctx context.Context) ([]Comment, time.Duration, error) {
 	// 1. 初回呼び出し時に liveChatID を検索し設定
-	if c.liveChatID == "" {
-		id, err := c.findLiveChatID(ctx)
-		if err != nil {
-			return nil, 0, err
-		}
-		c.liveChatID = id
+	// API の呼び出し中に投稿などを待たせないよう、ロックは状態の読み書きの間だけ保持する
+	chat, err := c.ensureLiveChatID(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	liveChatID := chat.chatID
+	c.mu.Lock()
+	var pageToken string
+	if c.liveChatID == liveChatID {
+		// 検索の後に別のチャットに切り替わった場合、ページトークンはそのチャットのものであるため使用しない
+		pageToken = c.nextPageToken
 	}
+	batchSize, seen := c.fetchBatchSize, c.seen
+	c.mu.Unlock()
 
 	// 2. LiveChatMessages.List を呼び出し
-	call := c.service.LiveChatMessages.List(c.liveChatID, []string{"snippet", "authorDetails"})
+	call := c.service.LiveChatMessages.List(liveChatID, []string{"snippet", "authorDetails"}).
+		MaxResults(batchSize)
 
-	if c.nextPageToken != "" {
-		call = call.PageToken(c.nextPageToken)
+	if pageToken != "" {
+		call = call.PageToken(pageToken)
 	}
 
 	response, err := call.Context(ctx).Do()
+
+	// 重複チェック (判定と同時に記録するため、共有ストアでは 1 つのインスタンスだけが処理する)。
+	// ストアへの問い合わせはバッチ全体で 1 回にまとめ、投稿などを待たせないようロックの外で行う
+	var isNew []bool
+	var storeErr error
+	if err == nil {
+		ids := make([]string, len(response.Items))
+		for i, item := range response.Items {
+			ids[i] = item.Id
+		}
+		isNew, storeErr = seen.MarkSeenBatch(ctx, ids, commentIDRetentionDuration)
+	}
+
+	// 再開位置のファイルへの保存は、c.mu の解放後 (下の defer の後) に実行する
+	var saveResume bool
+	defer func() {
+		if saveResume {
+			c.writeResumeState()
+		}
+	}()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if err != nil {
 		// YouTube API が返すエラーメッセージをチェック
 		// "liveChatEnded" または類似のエラーメッセージが含まれるかチェック
-		if strings.Contains(err.Error(), "liveChatEnded") || strings.Contains(err.Error(), "live chat is inactive") {
+		reason := apiErrorReason(err)
+		if reason == "liveChatEnded" || strings.Contains(err.Error(), "liveChatEnded") || strings.Contains(err.Error(), "live chat is inactive") {
 			// ライブチャット終了エラーの場合
 			log.Printf("YouTube API Error: Live chat ended. Error: %v", err)
 			c.liveChatID = "" // 💡 修正: liveChatID をリセット
 			c.nextPageToken = ""
 			return nil, 0, ErrLiveChatEnded // 💡 修正: カスタムエラーと 0s を返す
 		}
+		if sentinel := chatUnavailableError(reason); sentinel != nil {
+			// チャットが無効化・制限されている場合は、後で有効化される可能性に備えて状態をリセット
+			log.Printf("YouTube API Error: %v. Error: %v", sentinel, err)
+			c.liveChatID = ""
+			c.nextPageToken = ""
+			return nil, 0, sentinel
+		}
 		// その他のエラー
 		return nil, 0, fmt.Errorf("failed to fetch live chat messages: %w", err)
 	}
 
 	// 3. 次のポーリングのためのトークンと間隔を更新
-	c.nextPageToken = response.NextPageToken
+	if c.liveChatID == liveChatID {
+		c.nextPageToken = response.NextPageToken
+	}
 	pollingInterval := time.Duration(response.PollingIntervalMillis) * time.Millisecond // 💡 修正: pollingInterval をここで定義
 
 	// 4. メッセージを処理し、重複をフィルタリング
 	var newComments []Comment
 	currentTime := time.Now()
+	var latestPublishedAt time.Time
+	resumedCount := 0
 
-	for _, item := range response.Items {
+	for i, item := range response.Items {
 		// YouTube Data APIの仕様: LiveChatMessage IDは item.Id
 		commentID := item.Id
 
-		// 4.1. 重複チェック
-		if _, exists := c.lastFetchedCommentIDs[commentID]; exists {
-			continue // 既に処理済みのためスキップ
+		// 4.1. 重複チェックの結果、既に処理済みのコメントはスキップ
+		// (ストアに到達できない場合は、コメントを取りこぼさないようバッチ全体を新しいコメントとして扱う)
+		if storeErr == nil && !isNew[i] {
+			continue
+		}
+
+		// 4.2. 前回の実行で取得済みのメッセージは、再起動後に改めて応答しないよう既読として扱う
+		publishedAt := parseYouTubeTimestamp(item.Snippet.PublishedAt)
+		if publishedAt.After(latestPublishedAt) {
+			latestPublishedAt = publishedAt
+		}
+		if c.alreadySeen(publishedAt) {
+			resumedCount++
+			continue
+		}
+
+		// 4.3. 削除イベントは削除されたコメントIDを記録するのみ (応答対象にはしない)
+		if item.Snippet.Type == messageDeletedEvent {
+			if details := item.Snippet.MessageDeletedDetails; details != nil && details.DeletedMessageId != "" {
+				c.deletedCommentIDs[details.DeletedMessageId] = currentTime
+			}
+			continue
+		}
+
+		// 4.4. メンバーシップ関連イベントはイベント内容を説明する合成メッセージに変換
+		message := item.Snippet.DisplayMessage
+		event := EventNone
+		switch item.Snippet.Type {
+		case EventNewMember, EventMemberMilestone:
+			event = item.Snippet.Type
+			message = describeMembershipEvent(item)
+		}
+		// メッセージのない Super Chat も応答の対象にする
+		if message == "" && item.Snippet.SuperChatDetails != nil {
+			message = "(Super Chat with no message)"
 		}
 
-		// 4.2. 必須フィールドのチェック (AI応答に必要なメッセージ本文)
-		if item.Snippet.DisplayMessage == "" {
+		// 4.5. 必須フィールドのチェック (AI応答に必要なメッセージ本文)
+		if message == "" {
 			continue
 		}
 
-		// 4.3. コメントの構造体を作成
+		// 4.6. コメントの構造体を作成
 		newComment := Comment{
 			ID:       commentID,
 			AuthorID: item.AuthorDetails.ChannelId,
 			Author:   item.AuthorDetails.DisplayName,
-			Message:  item.Snippet.DisplayMessage, // 💡 修正: TextではなくMessageを使用
+			Message:  message, // 💡 修正: TextではなくMessageを使用
 			// YouTubeのタイムスタンプはRFC3339形式
-			Timestamp: parseYouTubeTimestamp(item.Snippet.PublishedAt),
+			Timestamp:   publishedAt,
+			Event:       event,
+			IsOwner:     item.AuthorDetails.IsChatOwner,
+			IsModerator: item.AuthorDetails.IsChatModerator,
+			IsMember:    item.AuthorDetails.IsChatSponsor,
+		}
+		if details := item.Snippet.SuperChatDetails; details != nil {
+			newComment.SuperChatAmountMicros = details.AmountMicros
+			newComment.SuperChatAmount = details.AmountDisplayString
+		}
+		if c.preserveRaw {
+			newComment.Raw = item
 		}
 
 		newComments = append(newComments, newComment)
+	}
+
+	if storeErr != nil {
+		log.Printf("Warning: State store error during dedup; treating this batch as new: %v", storeErr)
+	}
 
-		// 4.4. 💡 新しいコメントIDをマップに記録
-		c.lastFetchedCommentIDs[commentID] = currentTime
+	if resumedCount > 0 {
+		log.Printf("Skipped %d chat messages already seen before the restart.", resumedCount)
 	}
+	// ファイルへの保存は c.mu の解放後に行う (先頭で登録した defer)
+	saveResume = c.updateResumeState(latestPublishedAt)
 
 	// 5. 💡 ガベージコレクションを実行し、古いエントリを削除
 	c.cleanOldCommentIDs(currentTime)
 
+	// 6. API の順序はページの境界をまたぐと時系列になるとは限らないため、投稿時刻順に並べ替える
+	sortByTimestamp(newComments)
+
 	return newComments, pollingInterval, nil // 💡 修正: 正しい戻り値の数で返す
 }
 
-// cleanOldCommentIDs は保持期間を過ぎたコメントIDをマップから削除します。
-func (c *Client) cleanOldCommentIDs(currentTime time.Time) {
-	// ログの頻度を抑えるためのカウンター
-	deletedCount := 0
+// sortByTimestamp はコメントを投稿時刻の古い順に安定ソートします。投稿時刻がゼロ値 (解析の失敗) のコメントは最後に並べます。
+func sortByTimestamp(comments []Comment) {
+	slices.SortStableFunc(comments, func(a, b Comment) int {
+		switch {
+		case a.Timestamp.IsZero() && b.Timestamp.IsZero():
+			return 0
+		case a.Timestamp.IsZero():
+			return 1
+		case b.Timestamp.IsZero():
+			return -1
+		}
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+}
 
+// SelfChannelID は認証済みアカウント (ボット自身) のチャンネルIDを返します。
+// 初回呼び出し時のみ Channels.List API を呼び出し、以降はキャッシュを返します。
+func (c *Client) SelfChannelID(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	cached := c.selfChannelID
+	c.mu.Unlock()
+	if cached != "" {
+		return cached, nil
+	}
+
+	response, err := c.service.Channels.List([]string{"id"}).Mine(true).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to get authenticated channel: %w", err)
+	}
+	if len(response.Items) == 0 {
+		return "", fmt.Errorf("no channel found for the authenticated account")
+	}
+
+	id := response.Items[0].Id
+	c.mu.Lock()
+	c.selfChannelID = id
+	c.mu.Unlock()
+	log.Printf("Authenticated as channel ID: %s", id)
+	return id, nil
+}
+
+// IsCommentDeleted は指定したコメントIDについて、これまでに取得したバッチで削除イベントが
+// 通知されているかどうかを返します。
+func (c *Client) IsCommentDeleted(commentID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, deleted := c.deletedCommentIDs[commentID]
+	return deleted
+}
+
+// describeMembershipEvent はメンバーシップ関連イベントを説明する英文を生成します。
+func describeMembershipEvent(item *youtube.LiveChatMessage) string {
+	author := item.AuthorDetails.DisplayName
+
+	switch item.Snippet.Type {
+	case EventNewMember:
+		text := fmt.Sprintf("%s just became a member", author)
+		if details := item.Snippet.NewSponsorDetails; details != nil && details.MemberLevelName != "" {
+			text += fmt.Sprintf(" (%s)", details.MemberLevelName)
+		}
+		return text
+	case EventMemberMilestone:
+		details := item.Snippet.MemberMilestoneChatDetails
+		if details == nil {
+			return fmt.Sprintf("%s reached a membership milestone", author)
+		}
+		text := fmt.Sprintf("%s has been a member for %d months", author, details.MemberMonth)
+		if details.UserComment != "" {
+			text += fmt.Sprintf(" and says: %s", details.UserComment)
+		}
+		return text
+	}
+	return ""
+}
+
+// cleanOldCommentIDs は保持期間を過ぎた削除済みコメントIDをマップから削除します。呼び出し元は c.mu を保持している必要があります。
+// 取得済みのコメントIDは、記録先のストア (c.seen) が有効期限に従って削除します。
+func (c *Client) cleanOldCommentIDs(currentTime time.Time) {
 	// 現在時刻から保持期間を引いたしきい値
 	threshold := currentTime.Add(-commentIDRetentionDuration)
 
-	for id, t := range c.lastFetchedCommentIDs {
+	for id, t := range c.deletedCommentIDs {
 		if t.Before(threshold) {
-			delete(c.lastFetchedCommentIDs, id)
-			deletedCount++
+			delete(c.deletedCommentIDs, id)
 		}
 	}
+}
 
-	if deletedCount > 0 {
-		log.Printf("[YouTube Client] Cleaned %d old comment IDs. Total tracked: %d", deletedCount, len(c.lastFetchedCommentIDs))
-	}
+// HasActiveChat は投稿先のライブチャットが有効かどうかを返します。
+// ライブチャットの終了・無効化を検出した後は、新しいライブチャットが見つかるまで false を返します。
+func (c *Client) HasActiveChat() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.liveChatID != ""
+}
+
+// LiveChatID は現在接続しているライブチャットのIDを返します。接続していない場合は空文字列を返します。
+func (c *Client) LiveChatID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.liveChatID
 }
 
 // PostComment は指定されたテキストをライブチャットに投稿します。
 // ... (このメソッドは変更なしと仮定) ...
 
+// apiErrorReason は YouTube API の構造化エラーから最初のエラー理由 (reason) を取り出します。
+// googleapi.Error でない場合は空文字列を返します。
+func apiErrorReason(err error) string {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+	for _, item := range apiErr.Errors {
+		if item.Reason != "" {
+			return item.Reason
+		}
+	}
+	return ""
+}
+
+// chatUnavailableError はチャットの無効化・参加制限を示す API エラーの理由 (reason) を
+// 対応するカスタムエラーに変換します。該当しない場合は nil を返します。
+// クォータ超過 (quotaExceeded) やレート制限 (rateLimitExceeded) も 403 で返されるため、
+// ステータスコードではなく既知の理由のみで判定します。
+func chatUnavailableError(reason string) error {
+	switch reason {
+	case "liveChatDisabled":
+		return ErrLiveChatDisabled
+	case "liveChatNotFound":
+		// 配信中にもかかわらずチャットが見つからない場合は無効化と同様に扱う
+		return ErrLiveChatDisabled
+	case "forbidden", "insufficientPermissions":
+		return ErrLiveChatRestricted
+	}
+	return nil
+}
+
+// timestampLayouts は parseYouTubeTimestamp が順に試すタイムスタンプの形式です。
+// 通常は RFC3339 (小数秒付きを含む) ですが、オフセットのコロン省略や区切りの空白、
+// タイムゾーン省略 (UTC とみなす) などの軽微な揺れも受け付けます。
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+}
+
 // parseYouTubeTimestamp は YouTube API のタイムスタンプ文字列を time.Time に変換します。
 // これは YouTube の慣習的なユーティリティ関数であり、パッケージ内で定義されている必要があります。
+// どの形式でも解析できない場合は、コメントが失われないよう (ゼロ値で古いコメントと誤判定されないよう) 現在時刻を返します。
 func parseYouTubeTimestamp(t string) time.Time {
-	parsedTime, err := time.Parse(time.RFC3339, t)
-	if err != nil {
-		log.Printf("Error parsing timestamp '%s': %v", t, err)
-		return time.Time{} // パース失敗時はゼロ値を返す
+	t = strings.TrimSpace(t)
+	for _, layout := range timestampLayouts {
+		if parsedTime, err := time.Parse(layout, t); err == nil {
+			return parsedTime
+		}
 	}
-	return parsedTime
+	log.Printf("Error parsing timestamp '%s'. Using the current time instead.", t)
+	return time.Now()
 }
 
 // PostComment は指定されたテキストをライブチャットに投稿します。
 func (c *Client) PostComment(ctx context.Context, text string) error {
 	// 1. liveChatID が設定されていることを確認
-	if c.liveChatID == "" {
+	c.mu.Lock()
+	liveChatID := c.liveChatID
+	c.mu.Unlock()
+	if liveChatID == "" {
 		return fmt.Errorf("live chat ID is not set. Cannot post comment")
 	}
 
 	// 2. 投稿する LiveChatMessage オブジェクトを作成
 	message := &youtube.LiveChatMessage{
 		Snippet: &youtube.LiveChatMessageSnippet{
-			LiveChatId: c.liveChatID,
+			LiveChatId: liveChatID,
 			Type:       "textMessageEvent",
 			TextMessageDetails: &youtube.LiveChatTextMessageDetails{
 				MessageText: text,
@@ -247,9 +738,55 @@ func (c *Client) PostComment(ctx context.Context, text string) error {
 	// 3. LiveChatMessages.Insert を呼び出し
 	_, err := c.service.LiveChatMessages.Insert([]string{"snippet"}, message).Context(ctx).Do()
 	if err != nil {
+		// チャットが制限された (配信途中で登録者限定になったなど) 場合は、呼び出し元が判別できるよう番兵エラーを含める
+		if sentinel := chatUnavailableError(apiErrorReason(err)); sentinel != nil {
+			return fmt.Errorf("failed to post comment to live chat: %w: %w", sentinel, err)
+		}
 		return fmt.Errorf("failed to post comment to live chat: %w", err)
 	}
 
 	log.Printf("YouTube Comment Posted successfully: %s", text)
 	return nil
 }
+
+// writeTestMessage は VerifyWriteAccess で投稿するテストメッセージです。
+const writeTestMessage = "🔧 Connection test (this message will be deleted)"
+
+// VerifyWriteAccess はテストメッセージを投稿し、直後に削除することで、ライブチャットへの書き込み経路
+// (投稿に必要なスコープとチャットへの参加権限) を配信前に検証します。
+// 投稿または削除に失敗した場合は、API が返した理由を含むエラーを返します。
+func (c *Client) VerifyWriteAccess(ctx context.Context) error {
+	liveChatID, err := c.activeLiveChatID(ctx)
+	if err != nil {
+		return fmt.Errorf("could not find the live chat to test: %w", err)
+	}
+
+	message := &youtube.LiveChatMessage{
+		Snippet: &youtube.LiveChatMessageSnippet{
+			LiveChatId: liveChatID,
+			Type:       "textMessageEvent",
+			TextMessageDetails: &youtube.LiveChatTextMessageDetails{
+				MessageText: writeTestMessage,
+			},
+		},
+	}
+	posted, err := c.service.LiveChatMessages.Insert([]string{"snippet"}, message).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("write test failed: could not post a test message (reason: %s): %w", describeReason(err), err)
+	}
+
+	if err := c.service.LiveChatMessages.Delete(posted.Id).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("write test failed: posted a test message but could not delete it (reason: %s); remove message %s manually: %w", describeReason(err), posted.Id, err)
+	}
+
+	log.Println("Write test succeeded: posted and deleted a test message.")
+	return nil
+}
+
+// describeReason はエラーの理由 (reason) をログ・エラーメッセージ用に返します。理由が不明な場合は "unknown" です。
+func describeReason(err error) string {
+	if reason := apiErrorReason(err); reason != "" {
+		return reason
+	}
+	return "unknown"
+}
@@ -0,0 +1,627 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fixtureResponse は fixtureTransport が返す 1 件の応答です (testdata 以下のファイルとステータスコード)。
+type fixtureResponse struct {
+	status int
+	file   string
+}
+
+// fixtureTransport は YouTube Data API へのリクエストを testdata 以下の記録済みの JSON で応答する RoundTripper です。
+// ルートは "GET search" のようにメソッドと /youtube/v3/ 以下のパスで指定し、応答は登録順に 1 件ずつ返します
+// (最後の応答は以降のリクエストでも繰り返し返します)。
+type fixtureTransport struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	routes   map[string][]fixtureResponse
+	requests []*url.URL
+	calls    map[string]int
+}
+
+func newFixtureTransport(t *testing.T) *fixtureTransport {
+	t.Helper()
+	return &fixtureTransport{t: t, routes: make(map[string][]fixtureResponse), calls: make(map[string]int)}
+}
+
+// on は route (例: "GET liveChat/messages") に応答を追加します。
+func (f *fixtureTransport) on(route string, status int, file string) *fixtureTransport {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.routes[route] = append(f.routes[route], fixtureResponse{status: status, file: file})
+	return f
+}
+
+// callCount は route へのリクエスト数を返します。
+func (f *fixtureTransport) callCount(route string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[route]
+}
+
+// lastQuery は route への直近のリクエストのクエリパラメーターを返します。
+func (f *fixtureTransport) lastQuery(route string) url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.requests) - 1; i >= 0; i-- {
+		if f.routeOf("GET", f.requests[i]) == route {
+			return f.requests[i].Query()
+		}
+	}
+	return nil
+}
+
+func (f *fixtureTransport) routeOf(method string, u *url.URL) string {
+	return method + " " + strings.TrimPrefix(u.Path, "/youtube/v3/")
+}
+
+func (f *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+	route := f.routeOf(req.Method, req.URL)
+
+	f.mu.Lock()
+	f.requests = append(f.requests, req.URL)
+	n := f.calls[route]
+	f.calls[route]++
+	responses := f.routes[route]
+	f.mu.Unlock()
+
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("fixtureTransport: no fixture for %s", route)
+	}
+	resp := responses[min(n, len(responses)-1)]
+	body, err := os.ReadFile(filepath.Join("testdata", resp.file))
+	if err != nil {
+		return nil, fmt.Errorf("fixtureTransport: %w", err)
+	}
+	return &http.Response{
+		StatusCode: resp.status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(string(body))),
+		Request:    req,
+	}, nil
+}
+
+// newFixtureClient は fixtureTransport に接続した Client を作成します。
+func newFixtureClient(t *testing.T, transport *fixtureTransport) *Client {
+	t.Helper()
+	c, err := NewClientWithHTTPClient(context.Background(), "channel-1", &http.Client{Transport: transport})
+	if err != nil {
+		t.Fatalf("NewClientWithHTTPClient: %v", err)
+	}
+	return c
+}
+
+// commentIDs はコメントのIDを順に返します。
+func commentIDs(comments []Comment) []string {
+	ids := make([]string, 0, len(comments))
+	for _, c := range comments {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func TestFindLiveChatID(t *testing.T) {
+	tests := []struct {
+		name       string
+		search     string
+		videos     string
+		wantChatID string
+		wantErr    error
+	}{
+		{name: "live broadcast", search: "search_live.json", videos: "videos_live.json", wantChatID: "chat-1"},
+		{name: "no live broadcast", search: "search_empty.json", videos: "videos_live.json", wantErr: ErrNoLiveBroadcast},
+		{name: "chat disabled", search: "search_live.json", videos: "videos_chat_disabled.json", wantErr: ErrLiveChatDisabled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := newFixtureTransport(t).
+				on("GET search", http.StatusOK, tt.search).
+				on("GET videos", http.StatusOK, tt.videos)
+			c := newFixtureClient(t, transport)
+
+			got, err := c.findLiveChatID(context.Background())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("findLiveChatID() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findLiveChatID() error = %v", err)
+			}
+			if got.chatID != tt.wantChatID {
+				t.Errorf("findLiveChatID() chat = %q, want %q", got.chatID, tt.wantChatID)
+			}
+			if got.info.Title != "Friday night stream" || got.videoID != "video-1" {
+				t.Errorf("stream info = %+v (video %q), want the fixture's title and video-1", got.info, got.videoID)
+			}
+			if q := transport.lastQuery("GET search"); q.Get("channelId") != "channel-1" || q.Get("eventType") != "live" {
+				t.Errorf("search query = %v, want channelId=channel-1 and eventType=live", q)
+			}
+		})
+	}
+}
+
+func TestFetchLiveChatMessagesPagination(t *testing.T) {
+	transport := newFixtureTransport(t).
+		on("GET search", http.StatusOK, "search_live.json").
+		on("GET videos", http.StatusOK, "videos_live.json").
+		on("GET liveChat/messages", http.StatusOK, "messages_page1.json").
+		on("GET liveChat/messages", http.StatusOK, "messages_page2.json")
+	c := newFixtureClient(t, transport)
+	ctx := context.Background()
+
+	comments, interval, err := c.FetchLiveChatMessages(ctx)
+	if err != nil {
+		t.Fatalf("first fetch: %v", err)
+	}
+	if q := transport.lastQuery("GET liveChat/messages"); q.Get("pageToken") != "" || q.Get("liveChatId") != "chat-1" {
+		t.Errorf("first request query = %v, want liveChatId=chat-1 and no pageToken", q)
+	}
+	if interval != 5*time.Second {
+		t.Errorf("first polling interval = %v, want 5s", interval)
+	}
+	if got := commentIDs(comments); strings.Join(got, ",") != "msg-1,msg-2" {
+		t.Errorf("first fetch = %v, want [msg-1 msg-2]", got)
+	}
+	if !comments[1].IsModerator || comments[1].Author != "Bob" {
+		t.Errorf("second comment = %+v, want moderator Bob", comments[1])
+	}
+
+	if _, interval, err = c.FetchLiveChatMessages(ctx); err != nil {
+		t.Fatalf("second fetch: %v", err)
+	}
+	if q := transport.lastQuery("GET liveChat/messages"); q.Get("pageToken") != "page-2" {
+		t.Errorf("second request pageToken = %q, want page-2", q.Get("pageToken"))
+	}
+	if interval != 3*time.Second {
+		t.Errorf("second polling interval = %v, want 3s", interval)
+	}
+	if got := transport.callCount("GET search"); got != 1 {
+		t.Errorf("search calls = %d, want 1 (the chat ID is cached)", got)
+	}
+}
+
+func TestFetchLiveChatMessagesDedup(t *testing.T) {
+	transport := newFixtureTransport(t).
+		on("GET search", http.StatusOK, "search_live.json").
+		on("GET videos", http.StatusOK, "videos_live.json").
+		on("GET liveChat/messages", http.StatusOK, "messages_page1.json").
+		on("GET liveChat/messages", http.StatusOK, "messages_page2.json")
+	c := newFixtureClient(t, transport)
+	ctx := context.Background()
+
+	if _, _, err := c.FetchLiveChatMessages(ctx); err != nil {
+		t.Fatalf("first fetch: %v", err)
+	}
+	comments, _, err := c.FetchLiveChatMessages(ctx)
+	if err != nil {
+		t.Fatalf("second fetch: %v", err)
+	}
+	// msg-2 は 1 回目で取得済みのため、2 回目のページに含まれていても返さない
+	if got := commentIDs(comments); strings.Join(got, ",") != "msg-3" {
+		t.Errorf("second fetch = %v, want [msg-3]", got)
+	}
+}
+
+func TestFetchLiveChatMessagesEnded(t *testing.T) {
+	transport := newFixtureTransport(t).
+		on("GET search", http.StatusOK, "search_live.json").
+		on("GET videos", http.StatusOK, "videos_live.json").
+		on("GET liveChat/messages", http.StatusOK, "messages_page1.json").
+		on("GET liveChat/messages", http.StatusForbidden, "messages_ended.json")
+	c := newFixtureClient(t, transport)
+	ctx := context.Background()
+
+	if _, _, err := c.FetchLiveChatMessages(ctx); err != nil {
+		t.Fatalf("first fetch: %v", err)
+	}
+	if !c.HasActiveChat() {
+		t.Fatal("HasActiveChat() = false after a successful fetch")
+	}
+
+	_, interval, err := c.FetchLiveChatMessages(ctx)
+	if !errors.Is(err, ErrLiveChatEnded) {
+		t.Fatalf("fetch after the chat ended: error = %v, want ErrLiveChatEnded", err)
+	}
+	if interval != 0 {
+		t.Errorf("polling interval = %v, want 0", interval)
+	}
+	if c.HasActiveChat() {
+		t.Error("HasActiveChat() = true after the chat ended")
+	}
+
+	// 次の取得では配信を検索し直す
+	c.FetchLiveChatMessages(ctx)
+	if got := transport.callCount("GET search"); got != 2 {
+		t.Errorf("search calls = %d, want 2 (the chat is looked up again after it ends)", got)
+	}
+}
+
+// TestClientConcurrentAccess はコメントの取得と投稿などを複数のゴルーチンから同時に呼び出します。
+// go test -race で実行し、可変な状態へのアクセスが c.mu で保護されていることを確認します。
+func TestClientConcurrentAccess(t *testing.T) {
+	transport := newFixtureTransport(t).
+		on("GET search", http.StatusOK, "search_live.json").
+		on("GET videos", http.StatusOK, "videos_live.json").
+		on("GET liveChat/messages", http.StatusOK, "messages_page1.json").
+		on("GET liveChat/messages", http.StatusOK, "messages_page2.json").
+		on("POST liveChat/messages", http.StatusOK, "insert_ok.json")
+	c := newFixtureClient(t, transport)
+	ctx := context.Background()
+
+	const workers, iterations = 8, 20
+	var (
+		wg      sync.WaitGroup
+		countMu sync.Mutex
+		counts  = make(map[string]int)
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				comments, _, err := c.FetchLiveChatMessages(ctx)
+				if err != nil {
+					t.Errorf("FetchLiveChatMessages: %v", err)
+					return
+				}
+				countMu.Lock()
+				for _, comment := range comments {
+					counts[comment.ID]++
+				}
+				countMu.Unlock()
+
+				if err := c.PostComment(ctx, "hi"); err != nil {
+					t.Errorf("PostComment: %v", err)
+					return
+				}
+				if _, err := c.StreamInfo(ctx); err != nil {
+					t.Errorf("StreamInfo: %v", err)
+					return
+				}
+				c.IsCommentDeleted("msg-1")
+				c.HasActiveChat()
+				c.SetPreserveRaw(i%2 == 0)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 同時に取得しても、それぞれのコメントはちょうど 1 回だけ返される
+	for _, id := range []string{"msg-1", "msg-2", "msg-3"} {
+		if counts[id] != 1 {
+			t.Errorf("comment %s returned %d times, want 1 (all: %v)", id, counts[id], counts)
+		}
+	}
+	if got := transport.callCount("POST liveChat/messages"); got != workers*iterations {
+		t.Errorf("insert calls = %d, want %d", got, workers*iterations)
+	}
+}
+
+// blockingTransport は path へのリクエストを release が閉じられるまで止める RoundTripper です。
+type blockingTransport struct {
+	next    http.RoundTripper
+	path    string
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == b.path {
+		close(b.entered)
+		<-b.release
+	}
+	return b.next.RoundTrip(req)
+}
+
+func TestLiveChatLookupDoesNotHoldLock(t *testing.T) {
+	fixtures := newFixtureTransport(t).
+		on("GET search", http.StatusOK, "search_live.json").
+		on("GET videos", http.StatusOK, "videos_live.json")
+	blocking := &blockingTransport{next: fixtures, path: "/youtube/v3/search", entered: make(chan struct{}), release: make(chan struct{})}
+	c, err := NewClientWithHTTPClient(context.Background(), "channel-1", &http.Client{Transport: blocking})
+	if err != nil {
+		t.Fatalf("NewClientWithHTTPClient: %v", err)
+	}
+
+	lookup := make(chan error, 1)
+	go func() {
+		_, err := c.activeLiveChatID(context.Background())
+		lookup <- err
+	}()
+	<-blocking.entered
+
+	// 配信の検索中も、ロックを取る他の呼び出しは待たされない
+	done := make(chan struct{})
+	go func() {
+		c.HasActiveChat()
+		c.IsCommentDeleted("msg-1")
+		c.SetPreserveRaw(true)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("client calls blocked while the live chat lookup was in flight")
+	}
+
+	close(blocking.release)
+	if err := <-lookup; err != nil {
+		t.Fatalf("activeLiveChatID: %v", err)
+	}
+	if !c.HasActiveChat() {
+		t.Error("HasActiveChat() = false after the lookup finished")
+	}
+}
+
+func TestFetchLiveChatMessagesNewStreamAfterEnd(t *testing.T) {
+	transport := newFixtureTransport(t).
+		on("GET search", http.StatusOK, "search_live.json").
+		on("GET search", http.StatusOK, "search_live_next.json").
+		on("GET videos", http.StatusOK, "videos_live.json").
+		on("GET videos", http.StatusOK, "videos_live_next.json").
+		on("GET liveChat/messages", http.StatusOK, "messages_page1.json").
+		on("GET liveChat/messages", http.StatusOK, "messages_deleted.json").
+		on("GET liveChat/messages", http.StatusForbidden, "messages_ended.json").
+		on("GET liveChat/messages", http.StatusOK, "messages_next_stream.json")
+	c := newFixtureClient(t, transport)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := c.FetchLiveChatMessages(ctx); err != nil {
+			t.Fatalf("fetch %d: %v", i+1, err)
+		}
+	}
+	if !c.IsCommentDeleted("msg-1") {
+		t.Fatal("IsCommentDeleted(msg-1) = false after the deletion event")
+	}
+	if _, _, err := c.FetchLiveChatMessages(ctx); !errors.Is(err, ErrLiveChatEnded) {
+		t.Fatalf("fetch after the chat ended: error = %v, want ErrLiveChatEnded", err)
+	}
+	if got := c.LiveChatID(); got != "" {
+		t.Errorf("LiveChatID() after the chat ended = %q, want empty", got)
+	}
+
+	comments, _, err := c.FetchLiveChatMessages(ctx)
+	if err != nil {
+		t.Fatalf("fetch from the new stream: %v", err)
+	}
+	if got := c.LiveChatID(); got != "chat-2" {
+		t.Errorf("LiveChatID() = %q, want chat-2", got)
+	}
+	// 新しいチャットは最初のページから取得する (前のチャットのページトークンを使わない)
+	if q := transport.lastQuery("GET liveChat/messages"); q.Get("liveChatId") != "chat-2" || q.Get("pageToken") != "" {
+		t.Errorf("new stream request query = %v, want liveChatId=chat-2 and no pageToken", q)
+	}
+	if got := commentIDs(comments); strings.Join(got, ",") != "msg-10" {
+		t.Errorf("new stream comments = %v, want [msg-10]", got)
+	}
+	// 削除の記録と配信情報は前の配信から引き継がない
+	if c.IsCommentDeleted("msg-1") {
+		t.Error("IsCommentDeleted(msg-1) = true after switching to a new stream")
+	}
+	info, err := c.StreamInfo(ctx)
+	if err != nil || info.Title != "Saturday stream" {
+		t.Errorf("StreamInfo() = %+v, %v, want the new stream's title", info, err)
+	}
+}
+
+func TestParseYouTubeTimestamp(t *testing.T) {
+	want := time.Date(2025, 1, 1, 20, 1, 5, 0, time.UTC)
+	tests := []struct {
+		name  string
+		input string
+		want  time.Time
+	}{
+		{name: "RFC3339 with milliseconds", input: "2025-01-01T20:01:05.000Z", want: want},
+		{name: "RFC3339 with microseconds", input: "2025-01-01T20:01:05.123456Z", want: want.Add(123456 * time.Microsecond)},
+		{name: "RFC3339 with nanoseconds", input: "2025-01-01T20:01:05.123456789Z", want: want.Add(123456789 * time.Nanosecond)},
+		{name: "RFC3339 without fraction", input: "2025-01-01T20:01:05Z", want: want},
+		{name: "offset with colon", input: "2025-01-02T05:01:05+09:00", want: want},
+		{name: "offset without colon", input: "2025-01-02T05:01:05.000+0900", want: want},
+		{name: "negative offset", input: "2025-01-01T15:01:05-05:00", want: want},
+		{name: "space separator", input: "2025-01-01 20:01:05.000Z", want: want},
+		{name: "space separator without offset colon", input: "2025-01-01 20:01:05+0000", want: want},
+		{name: "no time zone means UTC", input: "2025-01-01T20:01:05.000", want: want},
+		{name: "space separator without time zone", input: "2025-01-01 20:01:05", want: want},
+		{name: "surrounding whitespace", input: "  2025-01-01T20:01:05Z\n", want: want},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseYouTubeTimestamp(tt.input)
+			if !got.Equal(tt.want) {
+				t.Errorf("parseYouTubeTimestamp(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYouTubeTimestampFallsBackToNow(t *testing.T) {
+	for _, input := range []string{"", "not a timestamp", "2025-13-45T99:99:99Z", "1735761665"} {
+		t.Run(input, func(t *testing.T) {
+			before := time.Now()
+			got := parseYouTubeTimestamp(input)
+			after := time.Now()
+			// ゼロ値ではなく現在時刻を返し、古いコメントとして扱われないようにする
+			if got.IsZero() || got.Before(before) || got.After(after) {
+				t.Errorf("parseYouTubeTimestamp(%q) = %v, want the current time (between %v and %v)", input, got, before, after)
+			}
+		})
+	}
+}
+
+func TestSortByTimestamp(t *testing.T) {
+	base := time.Date(2025, 1, 1, 20, 0, 0, 0, time.UTC)
+	at := func(id string, offset time.Duration) Comment {
+		return Comment{ID: id, Timestamp: base.Add(offset)}
+	}
+	zero := func(id string) Comment { return Comment{ID: id} }
+
+	tests := []struct {
+		name     string
+		comments []Comment
+		want     string
+	}{
+		{name: "empty", comments: nil, want: ""},
+		{name: "already sorted", comments: []Comment{at("a", 0), at("b", time.Second), at("c", 2*time.Second)}, want: "a,b,c"},
+		{name: "out of order", comments: []Comment{at("c", 2*time.Second), at("a", 0), at("b", time.Second)}, want: "a,b,c"},
+		{name: "equal timestamps keep API order", comments: []Comment{at("b", time.Second), at("x", 0), at("c", time.Second), at("a", time.Second)}, want: "x,b,c,a"},
+		{name: "zero timestamps last", comments: []Comment{zero("z1"), at("b", time.Second), zero("z2"), at("a", 0)}, want: "a,b,z1,z2"},
+		{name: "only zero timestamps keep API order", comments: []Comment{zero("z2"), zero("z1")}, want: "z2,z1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sortByTimestamp(tt.comments)
+			if got := strings.Join(commentIDs(tt.comments), ","); got != tt.want {
+				t.Errorf("sortByTimestamp() order = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchLiveChatMessagesSortsByTimestamp(t *testing.T) {
+	transport := newFixtureTransport(t).
+		on("GET search", http.StatusOK, "search_live.json").
+		on("GET videos", http.StatusOK, "videos_live.json").
+		on("GET liveChat/messages", http.StatusOK, "messages_out_of_order.json")
+	c := newFixtureClient(t, transport)
+
+	comments, _, err := c.FetchLiveChatMessages(context.Background())
+	if err != nil {
+		t.Fatalf("FetchLiveChatMessages: %v", err)
+	}
+	if got := strings.Join(commentIDs(comments), ","); got != "msg-early,msg-middle,msg-late" {
+		t.Errorf("fetch order = %s, want msg-early,msg-middle,msg-late", got)
+	}
+}
+
+// TestForbiddenErrorsByReason は 403 のエラーを理由 (reason) ごとに判別し、クォータ超過やレート制限を
+// チャットの無効化・参加制限と誤判定しないことを確認します。
+func TestForbiddenErrorsByReason(t *testing.T) {
+	tests := []struct {
+		name         string
+		file         string
+		wantSentinel error // nil の場合はどの番兵エラーも含まない
+		wantActive   bool  // エラーの後もライブチャットIDを保持するか
+	}{
+		{name: "quota exceeded", file: "error_quotaExceeded.json", wantActive: true},
+		{name: "rate limit exceeded", file: "error_rateLimitExceeded.json", wantActive: true},
+		{name: "daily limit exceeded", file: "error_dailyLimitExceeded.json", wantActive: true},
+		{name: "403 without a reason", file: "error_forbidden_no_reason.json", wantActive: true},
+		{name: "chat disabled", file: "error_liveChatDisabled.json", wantSentinel: ErrLiveChatDisabled},
+		{name: "chat restricted", file: "error_forbidden.json", wantSentinel: ErrLiveChatRestricted},
+	}
+	sentinels := []error{ErrLiveChatDisabled, ErrLiveChatRestricted, ErrLiveChatEnded}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := newFixtureTransport(t).
+				on("GET search", http.StatusOK, "search_live.json").
+				on("GET videos", http.StatusOK, "videos_live.json").
+				on("GET liveChat/messages", http.StatusOK, "messages_page1.json").
+				on("GET liveChat/messages", http.StatusForbidden, tt.file).
+				on("POST liveChat/messages", http.StatusForbidden, tt.file)
+			c := newFixtureClient(t, transport)
+			ctx := context.Background()
+			if _, _, err := c.FetchLiveChatMessages(ctx); err != nil {
+				t.Fatalf("first fetch: %v", err)
+			}
+
+			postErr := c.PostComment(ctx, "hello")
+			_, _, fetchErr := c.FetchLiveChatMessages(ctx)
+			for _, check := range []struct {
+				op  string
+				err error
+			}{{"PostComment", postErr}, {"FetchLiveChatMessages", fetchErr}} {
+				if check.err == nil {
+					t.Fatalf("%s: error = nil, want an error", check.op)
+				}
+				for _, sentinel := range sentinels {
+					if want := sentinel == tt.wantSentinel; errors.Is(check.err, sentinel) != want {
+						t.Errorf("%s: errors.Is(%v, %v) = %v, want %v", check.op, check.err, sentinel, !want, want)
+					}
+				}
+			}
+			if got := c.HasActiveChat(); got != tt.wantActive {
+				t.Errorf("HasActiveChat() after the error = %v, want %v", got, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestResumeStatePath(t *testing.T) {
+	tests := []struct {
+		name      string
+		channelID string
+		want      string
+	}{
+		{name: "channel id", channelID: "UC123-abc_DEF", want: "chat_state.UC123-abc_DEF.json"},
+		{name: "unsafe characters", channelID: "../UC 1", want: "chat_state.___UC_1.json"},
+		{name: "empty", channelID: "", want: "chat_state.default.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResumeStatePath("config", tt.channelID); got != filepath.Join("config", tt.want) {
+				t.Errorf("ResumeStatePath(%q) = %q, want %q", tt.channelID, got, filepath.Join("config", tt.want))
+			}
+		})
+	}
+	if ResumeStatePath("config", "UC-a") == ResumeStatePath("config", "UC-b") {
+		t.Error("different channels share a resume state file")
+	}
+}
+
+// TestResumeStateAcrossRestart は、取得後に再開位置がファイルに保存され、再起動後の Client が
+// 同じライブチャットの取得済みのメッセージを返さないことを確認します。
+func TestResumeStateAcrossRestart(t *testing.T) {
+	path := ResumeStatePath(t.TempDir(), "channel-1")
+	newClient := func() *Client {
+		transport := newFixtureTransport(t).
+			on("GET search", http.StatusOK, "search_live.json").
+			on("GET videos", http.StatusOK, "videos_live.json").
+			on("GET liveChat/messages", http.StatusOK, "messages_page1.json")
+		c := newFixtureClient(t, transport)
+		if err := c.EnableResumeState(path); err != nil {
+			t.Fatalf("EnableResumeState: %v", err)
+		}
+		return c
+	}
+	ctx := context.Background()
+
+	first, _, err := newClient().FetchLiveChatMessages(ctx)
+	if err != nil {
+		t.Fatalf("first run fetch: %v", err)
+	}
+	if len(first) != 2 {
+		t.Fatalf("first run fetched %d comments, want 2", len(first))
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("resume state was not saved: %v", err)
+	}
+
+	restarted, _, err := newClient().FetchLiveChatMessages(ctx)
+	if err != nil {
+		t.Fatalf("fetch after restart: %v", err)
+	}
+	if len(restarted) != 0 {
+		t.Errorf("fetch after restart = %v, want no comments (already seen before the restart)", commentIDs(restarted))
+	}
+}
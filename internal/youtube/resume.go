@@ -0,0 +1,103 @@
+package youtube
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"prompter-live-go/internal/util"
+)
+
+// resumeState は再起動後に同じライブチャットの既読位置から再開するために保存する状態です。
+type resumeState struct {
+	LiveChatID      string    `json:"live_chat_id"`
+	LastPublishedAt time.Time `json:"last_published_at"`
+}
+
+// EnableResumeState は最後に取得したメッセージの投稿時刻を path に保存し、再起動後に同じライブチャットに
+// 接続した場合は、その時刻までのメッセージを既読として扱うよう設定します。
+// 再起動直後の最初の取得で返される直近のメッセージ群に、まとめて応答してしまうのを防ぎます。
+// ファイルが存在しない場合は、新たに作成します。
+func (c *Client) EnableResumeState(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resumePath = path
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read resume state: %w", err)
+	}
+	if err := json.Unmarshal(b, &c.resume); err != nil {
+		// 壊れたファイルで起動できなくなるのを避けるため、警告のみとし次回の保存で上書きする
+		log.Printf("Warning: Ignoring unreadable resume state %s: %v", path, err)
+		c.resume = resumeState{}
+	}
+	return nil
+}
+
+// alreadySeen はメッセージが前回の実行で取得済み (保存した再開位置以前) かどうかを判定します。
+// 呼び出し元は c.mu を保持している必要があります。
+func (c *Client) alreadySeen(publishedAt time.Time) bool {
+	return c.resumePath != "" && c.resume.LiveChatID == c.liveChatID && !publishedAt.After(c.resume.LastPublishedAt)
+}
+
+// updateResumeState は取得したメッセージの最新の投稿時刻で再開位置を更新し、保存が必要かどうかを返します。
+// 呼び出し元は c.mu を保持している必要があります。ファイルへの保存は c.mu の解放後に writeResumeState で行います。
+func (c *Client) updateResumeState(latest time.Time) bool {
+	if c.resumePath == "" || latest.IsZero() {
+		return false
+	}
+	if c.resume.LiveChatID == c.liveChatID && !latest.After(c.resume.LastPublishedAt) {
+		return false
+	}
+	c.resume = resumeState{LiveChatID: c.liveChatID, LastPublishedAt: latest}
+	return true
+}
+
+// writeResumeState は現在の再開位置をファイルに保存します。c.mu を保持せずに呼び出します。
+// 書き込み途中で終了してもファイルが壊れないよう、一時ファイルからのリネームで置き換えます。
+// 同時に呼び出された場合も古い再開位置で上書きしないよう、書き込みは resumeMu で直列化し、その中で最新の状態を読み取ります。
+func (c *Client) writeResumeState() {
+	c.resumeMu.Lock()
+	defer c.resumeMu.Unlock()
+
+	c.mu.Lock()
+	path, state := c.resumePath, c.resume
+	c.mu.Unlock()
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("Warning: Failed to encode resume state: %v", err)
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		log.Printf("Warning: Failed to save resume state: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("Warning: Failed to save resume state: %v", err)
+	}
+}
+
+// ResumeStatePath は dir 内のチャンネル channelID の再開位置のファイルのパスを返します。
+// 異なるチャンネルのボットが互いの再開位置を上書きしないよう、ファイル名にチャンネルIDを含めます。
+func ResumeStatePath(dir, channelID string) string {
+	return filepath.Join(dir, "chat_state."+util.FileNameKey(channelID)+".json")
+}
+
+// DefaultResumeStatePath は設定ディレクトリ内のチャンネル channelID の再開位置のファイルのパスを返します。
+func DefaultResumeStatePath(channelID string) (string, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return ResumeStatePath(configPath, channelID), nil
+}
@@ -0,0 +1,75 @@
+package youtube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// tokenInfoURL はアクセストークンに付与されたスコープを確認するエンドポイントです (クォータを消費しません)。
+const tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+// ErrMissingWriteScope は認証済みトークンにライブチャットへの投稿に必要なスコープがないことを示すカスタムエラー
+var ErrMissingWriteScope = errors.New("your token lacks YouTube write scope; re-run 'prompter_live auth' to grant it")
+
+// writeScopes はライブチャットへの投稿 (LiveChatMessages.Insert) を許可するスコープです。
+var writeScopes = []string{youtube.YoutubeForceSslScope, youtube.YoutubeScope}
+
+// CheckWriteScope は現在のアクセストークンに付与されたスコープを確認し、
+// 投稿に必要なスコープがない場合は ErrMissingWriteScope を返します。
+// 読み取り専用スコープで認証した場合や古いトークンの場合に、配信途中の分かりにくい権限エラーの代わりに起動時に失敗させます。
+// スコープを確認できなかった場合 (NewClientWithHTTPClient で作成した場合やネットワークエラー) は警告を出力して nil を返します。
+func (c *Client) CheckWriteScope(ctx context.Context) error {
+	if c.tokenSource == nil {
+		return nil
+	}
+
+	scopes, err := c.grantedScopes(ctx)
+	if err != nil {
+		log.Printf("Warning: Could not verify the OAuth token's scopes: %v", err)
+		return nil
+	}
+	for _, scope := range writeScopes {
+		if slices.Contains(scopes, scope) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w (granted scopes: %s)", ErrMissingWriteScope, strings.Join(scopes, ", "))
+}
+
+// grantedScopes は tokeninfo エンドポイントからアクセストークンに付与されたスコープを取得します。
+func (c *Client) grantedScopes(ctx context.Context) ([]string, error) {
+	token, err := c.tokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenInfoURL+"?access_token="+url.QueryEscape(token.AccessToken), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("tokeninfo request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tokeninfo returned status %s", resp.Status)
+	}
+	var info struct {
+		Scope string `json:"scope"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to decode tokeninfo response: %w", err)
+	}
+	return strings.Fields(info.Scope), nil
+}
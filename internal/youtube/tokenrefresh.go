@@ -0,0 +1,88 @@
+package youtube
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// tokenRefreshRetryInterval はリフレッシュに失敗した場合や有効期限が不明な場合に、再度確認するまでの待機時間です。
+const tokenRefreshRetryInterval = time.Minute
+
+// tokenRefreshMargin は有効期限のこの時間前にアクセストークンを先行してリフレッシュする猶予です。0 の場合は無効です。
+var tokenRefreshMargin time.Duration
+
+// SetTokenRefreshMargin はアクセストークンを有効期限の margin 前に先行してリフレッシュするよう設定します。
+// 通常のリフレッシュは次の API 呼び出し時に行われるため、コメントの少ない時間帯に期限切れのままになるのを防ぎます。
+// NewClient を呼び出す前に設定する必要があります。
+func SetTokenRefreshMargin(margin time.Duration) {
+	tokenRefreshMargin = margin
+}
+
+// newRefreshingTokenSource は token を起点に自動でリフレッシュし、リフレッシュされたトークンを保存する TokenSource を作成します。
+// 先行リフレッシュが有効な場合、有効期限の tokenRefreshMargin 前のトークンを期限切れとみなします。
+func newRefreshingTokenSource(ctx context.Context, config *oauth2.Config, token *oauth2.Token) oauth2.TokenSource {
+	src := config.TokenSource(ctx, token)
+	if tokenRefreshMargin > 0 {
+		src = oauth2.ReuseTokenSourceWithExpiry(token, src, tokenRefreshMargin)
+	}
+	return &savingTokenSource{src: src, lastAccessToken: token.AccessToken}
+}
+
+// savingTokenSource はアクセストークンが更新されるたびにトークンストアに保存する TokenSource です。
+type savingTokenSource struct {
+	src oauth2.TokenSource
+
+	mu              sync.Mutex
+	lastAccessToken string
+}
+
+// Token はトークンを返します。リフレッシュが行われた場合は新しいトークンを保存します。
+func (s *savingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if token.AccessToken != s.lastAccessToken {
+		s.lastAccessToken = token.AccessToken
+		log.Printf("OAuth access token refreshed (expires at %s).", token.Expiry.Format(time.RFC3339))
+		if err := saveToken(token); err != nil {
+			// 保存に失敗しても、メモリ上のトークンで処理は継続できる
+			log.Printf("Warning: Failed to save refreshed token: %v", err)
+		}
+	}
+	return token, nil
+}
+
+// RunTokenRefresher はアクセストークンの有効期限を監視し、期限の tokenRefreshMargin 前にリフレッシュします。
+// ctx がキャンセルされるまでブロックします。先行リフレッシュが無効な場合は何もせずに戻ります。
+func (c *Client) RunTokenRefresher(ctx context.Context) {
+	if c.tokenSource == nil || tokenRefreshMargin <= 0 {
+		return
+	}
+	log.Printf("Proactive token refresh enabled (%v before expiry).", tokenRefreshMargin)
+
+	for {
+		// 期限の tokenRefreshMargin 前を過ぎていれば、ここでリフレッシュされる
+		wait := tokenRefreshRetryInterval
+		token, err := c.tokenSource.Token()
+		if err != nil {
+			log.Printf("Warning: Proactive token refresh failed: %v. Retrying in %v.", err, wait)
+		} else if !token.Expiry.IsZero() {
+			// 有効期限が猶予より短いトークンでビジーループにならないよう、待機時間には下限を設ける
+			wait = max(time.Until(token.Expiry)-tokenRefreshMargin, tokenRefreshRetryInterval)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
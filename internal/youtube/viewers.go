@@ -0,0 +1,79 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// MinViewerTrackInterval は視聴者数を取得する間隔の下限です。
+// Videos.List は 1 回につきクォータを 1 ユニット消費するため、短すぎる間隔は受け付けません。
+const MinViewerTrackInterval = time.Minute
+
+// ViewerStats は配信の同時視聴者数と高評価数です。
+type ViewerStats struct {
+	ConcurrentViewers uint64
+	LikeCount         uint64
+	// UpdatedAt は取得した時刻です。一度も取得していない場合はゼロ値です。
+	UpdatedAt time.Time
+}
+
+// ViewerStats は直近に取得した視聴者数と高評価数を返します。まだ取得していない場合は false を返します。
+func (c *Client) ViewerStats() (ViewerStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.viewers, !c.viewers.UpdatedAt.IsZero()
+}
+
+// RunViewerTracker は interval ごとに配信の同時視聴者数と高評価数を取得し、ViewerStats で参照できるよう保持します。
+// ctx がキャンセルされるまでブロックします。取得ごとに Videos.List のクォータを 1 ユニット消費します。
+func (c *Client) RunViewerTracker(ctx context.Context, interval time.Duration) {
+	interval = max(interval, MinViewerTrackInterval)
+	log.Printf("Viewer tracking enabled. Fetching viewer counts every %v.", interval)
+
+	for {
+		if err := c.refreshViewerStats(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Warning: Failed to fetch viewer counts: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// refreshViewerStats は現在の配信の視聴者数と高評価数を取得します。
+func (c *Client) refreshViewerStats(ctx context.Context) error {
+	chat, err := c.ensureLiveChatID(ctx)
+	if err != nil {
+		return err
+	}
+	videoID := chat.videoID
+
+	resp, err := c.service.Videos.List([]string{"liveStreamingDetails", "statistics"}).Id(videoID).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to get video statistics: %w", err)
+	}
+	if len(resp.Items) == 0 {
+		return fmt.Errorf("video %s not found", videoID)
+	}
+
+	stats := ViewerStats{UpdatedAt: time.Now()}
+	if details := resp.Items[0].LiveStreamingDetails; details != nil {
+		stats.ConcurrentViewers = details.ConcurrentViewers
+	}
+	if s := resp.Items[0].Statistics; s != nil {
+		stats.LikeCount = s.LikeCount
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 取得中に別の配信に切り替わった場合は、古い配信の値を保持しない
+	if c.videoID == videoID {
+		c.viewers = stats
+	}
+	return nil
+}